@@ -0,0 +1,420 @@
+package ch03
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 하트비트 프레임 종류
+const (
+	HEARTBEAT_DATA uint8 = iota + 1
+	HEARTBEAT_PING
+	HEARTBEAT_PONG
+)
+
+const (
+	DEFAULT_MAX_MISSED = 3
+	// 프레임 하나에 담는 데이터의 최대 크기
+	MAX_HEARTBEAT_PAYLOAD = 1<<16 - 1
+	DEFAULT_MAX_BUFFERED  = 1 << 20
+)
+
+var ErrPeerDead = errors.New("peer missed heartbeats")
+
+// 연결에 하트비트를 더한다. 데이터는 프레임으로 감싸서 보내므로 상대도 Heartbeat를 사용해야 한다.
+//
+//	| Type(1B) | Length(2B) | Payload |
+//
+// 받은 데이터가 없는 동안 Interval마다 핑을 보내고 상대의 핑에는 퐁으로 답한다.
+// 무엇이든 받으면 읽기 기한과 다음 핑을 뒤로 미룬다.
+// 받은 데이터는 Read가 가져갈 때까지 쌓아 둔다. MaxBuffered를 넘으면 연결에서 읽기를 멈추고
+// 그동안 답을 받지 못한 핑은 세지 않는다. 상대는 계속 보내는 핑으로 이쪽이 살아 있음을 안다.
+// 하트비트는 Run을 호출해야 동작한다.
+type Heartbeat struct {
+	net.Conn
+	// 0 이하면 DEFAULT_PING_INTERVAL
+	Interval time.Duration
+	// 답을 받지 못한 핑이 이 수를 넘으면 상대가 끊긴 것으로 본다. 0 이하면 DEFAULT_MAX_MISSED
+	MaxMissed int
+	// 상대가 끊기면 호출한다. nil이면 연결을 닫는다.
+	OnDead func()
+	// 읽지 않은 데이터를 쌓아 두는 최대 크기. 0 이하면 DEFAULT_MAX_BUFFERED
+	MaxBuffered int
+
+	once     sync.Once
+	closed   chan struct{}
+	err      error
+	deadline deadline
+	// Read가 가져가지 않은 데이터
+	bufMu sync.Mutex
+	buf   bytes.Buffer
+	// 데이터가 쌓이면 readable, Read가 가져가면 drained에 알린다.
+	readable chan struct{}
+	drained  chan struct{}
+	// 쌓인 데이터가 많아 연결에서 읽지 않고 있다.
+	paused  atomic.Bool
+	writeMu sync.Mutex
+	missed  atomic.Int64
+	rtt     atomic.Int64
+	dead    sync.Once
+	// 읽기를 멈춘 후에는 읽기 기한을 미루지 않는다.
+	stopMu  sync.Mutex
+	stopped bool
+}
+
+func (h *Heartbeat) init() {
+	h.once.Do(func() {
+		h.closed = make(chan struct{})
+		h.readable = make(chan struct{}, 1)
+		h.drained = make(chan struct{}, 1)
+	})
+}
+
+func (h *Heartbeat) interval() time.Duration {
+	if h.Interval <= 0 {
+		return DEFAULT_PING_INTERVAL
+	}
+
+	return h.Interval
+}
+
+func (h *Heartbeat) maxMissed() int {
+	if h.MaxMissed <= 0 {
+		return DEFAULT_MAX_MISSED
+	}
+
+	return h.MaxMissed
+}
+
+func (h *Heartbeat) maxBuffered() int {
+	if h.MaxBuffered <= 0 {
+		return DEFAULT_MAX_BUFFERED
+	}
+
+	return h.MaxBuffered
+}
+
+// 마지막으로 잰 왕복 시간. 아직 퐁을 받지 못했으면 0이다.
+func (h *Heartbeat) RTT() time.Duration {
+	return time.Duration(h.rtt.Load())
+}
+
+// ctx가 끝나거나 연결에 문제가 생길 때까지 하트비트를 주고받는다. 한 번만 호출한다.
+// 반환한 후에는 Read가 같은 오류를 반환한다.
+func (h *Heartbeat) Run(ctx context.Context) error {
+	h.init()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// 읽고 있는 중에도 ctx가 끝나면 멈춘다.
+	stop := context.AfterFunc(ctx, h.stopReading)
+	defer stop()
+
+	reset := make(chan time.Duration, 1)
+	reset <- h.interval()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		Pinger(ctx, pingWriter{h}, reset)
+	}()
+
+	err := h.readLoop(ctx, reset)
+	if h.missed.Load() > int64(h.maxMissed()) {
+		err = ErrPeerDead
+	}
+	cancel()
+	<-done
+
+	if err == ErrPeerDead {
+		h.die()
+	}
+	h.err = err
+	close(h.closed)
+
+	return err
+}
+
+func (h *Heartbeat) stopReading() {
+	h.stopMu.Lock()
+	defer h.stopMu.Unlock()
+
+	h.stopped = true
+	_ = h.Conn.SetReadDeadline(time.Unix(1, 0))
+}
+
+func (h *Heartbeat) extendReadDeadline(d time.Duration) {
+	h.stopMu.Lock()
+	defer h.stopMu.Unlock()
+
+	if !h.stopped {
+		_ = h.Conn.SetReadDeadline(time.Now().Add(d))
+	}
+}
+
+func (h *Heartbeat) readLoop(ctx context.Context, reset chan<- time.Duration) error {
+	r := bufio.NewReader(h.Conn)
+	header := make([]byte, 3)
+	// 답을 받지 못한 핑이 MaxMissed를 넘을 만큼의 시간
+	idle := h.interval() * time.Duration(h.maxMissed()+1)
+
+	for {
+		h.extendReadDeadline(idle)
+
+		_, err := io.ReadFull(r, header)
+		if err != nil {
+			return h.readErr(ctx, err)
+		}
+		payload := make([]byte, binary.BigEndian.Uint16(header[1:]))
+		_, err = io.ReadFull(r, payload)
+		if err != nil {
+			return h.readErr(ctx, err)
+		}
+
+		// 무엇이든 받으면 상대가 살아 있다.
+		h.missed.Store(0)
+		select {
+		case reset <- 0:
+		default:
+		}
+
+		switch header[0] {
+		case HEARTBEAT_DATA:
+			err = h.queue(ctx, payload)
+			if err != nil {
+				return err
+			}
+		case HEARTBEAT_PING:
+			err = h.writeFrame(HEARTBEAT_PONG, payload)
+			if err != nil {
+				return err
+			}
+		case HEARTBEAT_PONG:
+			if len(payload) == 8 {
+				sent := time.Unix(0, int64(binary.BigEndian.Uint64(payload)))
+				h.rtt.Store(int64(time.Since(sent)))
+			}
+		default:
+			return fmt.Errorf("invalid heartbeat frame type %d", header[0])
+		}
+	}
+}
+
+// 받은 데이터를 쌓아 둔다. 쌓인 데이터가 MaxBuffered 이상이면 Read가 가져갈 때까지 기다린다.
+func (h *Heartbeat) queue(ctx context.Context, p []byte) error {
+	defer h.paused.Store(false)
+
+	for {
+		h.bufMu.Lock()
+		if h.buf.Len() < h.maxBuffered() {
+			h.buf.Write(p)
+			h.bufMu.Unlock()
+			notify(h.readable)
+			return nil
+		}
+		h.bufMu.Unlock()
+
+		h.paused.Store(true)
+		select {
+		case <-h.drained:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func notify(c chan struct{}) {
+	select {
+	case c <- struct{}{}:
+	default:
+	}
+}
+
+func (h *Heartbeat) readErr(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	var nErr net.Error
+	if errors.As(err, &nErr) && nErr.Timeout() {
+		return ErrPeerDead
+	}
+
+	return err
+}
+
+func (h *Heartbeat) die() {
+	h.dead.Do(func() {
+		if h.OnDead != nil {
+			h.OnDead()
+			return
+		}
+		_ = h.Conn.Close()
+	})
+}
+
+// Pinger가 쓰는 내용 대신 보낸 시각을 담은 핑 프레임을 보낸다.
+type pingWriter struct {
+	h *Heartbeat
+}
+
+// Pinger는 ctx가 끝날 때까지 계속 동작한다. 문제가 생기면 읽기를 멈춰 Run이 반환하게 한다.
+func (w pingWriter) Write(p []byte) (int, error) {
+	// 읽지 않고 있는 동안에는 퐁을 받을 수 없다.
+	if !w.h.paused.Load() && w.h.missed.Add(1) > int64(w.h.maxMissed()) {
+		w.h.stopReading()
+		return len(p), nil
+	}
+
+	payload := binary.BigEndian.AppendUint64(nil, uint64(time.Now().UnixNano()))
+	err := w.h.writeFrame(HEARTBEAT_PING, payload)
+	if err != nil {
+		w.h.stopReading()
+	}
+
+	return len(p), nil
+}
+
+func (h *Heartbeat) writeFrame(typ uint8, payload []byte) error {
+	b := make([]byte, 3, 3+len(payload))
+	b[0] = typ
+	binary.BigEndian.PutUint16(b[1:], uint16(len(payload)))
+	b = append(b, payload...)
+
+	h.writeMu.Lock()
+	defer h.writeMu.Unlock()
+
+	_, err := h.Conn.Write(b)
+
+	return err
+}
+
+func (h *Heartbeat) Write(p []byte) (int, error) {
+	n := 0
+	for len(p) > 0 {
+		chunk := p[:min(len(p), MAX_HEARTBEAT_PAYLOAD)]
+		err := h.writeFrame(HEARTBEAT_DATA, chunk)
+		if err != nil {
+			return n, err
+		}
+		n += len(chunk)
+		p = p[len(chunk):]
+	}
+
+	return n, nil
+}
+
+func (h *Heartbeat) Read(p []byte) (int, error) {
+	h.init()
+
+	for {
+		h.bufMu.Lock()
+		if h.buf.Len() > 0 {
+			n, _ := h.buf.Read(p)
+			h.bufMu.Unlock()
+			notify(h.drained)
+			return n, nil
+		}
+		h.bufMu.Unlock()
+
+		select {
+		case <-h.readable:
+		case <-h.closed:
+			// 쌓여 있던 데이터를 모두 읽은 후에 오류를 반환한다.
+			h.bufMu.Lock()
+			empty := h.buf.Len() == 0
+			h.bufMu.Unlock()
+			if empty {
+				return 0, h.err
+			}
+		case <-h.deadline.wait():
+			return 0, os.ErrDeadlineExceeded
+		}
+	}
+}
+
+// 하트비트가 연결의 읽기 기한을 사용하므로 Read의 기한은 따로 관리한다.
+func (h *Heartbeat) SetReadDeadline(t time.Time) error {
+	h.deadline.set(t)
+
+	return nil
+}
+
+func (h *Heartbeat) SetDeadline(t time.Time) error {
+	h.deadline.set(t)
+
+	return h.Conn.SetWriteDeadline(t)
+}
+
+// 기한이 지나면 닫히는 채널을 관리한다.
+type deadline struct {
+	mu     sync.Mutex
+	timer  *time.Timer
+	cancel chan struct{}
+}
+
+func (d *deadline) set(t time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.timer != nil && !d.timer.Stop() {
+		// 이미 닫은 채널은 다시 쓸 수 없다.
+		<-d.cancel
+	}
+	d.timer = nil
+
+	closed := d.cancel != nil && isClosed(d.cancel)
+	if t.IsZero() {
+		if closed {
+			d.cancel = make(chan struct{})
+		}
+		return
+	}
+
+	if dur := time.Until(t); dur > 0 {
+		if closed || d.cancel == nil {
+			d.cancel = make(chan struct{})
+		}
+		cancel := d.cancel
+		d.timer = time.AfterFunc(dur, func() { close(cancel) })
+		return
+	}
+
+	if d.cancel == nil {
+		d.cancel = make(chan struct{})
+	}
+	if !closed {
+		close(d.cancel)
+	}
+}
+
+func (d *deadline) wait() chan struct{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.cancel == nil {
+		d.cancel = make(chan struct{})
+	}
+
+	return d.cancel
+}
+
+func isClosed(c chan struct{}) bool {
+	select {
+	case <-c:
+		return true
+	default:
+		return false
+	}
+}
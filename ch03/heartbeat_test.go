@@ -0,0 +1,278 @@
+package ch03
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+func tcpPair(t *testing.T) (net.Conn, net.Conn) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	accepted := make(chan net.Conn)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			t.Log(err)
+		}
+		accepted <- conn
+	}()
+
+	client, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := <-accepted
+	if server == nil {
+		t.Fatal("accept failed")
+	}
+	t.Cleanup(func() {
+		_ = client.Close()
+		_ = server.Close()
+	})
+
+	return client, server
+}
+
+func runHeartbeat(ctx context.Context, h *Heartbeat) <-chan error {
+	done := make(chan error, 1)
+	go func() { done <- h.Run(ctx) }()
+
+	return done
+}
+
+func TestHeartbeat(t *testing.T) {
+	c1, c2 := tcpPair(t)
+	a := &Heartbeat{Conn: c1, Interval: 20 * time.Millisecond}
+	b := &Heartbeat{Conn: c2, Interval: 20 * time.Millisecond}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	aDone, bDone := runHeartbeat(ctx, a), runHeartbeat(ctx, b)
+
+	_, err := a.Write([]byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 5)
+	_, err = io.ReadFull(b, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "hello" {
+		t.Errorf("expected %q; actual %q", "hello", buf)
+	}
+
+	// 주고받는 데이터가 없어도 핑과 퐁으로 연결을 유지한다.
+	time.Sleep(150 * time.Millisecond)
+	if a.RTT() <= 0 || b.RTT() <= 0 {
+		t.Errorf("expected RTT to be measured; actual %s and %s", a.RTT(), b.RTT())
+	}
+
+	cancel()
+	for _, done := range []<-chan error{aDone, bDone} {
+		if err := <-done; !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context canceled; actual %v", err)
+		}
+	}
+
+	_, err = a.Read(buf)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected Read to return context canceled; actual %v", err)
+	}
+}
+
+func TestHeartbeatLargeWrite(t *testing.T) {
+	c1, c2 := tcpPair(t)
+	a := &Heartbeat{Conn: c1}
+	b := &Heartbeat{Conn: c2}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	runHeartbeat(ctx, a)
+	runHeartbeat(ctx, b)
+
+	payload := make([]byte, 3*MAX_HEARTBEAT_PAYLOAD/2)
+	for i := range payload {
+		payload[i] = byte(i)
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := a.Write(payload)
+		errs <- err
+	}()
+
+	buf := make([]byte, len(payload))
+	_, err := io.ReadFull(b, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+	for i := range buf {
+		if buf[i] != payload[i] {
+			t.Fatalf("mismatch at byte %d", i)
+		}
+	}
+}
+
+// 상대가 답하지 않으면 MaxMissed번 핑을 보낸 후 끊긴 것으로 본다.
+func TestHeartbeatDeadPeer(t *testing.T) {
+	c1, c2 := tcpPair(t)
+	go func() { _, _ = io.Copy(io.Discard, c2) }()
+
+	dead := make(chan struct{})
+	h := &Heartbeat{
+		Conn:      c1,
+		Interval:  20 * time.Millisecond,
+		MaxMissed: 2,
+		OnDead:    func() { close(dead) },
+	}
+
+	start := time.Now()
+	err := h.Run(context.Background())
+	if !errors.Is(err, ErrPeerDead) {
+		t.Fatalf("expected ErrPeerDead; actual %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 60*time.Millisecond {
+		t.Errorf("declared dead too early after %s", elapsed)
+	}
+
+	select {
+	case <-dead:
+	default:
+		t.Error("expected OnDead to be called")
+	}
+
+	// OnDead가 있으면 연결은 그대로 둔다.
+	_, err = c1.Write([]byte{0})
+	if err != nil {
+		t.Errorf("expected conn to stay open; actual %v", err)
+	}
+}
+
+func TestHeartbeatDeadPeerCloses(t *testing.T) {
+	c1, c2 := tcpPair(t)
+	go func() { _, _ = io.Copy(io.Discard, c2) }()
+
+	h := &Heartbeat{Conn: c1, Interval: 10 * time.Millisecond, MaxMissed: 1}
+	err := h.Run(context.Background())
+	if !errors.Is(err, ErrPeerDead) {
+		t.Fatalf("expected ErrPeerDead; actual %v", err)
+	}
+
+	_, err = c1.Write([]byte{0})
+	if !errors.Is(err, net.ErrClosed) {
+		t.Errorf("expected conn to be closed; actual %v", err)
+	}
+}
+
+// 상대가 핑에 답하지 않아도 데이터를 보내는 동안에는 살아 있는 것으로 본다.
+func TestHeartbeatTrafficKeepsAlive(t *testing.T) {
+	c1, c2 := tcpPair(t)
+	go func() { _, _ = io.Copy(io.Discard, c2) }()
+
+	h := &Heartbeat{Conn: c1, Interval: 20 * time.Millisecond, MaxMissed: 1}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	done := runHeartbeat(ctx, h)
+
+	go func() {
+		frame := []byte{HEARTBEAT_DATA, 0, 1, 'x'}
+		for ctx.Err() == nil {
+			_, _ = c2.Write(frame)
+			time.Sleep(5 * time.Millisecond)
+		}
+	}()
+	go func() { _, _ = io.Copy(io.Discard, h) }()
+
+	if err := <-done; !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected heartbeat to run until the deadline; actual %v", err)
+	}
+}
+
+func TestHeartbeatReadDeadline(t *testing.T) {
+	c1, c2 := tcpPair(t)
+	a := &Heartbeat{Conn: c1}
+	b := &Heartbeat{Conn: c2}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	runHeartbeat(ctx, a)
+	runHeartbeat(ctx, b)
+
+	_ = b.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	buf := make([]byte, 1)
+	_, err := b.Read(buf)
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded; actual %v", err)
+	}
+
+	// 기한을 없애면 다시 읽을 수 있다.
+	_ = b.SetReadDeadline(time.Time{})
+	_, err = a.Write([]byte("x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = b.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 읽지 않는 쪽도 핑을 계속 보내므로 양쪽 모두 상대가 살아 있는 것으로 본다.
+func TestHeartbeatSlowReader(t *testing.T) {
+	c1, c2 := tcpPair(t)
+	a := &Heartbeat{Conn: c1, Interval: 20 * time.Millisecond, MaxMissed: 2}
+	b := &Heartbeat{Conn: c2, Interval: 20 * time.Millisecond, MaxMissed: 2, MaxBuffered: 1024}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	aDone, bDone := runHeartbeat(ctx, a), runHeartbeat(ctx, b)
+
+	data := make([]byte, 8<<10)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	_, err := a.Write(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// b는 MaxBuffered를 넘은 후 연결에서 읽지 않는다.
+	time.Sleep(200 * time.Millisecond)
+	select {
+	case err := <-aDone:
+		t.Fatalf("a stopped while b was not reading: %v", err)
+	case err := <-bDone:
+		t.Fatalf("b stopped while not reading: %v", err)
+	default:
+	}
+
+	actual := make([]byte, len(data))
+	_, err = io.ReadFull(b, actual)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(actual, data) {
+		t.Error("received data does not match")
+	}
+
+	cancel()
+	for _, done := range []<-chan error{aDone, bDone} {
+		if err := <-done; !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context canceled; actual %v", err)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package ch03
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// 간격이 늘어날 수 있는 최댓값의 기본값
+const DEFAULT_MAX_PING_INTERVAL = 5 * time.Minute
+
+// 다음 핑까지 기다릴 시간을 정한다. Pinger 하나에서만 사용한다.
+type IntervalStrategy interface {
+	// Pinger를 시작하거나 reset 채널에서 값을 받으면 호출한다.
+	// d가 0보다 크면 기본 간격을 d로 바꾼다.
+	Reset(d time.Duration) time.Duration
+	// 핑을 보낸 후에 호출한다.
+	Next() time.Duration
+}
+
+// 항상 같은 간격으로 핑을 보낸다. Pinger의 기본 동작이다.
+type FixedInterval struct {
+	// 0 이하면 DEFAULT_PING_INTERVAL
+	Interval time.Duration
+}
+
+func (f *FixedInterval) Reset(d time.Duration) time.Duration {
+	if d > 0 {
+		f.Interval = d
+	}
+	if f.Interval <= 0 {
+		f.Interval = DEFAULT_PING_INTERVAL
+	}
+
+	return f.Interval
+}
+
+func (f *FixedInterval) Next() time.Duration {
+	return f.Reset(0)
+}
+
+// 간격에 0부터 Jitter 사이의 임의의 시간을 더한다.
+// 동시에 연결한 클라이언트들의 핑이 한꺼번에 몰리지 않는다.
+type JitterInterval struct {
+	FixedInterval
+	Jitter time.Duration
+}
+
+func (j *JitterInterval) Reset(d time.Duration) time.Duration {
+	return j.FixedInterval.Reset(d) + j.jitter()
+}
+
+func (j *JitterInterval) Next() time.Duration {
+	return j.Reset(0)
+}
+
+func (j *JitterInterval) jitter() time.Duration {
+	if j.Jitter <= 0 {
+		return 0
+	}
+
+	return rand.N(j.Jitter + 1)
+}
+
+// 활동 없이 핑을 보낼 때마다 간격을 Factor배로 늘린다. 활동이 있으면 기본 간격으로 돌아간다.
+type BackoffInterval struct {
+	// 기본 간격. 0 이하면 DEFAULT_PING_INTERVAL
+	Min time.Duration
+	// 0 이하면 DEFAULT_MAX_PING_INTERVAL
+	Max time.Duration
+	// 1 이하면 2
+	Factor float64
+
+	current time.Duration
+}
+
+func (b *BackoffInterval) Reset(d time.Duration) time.Duration {
+	if d > 0 {
+		b.Min = d
+	}
+	if b.Min <= 0 {
+		b.Min = DEFAULT_PING_INTERVAL
+	}
+	b.current = min(b.Min, b.max())
+
+	return b.current
+}
+
+func (b *BackoffInterval) Next() time.Duration {
+	if b.current <= 0 {
+		return b.Reset(0)
+	}
+	b.current = grow(b.current, b.Factor, b.max())
+
+	return b.current
+}
+
+func (b *BackoffInterval) max() time.Duration {
+	if b.Max <= 0 {
+		return DEFAULT_MAX_PING_INTERVAL
+	}
+
+	return b.Max
+}
+
+// 활동이 있을 때마다 간격을 두 배로 늘리고 핑을 보내면 기본 간격으로 돌아간다.
+// 데이터가 오가는 동안에는 연결이 살아 있음을 알 수 있으므로 핑을 덜 보낸다.
+type AdaptiveInterval struct {
+	// 기본 간격. 0 이하면 DEFAULT_PING_INTERVAL
+	Min time.Duration
+	// 0 이하면 DEFAULT_MAX_PING_INTERVAL
+	Max time.Duration
+
+	current time.Duration
+}
+
+func (a *AdaptiveInterval) Reset(d time.Duration) time.Duration {
+	switch {
+	case d > 0:
+		a.Min, a.current = d, 0
+	case a.current > 0:
+		// 활동이 있었다.
+		a.current = grow(a.current, 2, a.max())
+		return a.current
+	}
+
+	return a.Next()
+}
+
+// 핑을 보냈다면 그동안 활동이 없었다.
+func (a *AdaptiveInterval) Next() time.Duration {
+	if a.Min <= 0 {
+		a.Min = DEFAULT_PING_INTERVAL
+	}
+	a.current = min(a.Min, a.max())
+
+	return a.current
+}
+
+func (a *AdaptiveInterval) max() time.Duration {
+	if a.Max <= 0 {
+		return DEFAULT_MAX_PING_INTERVAL
+	}
+
+	return a.Max
+}
+
+func grow(d time.Duration, factor float64, limit time.Duration) time.Duration {
+	if factor <= 1 {
+		factor = 2
+	}
+	// 곱한 값이 넘치지 않도록 먼저 비교한다.
+	if float64(d) >= float64(limit)/factor {
+		return limit
+	}
+
+	return time.Duration(float64(d) * factor)
+}
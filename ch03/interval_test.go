@@ -0,0 +1,134 @@
+package ch03
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestFixedInterval(t *testing.T) {
+	f := new(FixedInterval)
+	if d := f.Reset(0); d != DEFAULT_PING_INTERVAL {
+		t.Errorf("expected %s; actual %s", DEFAULT_PING_INTERVAL, d)
+	}
+	if d := f.Reset(time.Second); d != time.Second {
+		t.Errorf("expected 1s; actual %s", d)
+	}
+	// 0은 간격을 바꾸지 않는다.
+	for _, d := range []time.Duration{f.Next(), f.Reset(0), f.Next()} {
+		if d != time.Second {
+			t.Errorf("expected 1s; actual %s", d)
+		}
+	}
+}
+
+func TestJitterInterval(t *testing.T) {
+	j := &JitterInterval{FixedInterval: FixedInterval{Interval: time.Second}, Jitter: 100 * time.Millisecond}
+	seen := make(map[time.Duration]bool)
+	for i := 0; i < 100; i++ {
+		d := j.Next()
+		if d < time.Second || d > 1100*time.Millisecond {
+			t.Fatalf("interval %s out of range", d)
+		}
+		seen[d] = true
+	}
+	if len(seen) < 2 {
+		t.Error("expected intervals to vary")
+	}
+}
+
+func TestBackoffInterval(t *testing.T) {
+	b := &BackoffInterval{Min: time.Second, Max: 10 * time.Second}
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second}
+
+	actual := []time.Duration{b.Reset(0)}
+	for len(actual) < len(expected) {
+		actual = append(actual, b.Next())
+	}
+	for i := range expected {
+		if actual[i] != expected[i] {
+			t.Fatalf("expected %v; actual %v", expected, actual)
+		}
+	}
+
+	// 활동이 있으면 처음 간격으로 돌아간다.
+	if d := b.Reset(0); d != time.Second {
+		t.Errorf("expected 1s after reset; actual %s", d)
+	}
+
+	b = &BackoffInterval{Min: time.Second, Max: 10 * time.Second, Factor: 3}
+	b.Reset(0)
+	if d := b.Next(); d != 3*time.Second {
+		t.Errorf("expected 3s; actual %s", d)
+	}
+}
+
+func TestAdaptiveInterval(t *testing.T) {
+	a := &AdaptiveInterval{Min: time.Second, Max: 5 * time.Second}
+	if d := a.Reset(0); d != time.Second {
+		t.Fatalf("expected 1s; actual %s", d)
+	}
+
+	// 데이터가 오가는 동안에는 간격이 늘어난다.
+	expected := []time.Duration{2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for _, e := range expected {
+		if d := a.Reset(0); d != e {
+			t.Errorf("expected %s; actual %s", e, d)
+		}
+	}
+
+	// 핑을 보냈다면 활동이 없었으므로 처음 간격으로 돌아간다.
+	if d := a.Next(); d != time.Second {
+		t.Errorf("expected 1s after idle ping; actual %s", d)
+	}
+	if d := a.Reset(3 * time.Second); d != 3*time.Second {
+		t.Errorf("expected 3s; actual %s", d)
+	}
+}
+
+// 핑을 보낸 시각을 기록한다.
+type pingTimes chan time.Time
+
+func (p pingTimes) Write(b []byte) (int, error) {
+	p <- time.Now()
+
+	return len(b), nil
+}
+
+func TestPingerWithStrategy(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	pings := make(pingTimes, 10)
+	s := &BackoffInterval{Min: 20 * time.Millisecond, Max: 80 * time.Millisecond}
+	start := time.Now()
+	go PingerWithStrategy(ctx, pings, nil, s)
+
+	// 20ms, 40ms, 80ms, 80ms 간격으로 핑을 보낸다.
+	expected := []time.Duration{20, 60, 140, 220}
+	for _, e := range expected {
+		e *= time.Millisecond
+		elapsed := (<-pings).Sub(start)
+		if elapsed < e || elapsed > e+50*time.Millisecond {
+			t.Errorf("expected ping at %s; actual %s", e, elapsed)
+		}
+	}
+}
+
+func TestPingerWithStrategyWriteError(t *testing.T) {
+	r, w := io.Pipe()
+	_ = r.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		PingerWithStrategy(context.Background(), w, nil, &FixedInterval{Interval: time.Millisecond})
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected Pinger to return after write error")
+	}
+}
@@ -8,26 +8,29 @@ import (
 
 const DEFAULT_PING_INTERVAL = 30 * time.Second
 
+// reset 채널로 받은 간격을 계속 사용한다.
 func Pinger(ctx context.Context, w io.Writer, reset <-chan time.Duration) {
-	var interval time.Duration
+	PingerWithStrategy(ctx, w, reset, new(FixedInterval))
+}
+
+// 핑 사이의 간격을 strategy가 정한다.
+// reset 채널로 값을 받으면 타이머를 다시 시작한다. 0 이하의 값은 간격을 바꾸지 않고 활동이 있었음을 알린다.
+func PingerWithStrategy(ctx context.Context, w io.Writer, reset <-chan time.Duration, strategy IntervalStrategy) {
+	var initial time.Duration
 	select {
 	case <-ctx.Done():
 		return
-	case interval = <-reset:
+	case initial = <-reset:
 	default:
 	}
-	if interval <= 0 {
-		interval = DEFAULT_PING_INTERVAL
-	}
 
-	timer := time.NewTimer(interval)
-	defer func() {
-		if !timer.Stop() {
-			<-timer.C
-		}
-	}()
+	timer := time.NewTimer(strategy.Reset(initial))
+	// 쓰기에 실패해 반환할 때는 이미 타이머 값을 받았으므로 채널을 비우지 않는다.
+	defer timer.Stop()
 
 	for {
+		var interval time.Duration
+
 		select {
 		case <-ctx.Done():
 			return
@@ -35,13 +38,12 @@ func Pinger(ctx context.Context, w io.Writer, reset <-chan time.Duration) {
 			if !timer.Stop() {
 				<-timer.C
 			}
-			if newInterval > 0 {
-				interval = newInterval
-			}
+			interval = strategy.Reset(newInterval)
 		case <-timer.C:
 			if _, err := w.Write([]byte("ping")); err != nil {
 				return
 			}
+			interval = strategy.Next()
 		}
 		_ = timer.Reset(interval)
 	}
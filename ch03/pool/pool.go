@@ -0,0 +1,501 @@
+package pool
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	DEFAULT_MAX_IDLE = 2
+	// 검사할 때 상대가 보낸 데이터나 연결 종료를 기다리는 시간
+	VALIDATE_TIMEOUT = time.Millisecond
+	// 이보다 짧게 쉰 연결은 검사하지 않는다.
+	DEFAULT_VALIDATE_AFTER = 100 * time.Millisecond
+)
+
+var ErrClosed = errors.New("pool closed")
+
+// 새 연결을 만든다.
+type DialFunc func(ctx context.Context) (net.Conn, error)
+
+// network와 address로 연결한다. tcp와 unix 모두 사용할 수 있다. d가 nil이면 기본값을 사용한다.
+func NetDialer(d *net.Dialer, network, address string) DialFunc {
+	if d == nil {
+		d = new(net.Dialer)
+	}
+
+	return func(ctx context.Context) (net.Conn, error) {
+		return d.DialContext(ctx, network, address)
+	}
+}
+
+// 연결하고 TLS 핸드셰이크까지 마친다. d가 nil이면 기본값을 사용한다.
+func TLSDialer(d *tls.Dialer, network, address string) DialFunc {
+	if d == nil {
+		d = new(tls.Dialer)
+	}
+
+	return func(ctx context.Context) (net.Conn, error) {
+		return d.DialContext(ctx, network, address)
+	}
+}
+
+// 연결을 재사용한다. 꺼낼 때마다 연결을 검사하므로 끊긴 연결은 내주지 않는다.
+// 사용한 연결은 Close로 돌려준다.
+type Pool struct {
+	// 반드시 있어야 한다.
+	Dial DialFunc
+	// 꺼낼 때 연결을 검사한다. nil이면 Alive를 사용한다.
+	// Alive는 VALIDATE_TIMEOUT만큼 읽어 보므로 ValidateAfter보다 오래 쉰 연결을 꺼낼 때마다 그만큼 늦어진다.
+	// TLS 연결에서는 세션 티켓 같은 레코드를 처리하느라 더 걸릴 수 있다.
+	// 지연이 문제면 프로토콜에 맞는 검사를 지정하거나 항상 nil을 반환해 검사를 끈다.
+	Validate func(conn net.Conn) error
+	// 이 시간보다 짧게 쉰 연결은 검사하지 않고 내준다. 그사이 끊긴 연결은 사용할 때 오류가 난다.
+	// 0이면 DEFAULT_VALIDATE_AFTER, 음수면 항상 검사한다.
+	ValidateAfter time.Duration
+	// 쉬고 있는 연결의 최대 수. 0이면 DEFAULT_MAX_IDLE, 음수면 쉬는 연결을 두지 않는다.
+	MaxIdle int
+	// 열려 있는 연결의 최대 수. 0 이하면 제한하지 않는다.
+	MaxOpen int
+	// 이 시간 동안 쓰지 않은 연결은 닫는다. 0 이하면 제한하지 않는다.
+	IdleTimeout time.Duration
+	// 연결한 지 이 시간이 지난 연결은 닫는다. 0 이하면 제한하지 않는다.
+	MaxLifetime time.Duration
+
+	mu     sync.Mutex
+	idle   []*pooled
+	open   int
+	closed bool
+	// 연결이 반환되거나 닫히면 닫고 새로 만든다.
+	released chan struct{}
+	cleaner  sync.Once
+	done     chan struct{}
+	stats    Stats
+}
+
+// 풀의 상태
+type Stats struct {
+	Open  int
+	InUse int
+	Idle  int
+	// 연결을 기다린 횟수와 시간
+	WaitCount    int64
+	WaitDuration time.Duration
+	// 기다리다 ctx가 끝난 횟수
+	WaitTimeouts int64
+	Dials        int64
+	DialErrors   int64
+	// 검사에 실패했거나 사용하다 오류가 나서 닫은 연결
+	InvalidClosed     int64
+	MaxIdleClosed     int64
+	IdleTimeoutClosed int64
+	MaxLifetimeClosed int64
+}
+
+func (p *Pool) Stats() Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	s := p.stats
+	s.Open = p.open
+	s.Idle = len(p.idle)
+	s.InUse = p.open - len(p.idle)
+
+	return s
+}
+
+func (p *Pool) maxIdle() int {
+	switch {
+	case p.MaxIdle == 0:
+		return DEFAULT_MAX_IDLE
+	case p.MaxIdle < 0:
+		return 0
+	}
+
+	return p.MaxIdle
+}
+
+// 쉬고 있는 연결을 꺼내거나 새로 연결한다.
+// MaxOpen개의 연결을 모두 사용하고 있으면 연결이 반환되거나 ctx가 끝날 때까지 기다린다.
+func (p *Pool) Get(ctx context.Context) (*Conn, error) {
+	var waitStart time.Time
+	defer func() {
+		if !waitStart.IsZero() {
+			p.mu.Lock()
+			p.stats.WaitCount++
+			p.stats.WaitDuration += time.Since(waitStart)
+			p.mu.Unlock()
+		}
+	}()
+
+	for {
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			return nil, ErrClosed
+		}
+
+		// 최근에 반환된 연결부터 사용한다.
+		if n := len(p.idle); n > 0 {
+			c := p.idle[n-1]
+			p.idle = p.idle[:n-1]
+			expired := p.expired(c, time.Now())
+			p.mu.Unlock()
+
+			if expired || p.validate(c) != nil {
+				if !expired {
+					p.count(&p.stats.InvalidClosed)
+				}
+				p.discard(c)
+				continue
+			}
+			// 꺼낼 때마다 새 Conn을 만들어 이전 사용자의 Close가 영향을 주지 않게 한다.
+			return &Conn{conn: c.Conn, pool: p, pc: c}, nil
+		}
+
+		if p.MaxOpen <= 0 || p.open < p.MaxOpen {
+			p.open++
+			p.stats.Dials++
+			p.mu.Unlock()
+			return p.dial(ctx)
+		}
+
+		released := p.releasedLocked()
+		p.mu.Unlock()
+
+		if waitStart.IsZero() {
+			waitStart = time.Now()
+		}
+		select {
+		case <-released:
+		case <-ctx.Done():
+			p.count(&p.stats.WaitTimeouts)
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (p *Pool) dial(ctx context.Context) (*Conn, error) {
+	conn, err := p.Dial(ctx)
+	if err != nil {
+		p.mu.Lock()
+		p.open--
+		p.stats.DialErrors++
+		p.notifyLocked()
+		p.mu.Unlock()
+		return nil, err
+	}
+
+	return &Conn{conn: conn, pool: p, pc: &pooled{Conn: conn, created: time.Now()}}, nil
+}
+
+func (p *Pool) validate(c *pooled) error {
+	after := p.ValidateAfter
+	if after == 0 {
+		after = DEFAULT_VALIDATE_AFTER
+	}
+	if time.Since(c.idleSince) < after {
+		return nil
+	}
+
+	if p.Validate != nil {
+		return p.Validate(c.Conn)
+	}
+
+	return Alive(c.Conn)
+}
+
+// 연결이 끊겼는지 확인한다. 쉬고 있는 연결에는 읽을 데이터가 없어야 한다.
+// 기한을 VALIDATE_TIMEOUT만큼 두고 읽으므로 그만큼 시간이 걸린다.
+func Alive(conn net.Conn) error {
+	err := conn.SetReadDeadline(time.Now().Add(VALIDATE_TIMEOUT))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = conn.SetReadDeadline(time.Time{}) }()
+
+	n, err := conn.Read(make([]byte, 1))
+	if n > 0 {
+		return errors.New("unexpected data on idle connection")
+	}
+	var nErr net.Error
+	if errors.As(err, &nErr) && nErr.Timeout() {
+		return nil
+	}
+	if err == nil {
+		return errors.New("unexpected empty read on idle connection")
+	}
+
+	return err
+}
+
+// 잠금을 잡은 상태에서 호출한다. 만료된 이유를 통계에 더한다.
+func (p *Pool) expired(c *pooled, now time.Time) bool {
+	switch {
+	case p.MaxLifetime > 0 && now.Sub(c.created) >= p.MaxLifetime:
+		p.stats.MaxLifetimeClosed++
+		return true
+	case p.IdleTimeout > 0 && now.Sub(c.idleSince) >= p.IdleTimeout:
+		p.stats.IdleTimeoutClosed++
+		return true
+	}
+
+	return false
+}
+
+func (p *Pool) count(n *int64) {
+	p.mu.Lock()
+	*n++
+	p.mu.Unlock()
+}
+
+// 연결을 닫고 열린 연결의 수에서 뺀다.
+func (p *Pool) discard(c *pooled) {
+	_ = c.Conn.Close()
+
+	p.mu.Lock()
+	p.open--
+	p.notifyLocked()
+	p.mu.Unlock()
+}
+
+func (p *Pool) releasedLocked() chan struct{} {
+	if p.released == nil {
+		p.released = make(chan struct{})
+	}
+
+	return p.released
+}
+
+// 기다리는 고루틴을 모두 깨운다.
+func (p *Pool) notifyLocked() {
+	if p.released != nil {
+		close(p.released)
+		p.released = nil
+	}
+}
+
+func (p *Pool) put(h *Conn) error {
+	c := h.pc
+	if h.broken.Load() {
+		p.count(&p.stats.InvalidClosed)
+		p.discard(c)
+		return nil
+	}
+	// 사용자가 바꾼 기한을 되돌린다.
+	if err := c.Conn.SetDeadline(time.Time{}); err != nil {
+		p.count(&p.stats.InvalidClosed)
+		p.discard(c)
+		return nil
+	}
+
+	now := time.Now()
+	c.idleSince = now
+
+	p.mu.Lock()
+	switch {
+	case p.closed:
+	case p.MaxLifetime > 0 && now.Sub(c.created) >= p.MaxLifetime:
+		p.stats.MaxLifetimeClosed++
+	case len(p.idle) >= p.maxIdle():
+		p.stats.MaxIdleClosed++
+	default:
+		p.idle = append(p.idle, c)
+		p.notifyLocked()
+		p.mu.Unlock()
+		p.startCleaner()
+		return nil
+	}
+	p.mu.Unlock()
+
+	p.discard(c)
+
+	return nil
+}
+
+// IdleTimeout이나 MaxLifetime이 있으면 쉬고 있는 연결을 주기적으로 정리한다.
+func (p *Pool) startCleaner() {
+	interval := p.IdleTimeout
+	if interval <= 0 || (p.MaxLifetime > 0 && p.MaxLifetime < interval) {
+		interval = p.MaxLifetime
+	}
+	if interval <= 0 {
+		return
+	}
+
+	p.cleaner.Do(func() {
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			return
+		}
+		p.done = make(chan struct{})
+		done := p.done
+		p.mu.Unlock()
+
+		go func() {
+			ticker := time.NewTicker(max(interval/2, time.Millisecond))
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-done:
+					return
+				case <-ticker.C:
+					p.clean()
+				}
+			}
+		}()
+	})
+}
+
+func (p *Pool) clean() {
+	now := time.Now()
+
+	p.mu.Lock()
+	var expired []*pooled
+	idle := p.idle[:0]
+	for _, c := range p.idle {
+		if p.expired(c, now) {
+			expired = append(expired, c)
+			continue
+		}
+		idle = append(idle, c)
+	}
+	clear(p.idle[len(idle):])
+	p.idle = idle
+	p.mu.Unlock()
+
+	for _, c := range expired {
+		p.discard(c)
+	}
+}
+
+// 쉬고 있는 연결을 닫는다. 사용 중인 연결은 반환될 때 닫는다.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return ErrClosed
+	}
+	p.closed = true
+	idle := p.idle
+	p.idle = nil
+	p.open -= len(idle)
+	if p.done != nil {
+		close(p.done)
+	}
+	p.notifyLocked()
+	p.mu.Unlock()
+
+	var errs []error
+	for _, c := range idle {
+		errs = append(errs, c.Conn.Close())
+	}
+
+	return errors.Join(errs...)
+}
+
+// 풀이 관리하는 연결
+type pooled struct {
+	net.Conn
+	created   time.Time
+	idleSince time.Time
+}
+
+// 풀에서 꺼낸 연결. Close하면 풀로 돌아가고 이후의 호출은 net.ErrClosed를 반환한다.
+// 읽거나 쓰다가 기한 초과가 아닌 오류가 나면 돌려받을 때 닫는다.
+type Conn struct {
+	// 돌려준 후에는 다른 사용자가 쓰므로 드러내지 않는다.
+	conn     net.Conn
+	pool     *Pool
+	pc       *pooled
+	broken   atomic.Bool
+	returned atomic.Bool
+}
+
+func (c *Conn) Read(p []byte) (int, error) {
+	// 돌려준 연결은 다른 사용자가 쓰고 있을 수 있다.
+	if c.returned.Load() {
+		return 0, net.ErrClosed
+	}
+
+	n, err := c.conn.Read(p)
+	c.check(err)
+
+	return n, err
+}
+
+func (c *Conn) Write(p []byte) (int, error) {
+	if c.returned.Load() {
+		return 0, net.ErrClosed
+	}
+
+	n, err := c.conn.Write(p)
+	c.check(err)
+
+	return n, err
+}
+
+func (c *Conn) check(err error) {
+	if err == nil {
+		return
+	}
+	var nErr net.Error
+	if errors.As(err, &nErr) && nErr.Timeout() {
+		return
+	}
+	c.broken.Store(true)
+}
+
+// 연결을 다시 사용하지 않도록 한다. 프로토콜 상태를 알 수 없게 되었을 때 사용한다.
+func (c *Conn) MarkUnusable() {
+	c.broken.Store(true)
+}
+
+// 연결을 풀로 돌려준다.
+func (c *Conn) Close() error {
+	if c.returned.Swap(true) {
+		return net.ErrClosed
+	}
+
+	return c.pool.put(c)
+}
+
+func (c *Conn) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// 기한은 돌려받을 때 되돌린다.
+func (c *Conn) SetDeadline(t time.Time) error {
+	if c.returned.Load() {
+		return net.ErrClosed
+	}
+
+	return c.conn.SetDeadline(t)
+}
+
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	if c.returned.Load() {
+		return net.ErrClosed
+	}
+
+	return c.conn.SetReadDeadline(t)
+}
+
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	if c.returned.Load() {
+		return net.ErrClosed
+	}
+
+	return c.conn.SetWriteDeadline(t)
+}
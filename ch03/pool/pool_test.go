@@ -0,0 +1,362 @@
+package pool
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// 받은 연결을 conns로 보내고 받은 데이터를 그대로 돌려준다.
+func echoServer(t *testing.T, network, address string) (net.Listener, <-chan net.Conn) {
+	t.Helper()
+
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	conns := make(chan net.Conn, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conns <- conn
+			go func() {
+				defer conn.Close()
+				_, _ = io.Copy(conn, conn)
+			}()
+		}
+	}()
+
+	return listener, conns
+}
+
+func echo(t *testing.T, conn net.Conn) {
+	t.Helper()
+
+	_, err := conn.Write([]byte("ping"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 4)
+	_, err = io.ReadFull(conn, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "ping" {
+		t.Fatalf("expected %q; actual %q", "ping", buf)
+	}
+}
+
+func get(t *testing.T, p *Pool) *Conn {
+	t.Helper()
+
+	conn, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return conn
+}
+
+func TestPoolReuse(t *testing.T) {
+	l, _ := echoServer(t, "tcp", "127.0.0.1:")
+	p := &Pool{Dial: NetDialer(nil, "tcp", l.Addr().String())}
+	defer p.Close()
+
+	c1 := get(t, p)
+	echo(t, c1)
+	local := c1.LocalAddr().String()
+	_ = c1.Close()
+
+	c2 := get(t, p)
+	echo(t, c2)
+	if c2.LocalAddr().String() != local {
+		t.Errorf("expected idle connection to be reused")
+	}
+
+	s := p.Stats()
+	if s.Dials != 1 || s.Open != 1 || s.InUse != 1 || s.Idle != 0 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+
+	_ = c2.Close()
+	if err := c2.Close(); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("expected net.ErrClosed on second Close; actual %v", err)
+	}
+	if s := p.Stats(); s.Idle != 1 || s.InUse != 0 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+}
+
+// 이미 돌려준 연결을 다시 Close해도 다른 사용자가 꺼낸 연결에는 영향이 없다.
+func TestPoolStaleClose(t *testing.T) {
+	l, _ := echoServer(t, "tcp", "127.0.0.1:")
+	p := &Pool{Dial: NetDialer(nil, "tcp", l.Addr().String())}
+	defer p.Close()
+
+	c1 := get(t, p)
+	_ = c1.Close()
+
+	c2 := get(t, p)
+	defer c2.Close()
+	if err := c1.Close(); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("expected net.ErrClosed on stale Close; actual %v", err)
+	}
+	if _, err := c1.Write([]byte("ping")); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("expected net.ErrClosed on stale Write; actual %v", err)
+	}
+	if err := c1.SetDeadline(time.Now()); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("expected net.ErrClosed on stale SetDeadline; actual %v", err)
+	}
+	if s := p.Stats(); s.Idle != 0 || s.InUse != 1 {
+		t.Errorf("expected connection to stay in use; actual %+v", s)
+	}
+	echo(t, c2)
+}
+
+// 서버가 끊은 연결은 내주지 않는다.
+func TestPoolValidate(t *testing.T) {
+	l, conns := echoServer(t, "tcp", "127.0.0.1:")
+	p := &Pool{Dial: NetDialer(nil, "tcp", l.Addr().String()), ValidateAfter: -1}
+	defer p.Close()
+
+	c1 := get(t, p)
+	_ = c1.Close()
+	_ = (<-conns).Close()
+
+	c2 := get(t, p)
+	defer c2.Close()
+	echo(t, c2)
+
+	s := p.Stats()
+	if s.Dials != 2 || s.InvalidClosed != 1 || s.Open != 1 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+}
+
+// 방금 돌려받은 연결은 검사하지 않는다.
+func TestPoolValidateAfter(t *testing.T) {
+	l, _ := echoServer(t, "tcp", "127.0.0.1:")
+	var validated int
+	p := &Pool{
+		Dial: NetDialer(nil, "tcp", l.Addr().String()),
+		Validate: func(conn net.Conn) error {
+			validated++
+			return Alive(conn)
+		},
+		ValidateAfter: 20 * time.Millisecond,
+	}
+	defer p.Close()
+
+	_ = get(t, p).Close()
+	_ = get(t, p).Close()
+	if validated != 0 {
+		t.Errorf("expected recently returned connection not to be validated; validated %d times", validated)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	c := get(t, p)
+	defer c.Close()
+	if validated != 1 {
+		t.Errorf("expected idle connection to be validated once; validated %d times", validated)
+	}
+}
+
+// 사용하다 오류가 난 연결은 돌려받을 때 닫는다.
+func TestPoolBroken(t *testing.T) {
+	l, conns := echoServer(t, "tcp", "127.0.0.1:")
+	p := &Pool{Dial: NetDialer(nil, "tcp", l.Addr().String())}
+	defer p.Close()
+
+	c1 := get(t, p)
+	_ = (<-conns).Close()
+	_, err := c1.Read(make([]byte, 1))
+	if err == nil {
+		t.Fatal("expected read error")
+	}
+	_ = c1.Close()
+
+	// 기한 초과는 오류로 보지 않는다.
+	c2 := get(t, p)
+	_ = c2.SetReadDeadline(time.Now().Add(time.Millisecond))
+	_, err = c2.Read(make([]byte, 1))
+	var nErr net.Error
+	if !errors.As(err, &nErr) || !nErr.Timeout() {
+		t.Fatalf("expected timeout; actual %v", err)
+	}
+	_ = c2.Close()
+
+	c3 := get(t, p)
+	defer c3.Close()
+	echo(t, c3)
+
+	s := p.Stats()
+	if s.Dials != 2 || s.InvalidClosed != 1 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+}
+
+func TestPoolMaxOpen(t *testing.T) {
+	l, _ := echoServer(t, "tcp", "127.0.0.1:")
+	p := &Pool{Dial: NetDialer(nil, "tcp", l.Addr().String()), MaxOpen: 1}
+	defer p.Close()
+
+	c1 := get(t, p)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := p.Get(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded; actual %v", err)
+	}
+
+	got := make(chan *Conn)
+	go func() {
+		c, err := p.Get(context.Background())
+		if err != nil {
+			t.Error(err)
+		}
+		got <- c
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	_ = c1.Close()
+	c2 := <-got
+	if c2 == nil {
+		t.FailNow()
+	}
+	defer c2.Close()
+	echo(t, c2)
+
+	s := p.Stats()
+	if s.Dials != 1 || s.WaitCount != 2 || s.WaitTimeouts != 1 || s.WaitDuration < 40*time.Millisecond {
+		t.Errorf("unexpected stats %+v", s)
+	}
+}
+
+func TestPoolMaxIdle(t *testing.T) {
+	l, _ := echoServer(t, "tcp", "127.0.0.1:")
+	p := &Pool{Dial: NetDialer(nil, "tcp", l.Addr().String()), MaxIdle: 1}
+	defer p.Close()
+
+	c1, c2 := get(t, p), get(t, p)
+	_ = c1.Close()
+	_ = c2.Close()
+
+	s := p.Stats()
+	if s.Open != 1 || s.Idle != 1 || s.MaxIdleClosed != 1 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+}
+
+func TestPoolIdleTimeout(t *testing.T) {
+	l, _ := echoServer(t, "tcp", "127.0.0.1:")
+	p := &Pool{Dial: NetDialer(nil, "tcp", l.Addr().String()), IdleTimeout: 20 * time.Millisecond}
+	defer p.Close()
+
+	_ = get(t, p).Close()
+	time.Sleep(60 * time.Millisecond)
+
+	s := p.Stats()
+	if s.Open != 0 || s.Idle != 0 || s.IdleTimeoutClosed != 1 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+}
+
+func TestPoolMaxLifetime(t *testing.T) {
+	l, _ := echoServer(t, "tcp", "127.0.0.1:")
+	p := &Pool{Dial: NetDialer(nil, "tcp", l.Addr().String()), MaxLifetime: 20 * time.Millisecond}
+	defer p.Close()
+
+	c := get(t, p)
+	time.Sleep(30 * time.Millisecond)
+	_ = c.Close()
+
+	s := p.Stats()
+	if s.Open != 0 || s.MaxLifetimeClosed != 1 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+}
+
+func TestPoolClose(t *testing.T) {
+	l, _ := echoServer(t, "tcp", "127.0.0.1:")
+	p := &Pool{Dial: NetDialer(nil, "tcp", l.Addr().String())}
+
+	c1, c2 := get(t, p), get(t, p)
+	_ = c1.Close()
+
+	err := p.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = p.Get(context.Background())
+	if !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed; actual %v", err)
+	}
+
+	// 사용 중이던 연결은 반환할 때 닫는다.
+	_ = c2.Close()
+	if s := p.Stats(); s.Open != 0 {
+		t.Errorf("expected all connections to be closed; actual %+v", s)
+	}
+}
+
+func TestPoolUnix(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "echo.sock")
+	echoServer(t, "unix", socket)
+	p := &Pool{Dial: NetDialer(nil, "unix", socket)}
+	defer p.Close()
+
+	for range 3 {
+		c := get(t, p)
+		echo(t, c)
+		_ = c.Close()
+	}
+	if s := p.Stats(); s.Dials != 1 {
+		t.Errorf("expected one dial; actual %+v", s)
+	}
+}
+
+func TestPoolTLS(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	d := &tls.Dialer{Config: ts.Client().Transport.(*http.Transport).TLSClientConfig}
+	p := &Pool{Dial: TLSDialer(d, "tcp", ts.Listener.Addr().String())}
+	defer p.Close()
+
+	for range 3 {
+		c := get(t, p)
+		_, err := c.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.ReadResponse(bufio.NewReader(c), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if string(body) != "ok" {
+			t.Errorf("expected %q; actual %q", "ok", body)
+		}
+		_ = c.Close()
+	}
+	if s := p.Stats(); s.Dials != 1 || s.InvalidClosed != 0 {
+		t.Errorf("expected TLS connection to be reused; actual %+v", s)
+	}
+}
@@ -0,0 +1,222 @@
+package ch03
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RFC 8305에서 권하는 시도 사이의 간격
+const DEFAULT_ATTEMPT_DELAY = 250 * time.Millisecond
+
+// 여러 주소로 시차를 두고 연결을 시도하고 가장 먼저 연결된 것을 사용한다. (RFC 8305)
+// 앞선 시도가 실패하면 간격을 기다리지 않고 다음 주소로 시도한다.
+// 연결에 성공하면 나머지 시도를 취소하고 이미 연결된 것은 닫는다.
+type RaceDialer struct {
+	// 각 시도에 사용한다. Timeout은 시도마다 적용하고 Resolver로 호스트 이름을 찾는다.
+	Dialer net.Dialer
+	// 다음 시도를 시작하기 전까지 기다리는 시간. 0 이하면 DEFAULT_ATTEMPT_DELAY
+	Delay time.Duration
+}
+
+// 연결에 성공한 시도
+type DialAttempt struct {
+	// 시도한 순서. 0부터 시작한다.
+	Index   int
+	Address string
+	// 첫 시도부터 이 시도를 시작할 때까지 걸린 시간
+	Started time.Duration
+	// 이 시도가 연결하는 데 걸린 시간
+	Elapsed time.Duration
+}
+
+func (d *RaceDialer) delay() time.Duration {
+	if d.Delay <= 0 {
+		return DEFAULT_ATTEMPT_DELAY
+	}
+
+	return d.Delay
+}
+
+// net.Dialer.DialContext 대신 사용할 수 있다.
+func (d *RaceDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	conn, _, err := d.Race(ctx, network, address)
+
+	return conn, err
+}
+
+// 호스트 이름을 찾아 나온 주소들로 경쟁한다. IPv6와 IPv4 주소를 번갈아 시도한다.
+func (d *RaceDialer) Race(ctx context.Context, network, address string) (net.Conn, DialAttempt, error) {
+	addrs, err := d.resolve(ctx, network, address)
+	if err != nil {
+		return nil, DialAttempt{}, err
+	}
+
+	return d.RaceAddrs(ctx, network, addrs)
+}
+
+// 주어진 순서대로 시도한다.
+func (d *RaceDialer) RaceAddrs(ctx context.Context, network string, addrs []string) (net.Conn, DialAttempt, error) {
+	if len(addrs) == 0 {
+		return nil, DialAttempt{}, errors.New("no addresses to dial")
+	}
+
+	type result struct {
+		conn    net.Conn
+		attempt DialAttempt
+		err     error
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// 실패한 시도가 기다리지 않도록 모든 결과를 담을 수 있게 만든다.
+	results := make(chan result, len(addrs))
+	var wg sync.WaitGroup
+	start := time.Now()
+	next, pending := 0, 0
+	timer := time.NewTimer(d.delay())
+	defer timer.Stop()
+
+	launch := func() {
+		attempt := DialAttempt{Index: next, Address: addrs[next], Started: time.Since(start)}
+		next++
+		pending++
+		// 첫 시도와 실패 후의 시도는 타이머가 아직 돌고 있다.
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(d.delay())
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			conn, err := d.Dialer.DialContext(ctx, network, attempt.Address)
+			attempt.Elapsed = time.Since(start) - attempt.Started
+			results <- result{conn, attempt, err}
+		}()
+	}
+
+	// 반환하기 전에 나머지 시도가 끝나기를 기다리고 연결된 것은 닫는다.
+	finish := func() {
+		cancel()
+		wg.Wait()
+		close(results)
+		for r := range results {
+			if r.conn != nil {
+				_ = r.conn.Close()
+			}
+		}
+	}
+
+	launch()
+	var errs []error
+
+	for pending > 0 {
+		select {
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				finish()
+				return r.conn, r.attempt, nil
+			}
+			errs = append(errs, fmt.Errorf("%s: %w", r.attempt.Address, r.err))
+			// 실패하면 간격을 기다리지 않는다.
+			if next < len(addrs) {
+				launch()
+			}
+		case <-timer.C:
+			if next < len(addrs) {
+				launch()
+			}
+		case <-ctx.Done():
+			finish()
+			return nil, DialAttempt{}, ctx.Err()
+		}
+	}
+	finish()
+
+	return nil, DialAttempt{}, fmt.Errorf("all %d attempts failed: %w", len(addrs), errors.Join(errs...))
+}
+
+// 경쟁할 주소가 없으면 address 하나만 반환해 net.Dialer에 맡긴다.
+func (d *RaceDialer) resolve(ctx context.Context, network, address string) ([]string, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6", "udp", "udp4", "udp6":
+	default:
+		// unix 등은 호스트 이름을 찾지 않는다.
+		return []string{address}, nil
+	}
+
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	// 호스트가 없으면 net.Dialer가 로컬 시스템으로 연결한다.
+	if host == "" {
+		return []string{address}, nil
+	}
+	if _, err := netip.ParseAddr(host); err == nil {
+		return []string{address}, nil
+	}
+
+	ipNetwork := "ip"
+	switch {
+	case strings.HasSuffix(network, "4"):
+		ipNetwork = "ip4"
+	case strings.HasSuffix(network, "6"):
+		ipNetwork = "ip6"
+	}
+
+	resolver := d.Dialer.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	ips, err := resolver.LookupNetIP(ctx, ipNetwork, host)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs := make([]string, 0, len(ips))
+	for _, ip := range interleave(ips) {
+		addrs = append(addrs, net.JoinHostPort(ip.Unmap().String(), port))
+	}
+
+	return addrs, nil
+}
+
+// 첫 번째 주소의 주소 체계부터 시작해 IPv6와 IPv4 주소를 번갈아 늘어놓는다.
+func interleave(ips []netip.Addr) []netip.Addr {
+	var v4, v6 []netip.Addr
+	for _, ip := range ips {
+		if ip.Unmap().Is4() {
+			v4 = append(v4, ip)
+		} else {
+			v6 = append(v6, ip)
+		}
+	}
+
+	first, second := v6, v4
+	if len(ips) > 0 && ips[0].Unmap().Is4() {
+		first, second = v4, v6
+	}
+
+	out := make([]netip.Addr, 0, len(ips))
+	for i := 0; i < max(len(first), len(second)); i++ {
+		if i < len(first) {
+			out = append(out, first[i])
+		}
+		if i < len(second) {
+			out = append(out, second[i])
+		}
+	}
+
+	return out
+}
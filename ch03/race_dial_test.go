@@ -0,0 +1,252 @@
+package ch03
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/netip"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// 연결을 받아 상대가 닫을 때까지 읽는다. 받은 연결의 수와 닫힌 연결을 알려 준다.
+func raceListener(t *testing.T) (string, *atomic.Int64, <-chan error) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	accepted := new(atomic.Int64)
+	closed := make(chan error, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			accepted.Add(1)
+			go func() {
+				_, err := io.Copy(io.Discard, conn)
+				_ = conn.Close()
+				closed <- err
+			}()
+		}
+	}()
+
+	return listener.Addr().String(), accepted, closed
+}
+
+// 연결을 거부하는 주소
+func refusedAddr(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	_ = listener.Close()
+
+	return addr
+}
+
+func TestRaceDialerStaggered(t *testing.T) {
+	slow, _, _ := raceListener(t)
+	fast, _, _ := raceListener(t)
+
+	// 첫 번째 주소는 취소될 때까지 연결하지 않는다.
+	canceled := make(chan error, 1)
+	d := &RaceDialer{Delay: 50 * time.Millisecond}
+	d.Dialer.ControlContext = func(ctx context.Context, _, address string, _ syscall.RawConn) error {
+		if address != slow {
+			return nil
+		}
+		<-ctx.Done()
+		canceled <- ctx.Err()
+		return ctx.Err()
+	}
+
+	conn, attempt, err := d.RaceAddrs(context.Background(), "tcp", []string{slow, fast})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if attempt.Index != 1 || attempt.Address != fast {
+		t.Errorf("expected attempt 1 to %s to win; actual %+v", fast, attempt)
+	}
+	if attempt.Started < d.Delay {
+		t.Errorf("expected second attempt to start after %s; actual %s", d.Delay, attempt.Started)
+	}
+	if conn.RemoteAddr().String() != fast {
+		t.Errorf("expected conn to %s; actual %s", fast, conn.RemoteAddr())
+	}
+
+	select {
+	case err := <-canceled:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected losing attempt to be canceled; actual %v", err)
+		}
+	default:
+		t.Error("expected losing attempt to finish before RaceAddrs returns")
+	}
+}
+
+// 앞선 시도가 실패하면 간격을 기다리지 않는다.
+func TestRaceDialerFailureStartsNext(t *testing.T) {
+	addr, _, _ := raceListener(t)
+	d := &RaceDialer{Delay: time.Minute}
+
+	conn, attempt, err := d.RaceAddrs(context.Background(), "tcp", []string{refusedAddr(t), addr})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.Close()
+
+	if attempt.Index != 1 || attempt.Started > time.Second {
+		t.Errorf("expected attempt 1 to start right after the failure; actual %+v", attempt)
+	}
+}
+
+// 이긴 연결 외에 연결된 것은 닫는다.
+func TestRaceDialerClosesLosers(t *testing.T) {
+	addr, accepted, closed := raceListener(t)
+	d := &RaceDialer{Delay: time.Nanosecond}
+
+	conn, _, err := d.RaceAddrs(context.Background(), "tcp", []string{addr, addr, addr, addr})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	losers := 0
+	timeout := time.After(200 * time.Millisecond)
+wait:
+	for {
+		select {
+		case <-closed:
+			losers++
+		case <-timeout:
+			break wait
+		}
+	}
+	if expected := int(accepted.Load()) - 1; losers != expected {
+		t.Errorf("expected %d losing connections to be closed; actual %d", expected, losers)
+	}
+
+	// 이긴 연결은 열려 있다.
+	_, err = conn.Write([]byte("x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRaceDialerAllFail(t *testing.T) {
+	d := &RaceDialer{Delay: time.Millisecond}
+	addrs := []string{refusedAddr(t), refusedAddr(t)}
+
+	_, _, err := d.RaceAddrs(context.Background(), "tcp", addrs)
+	if !errors.Is(err, syscall.ECONNREFUSED) {
+		t.Errorf("expected connection refused; actual %v", err)
+	}
+
+	_, _, err = d.RaceAddrs(context.Background(), "tcp", nil)
+	if err == nil {
+		t.Error("expected error for no addresses")
+	}
+}
+
+func TestRaceDialerContext(t *testing.T) {
+	addr, _, _ := raceListener(t)
+	d := &RaceDialer{}
+	d.Dialer.ControlContext = func(ctx context.Context, _, _ string, _ syscall.RawConn) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, _, err := d.RaceAddrs(ctx, "tcp", []string{addr, addr})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded; actual %v", err)
+	}
+}
+
+func TestRaceDialerHostname(t *testing.T) {
+	addr, _, _ := raceListener(t)
+	_, port, _ := net.SplitHostPort(addr)
+
+	// localhost가 ::1을 먼저 돌려주더라도 127.0.0.1로 연결된다.
+	d := &RaceDialer{Delay: 10 * time.Millisecond}
+	conn, err := d.DialContext(context.Background(), "tcp", net.JoinHostPort("localhost", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.Close()
+
+	if conn.RemoteAddr().String() != addr {
+		t.Errorf("expected conn to %s; actual %s", addr, conn.RemoteAddr())
+	}
+}
+
+// 경쟁할 주소가 없는 네트워크와 주소는 net.Dialer로 그대로 연결한다.
+func TestRaceDialerPassThrough(t *testing.T) {
+	addr, _, _ := raceListener(t)
+	_, port, _ := net.SplitHostPort(addr)
+
+	socket := filepath.Join(t.TempDir(), "race.sock")
+	unix, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unix.Close()
+	go func() {
+		for {
+			conn, err := unix.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.Close()
+		}
+	}()
+
+	d := new(RaceDialer)
+	for _, target := range []struct{ network, address string }{
+		{"tcp", ":" + port},
+		{"unix", socket},
+	} {
+		conn, err := d.DialContext(context.Background(), target.network, target.address)
+		if err != nil {
+			t.Errorf("%s %s: %v", target.network, target.address, err)
+			continue
+		}
+		_ = conn.Close()
+	}
+}
+
+func TestInterleave(t *testing.T) {
+	v4a, v4b := netip.MustParseAddr("192.0.2.1"), netip.MustParseAddr("192.0.2.2")
+	v6a, v6b := netip.MustParseAddr("2001:db8::1"), netip.MustParseAddr("2001:db8::2")
+
+	testCases := []struct {
+		in, expected []netip.Addr
+	}{
+		{[]netip.Addr{v6a, v6b, v4a, v4b}, []netip.Addr{v6a, v4a, v6b, v4b}},
+		{[]netip.Addr{v4a, v4b, v6a}, []netip.Addr{v4a, v6a, v4b}},
+		{[]netip.Addr{v6a, v6b}, []netip.Addr{v6a, v6b}},
+	}
+	for _, c := range testCases {
+		actual := interleave(c.in)
+		if !reflect.DeepEqual(actual, c.expected) {
+			t.Errorf("interleave(%v): expected %v; actual %v", c.in, c.expected, actual)
+		}
+	}
+}
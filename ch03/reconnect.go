@@ -0,0 +1,369 @@
+package ch03
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_RECONNECT_MIN_DELAY = 100 * time.Millisecond
+	DEFAULT_RECONNECT_MAX_DELAY = 30 * time.Second
+	DEFAULT_HANDSHAKE_TIMEOUT   = 10 * time.Second
+)
+
+// 연결이 끊기면 다시 연결하는 클라이언트 연결. 끊긴 동안에도 같은 값을 계속 사용할 수 있다.
+// Read와 Write는 끊긴 연결의 오류를 그대로 반환하고 다음 호출에서 다시 연결한다.
+// 다시 연결하는 동안에도 기한이 지나면 os.ErrDeadlineExceeded를 반환한다. 연결은 계속 시도한다.
+// 보내던 데이터는 다시 보내지 않는다.
+// 다시 연결할 때는 MinDelay부터 두 배씩 늘어나는 시간에 임의의 시간을 더해 기다린다.
+type ReconnectingConn struct {
+	// 새 연결을 만든다.
+	Dial func(ctx context.Context) (net.Conn, error)
+	// 연결할 때마다 다른 데이터보다 먼저 호출한다. 실패하면 연결을 닫고 다시 연결한다.
+	Handshake func(ctx context.Context, conn net.Conn) error
+	// Handshake를 마쳐야 하는 시간. ctx와 연결의 기한에 모두 적용한다. 0 이하면 DEFAULT_HANDSHAKE_TIMEOUT
+	HandshakeTimeout time.Duration
+	// 0 이하면 DEFAULT_RECONNECT_MIN_DELAY
+	MinDelay time.Duration
+	// 0 이하면 DEFAULT_RECONNECT_MAX_DELAY
+	MaxDelay time.Duration
+	// 연결하고 Handshake까지 마친 후에 호출한다.
+	OnConnect func(conn net.Conn)
+	// 연결이 끊기면 원인과 함께 호출한다.
+	OnDisconnect func(err error)
+
+	mu     sync.Mutex
+	ctx    context.Context
+	cancel context.CancelFunc
+	closed bool
+	conn   net.Conn
+	// 연결할 때마다 늘어난다. 같은 연결이 여러 번 끊긴 것으로 처리되지 않게 한다.
+	gen uint64
+	// 다시 연결하는 중이면 nil이 아니다.
+	connecting *reconnect
+	// 새 연결에도 적용한다.
+	readDeadline  time.Time
+	writeDeadline time.Time
+	// 끊긴 동안에는 마지막 연결의 주소를 반환한다.
+	localAddr  net.Addr
+	remoteAddr net.Addr
+}
+
+// 처음 연결한다. ctx가 끝나면 더는 다시 연결하지 않는다.
+// 처음 연결에 실패하면 다시 Connect를 호출할 수 있다.
+func (r *ReconnectingConn) Connect(ctx context.Context) error {
+	if r.Dial == nil {
+		return errors.New("reconnecting conn: Dial is nil")
+	}
+
+	r.mu.Lock()
+	if r.ctx != nil {
+		r.mu.Unlock()
+		return errors.New("reconnecting conn: already connected")
+	}
+	r.ctx, r.cancel = context.WithCancel(ctx)
+	r.mu.Unlock()
+
+	// 처음 연결할 때는 ctx가 끝날 때까지 기다린다.
+	_, _, err := r.current(context.Background())
+	if err != nil {
+		r.mu.Lock()
+		if r.conn == nil && r.cancel != nil {
+			r.cancel()
+			r.ctx, r.cancel = nil, nil
+		}
+		r.mu.Unlock()
+	}
+
+	return err
+}
+
+// 진행 중인 다시 연결하기. done이 닫히면 err를 읽을 수 있다.
+type reconnect struct {
+	done chan struct{}
+	err  error
+}
+
+// 현재 연결을 반환한다. 끊겼으면 다시 연결하고 ctx가 끝날 때까지 기다린다.
+// ctx가 끝나도 다시 연결하기는 멈추지 않는다.
+func (r *ReconnectingConn) current(ctx context.Context) (net.Conn, uint64, error) {
+	for {
+		r.mu.Lock()
+		switch {
+		case r.closed:
+			r.mu.Unlock()
+			return nil, 0, net.ErrClosed
+		case r.ctx == nil:
+			r.mu.Unlock()
+			return nil, 0, errors.New("reconnecting conn: not connected")
+		case r.conn != nil:
+			conn, gen := r.conn, r.gen
+			r.mu.Unlock()
+			return conn, gen, nil
+		}
+
+		a := r.connecting
+		if a == nil {
+			a = &reconnect{done: make(chan struct{})}
+			r.connecting = a
+			go r.reconnect(r.ctx, a)
+		}
+		r.mu.Unlock()
+
+		select {
+		case <-a.done:
+			if a.err != nil {
+				return nil, 0, a.err
+			}
+		case <-ctx.Done():
+			return nil, 0, os.ErrDeadlineExceeded
+		}
+	}
+}
+
+// 연결을 마치거나 ctx가 끝나면 a.done을 닫는다.
+func (r *ReconnectingConn) reconnect(ctx context.Context, a *reconnect) {
+	defer close(a.done)
+
+	conn, err := r.redial(ctx)
+
+	r.mu.Lock()
+	r.connecting = nil
+	if err == nil && r.closed {
+		_ = conn.Close()
+		err = net.ErrClosed
+	}
+	if err != nil {
+		if r.closed {
+			err = net.ErrClosed
+		}
+		a.err = err
+		r.mu.Unlock()
+		return
+	}
+	r.conn = conn
+	r.gen++
+	r.localAddr, r.remoteAddr = conn.LocalAddr(), conn.RemoteAddr()
+	_ = conn.SetReadDeadline(r.readDeadline)
+	_ = conn.SetWriteDeadline(r.writeDeadline)
+	r.mu.Unlock()
+
+	if r.OnConnect != nil {
+		r.OnConnect(conn)
+	}
+}
+
+// 연결하고 Handshake를 마칠 때까지 시도한다.
+func (r *ReconnectingConn) redial(ctx context.Context) (net.Conn, error) {
+	var delay time.Duration
+
+	for {
+		conn, err := r.Dial(ctx)
+		if err == nil && r.Handshake != nil {
+			err = r.handshake(ctx, conn)
+			if err != nil {
+				_ = conn.Close()
+				err = fmt.Errorf("handshake: %w", err)
+			}
+		}
+		if err == nil {
+			return conn, nil
+		}
+
+		if delay == 0 {
+			delay = min(r.minDelay(), r.maxDelay())
+		} else {
+			delay = grow(delay, 2, r.maxDelay())
+		}
+
+		timer := time.NewTimer(delay/2 + rand.N(delay/2+1))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("reconnect: %w", errors.Join(ctx.Err(), err))
+		case <-timer.C:
+		}
+	}
+}
+
+// 응답하지 않는 상대 때문에 다시 연결하기가 멈추지 않도록 시간을 제한한다.
+func (r *ReconnectingConn) handshake(ctx context.Context, conn net.Conn) error {
+	timeout := r.HandshakeTimeout
+	if timeout <= 0 {
+		timeout = DEFAULT_HANDSHAKE_TIMEOUT
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// 연결을 넘겨줄 때 사용자가 정한 기한으로 바꾼다.
+	err := conn.SetDeadline(time.Now().Add(timeout))
+	if err != nil {
+		return err
+	}
+
+	return r.Handshake(ctx, conn)
+}
+
+func (r *ReconnectingConn) minDelay() time.Duration {
+	if r.MinDelay <= 0 {
+		return DEFAULT_RECONNECT_MIN_DELAY
+	}
+
+	return r.MinDelay
+}
+
+func (r *ReconnectingConn) maxDelay() time.Duration {
+	if r.MaxDelay <= 0 {
+		return DEFAULT_RECONNECT_MAX_DELAY
+	}
+
+	return r.MaxDelay
+}
+
+// 연결이 gen번째 연결 그대로면 닫고 끊긴 것으로 처리한다.
+func (r *ReconnectingConn) drop(gen uint64, err error) {
+	// 기한이 지난 것은 연결이 끊긴 것이 아니다.
+	var nErr net.Error
+	if errors.As(err, &nErr) && nErr.Timeout() {
+		return
+	}
+
+	r.mu.Lock()
+	if r.closed || r.gen != gen || r.conn == nil {
+		r.mu.Unlock()
+		return
+	}
+	conn := r.conn
+	r.conn = nil
+	r.mu.Unlock()
+
+	_ = conn.Close()
+	if r.OnDisconnect != nil {
+		r.OnDisconnect(err)
+	}
+}
+
+// 다시 연결하기를 기다리는 동안 t가 지나면 끝나는 ctx
+func deadlineContext(t time.Time) (context.Context, context.CancelFunc) {
+	if t.IsZero() {
+		return context.Background(), func() {}
+	}
+
+	return context.WithDeadline(context.Background(), t)
+}
+
+func (r *ReconnectingConn) Read(p []byte) (int, error) {
+	r.mu.Lock()
+	ctx, cancel := deadlineContext(r.readDeadline)
+	r.mu.Unlock()
+	defer cancel()
+
+	conn, gen, err := r.current(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := conn.Read(p)
+	if err != nil {
+		r.drop(gen, err)
+	}
+
+	return n, err
+}
+
+func (r *ReconnectingConn) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	ctx, cancel := deadlineContext(r.writeDeadline)
+	r.mu.Unlock()
+	defer cancel()
+
+	conn, gen, err := r.current(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := conn.Write(p)
+	if err != nil {
+		r.drop(gen, err)
+	}
+
+	return n, err
+}
+
+// 연결을 닫고 더는 다시 연결하지 않는다.
+func (r *ReconnectingConn) Close() error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return net.ErrClosed
+	}
+	r.closed = true
+	if r.cancel != nil {
+		r.cancel()
+	}
+	conn := r.conn
+	r.conn = nil
+	r.mu.Unlock()
+
+	if conn != nil {
+		return conn.Close()
+	}
+
+	return nil
+}
+
+// 끊긴 동안에는 마지막으로 연결했던 주소를 반환한다. 연결한 적이 없으면 nil이다.
+func (r *ReconnectingConn) LocalAddr() net.Addr {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.localAddr
+}
+
+// 끊긴 동안에는 마지막으로 연결했던 주소를 반환한다. 연결한 적이 없으면 nil이다.
+func (r *ReconnectingConn) RemoteAddr() net.Addr {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.remoteAddr
+}
+
+func (r *ReconnectingConn) SetDeadline(t time.Time) error {
+	err := r.SetReadDeadline(t)
+	if err != nil {
+		return err
+	}
+
+	return r.SetWriteDeadline(t)
+}
+
+func (r *ReconnectingConn) SetReadDeadline(t time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.readDeadline = t
+	if r.conn != nil {
+		return r.conn.SetReadDeadline(t)
+	}
+
+	return nil
+}
+
+func (r *ReconnectingConn) SetWriteDeadline(t time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.writeDeadline = t
+	if r.conn != nil {
+		return r.conn.SetWriteDeadline(t)
+	}
+
+	return nil
+}
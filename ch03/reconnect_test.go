@@ -0,0 +1,386 @@
+package ch03
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 연결마다 첫 줄을 handshakes로 보내고 나머지는 그대로 돌려준다.
+func echoServer(t *testing.T) (string, <-chan string, <-chan net.Conn) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	handshakes := make(chan string, 10)
+	conns := make(chan net.Conn, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conns <- conn
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				handshakes <- line
+				_, _ = io.Copy(conn, r)
+			}()
+		}
+	}()
+
+	return listener.Addr().String(), handshakes, conns
+}
+
+func TestReconnectingConn(t *testing.T) {
+	addr, handshakes, conns := echoServer(t)
+
+	var connects, disconnects atomic.Int64
+	r := &ReconnectingConn{
+		Dial: func(ctx context.Context) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "tcp", addr)
+		},
+		Handshake: func(_ context.Context, conn net.Conn) error {
+			_, err := conn.Write([]byte("hello\n"))
+			return err
+		},
+		MinDelay:     time.Millisecond,
+		OnConnect:    func(net.Conn) { connects.Add(1) },
+		OnDisconnect: func(error) { disconnects.Add(1) },
+	}
+	defer r.Close()
+
+	err := r.Connect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	echo := func() error {
+		_, err := r.Write([]byte("ping"))
+		if err != nil {
+			return err
+		}
+		buf := make([]byte, 4)
+		_, err = io.ReadFull(r, buf)
+		if err == nil && string(buf) != "ping" {
+			t.Errorf("expected %q; actual %q", "ping", buf)
+		}
+		return err
+	}
+
+	if err := echo(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 서버가 연결을 끊으면 끊긴 연결의 오류를 받는다.
+	_ = (<-conns).Close()
+	_, err = r.Read(make([]byte, 1))
+	if err == nil {
+		t.Fatal("expected error from closed connection")
+	}
+
+	// 다음 호출에서 다시 연결하고 핸드셰이크를 다시 보낸다.
+	if err := echo(); err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		if line := <-handshakes; line != "hello\n" {
+			t.Errorf("expected handshake; actual %q", line)
+		}
+	}
+	if connects.Load() != 2 || disconnects.Load() != 1 {
+		t.Errorf("expected 2 connects and 1 disconnect; actual %d and %d", connects.Load(), disconnects.Load())
+	}
+}
+
+func TestReconnectingConnBackoff(t *testing.T) {
+	addr, _, _ := echoServer(t)
+
+	var attempts []time.Time
+	r := &ReconnectingConn{
+		Dial: func(ctx context.Context) (net.Conn, error) {
+			attempts = append(attempts, time.Now())
+			if len(attempts) < 4 {
+				return nil, errors.New("unavailable")
+			}
+			var d net.Dialer
+			return d.DialContext(ctx, "tcp", addr)
+		},
+		MinDelay: 20 * time.Millisecond,
+		MaxDelay: 50 * time.Millisecond,
+	}
+	defer r.Close()
+
+	err := r.Connect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 20ms, 40ms, 50ms에 임의의 시간을 더해 절반에서 전체 사이를 기다린다.
+	expected := []time.Duration{20, 40, 50}
+	for i, e := range expected {
+		e *= time.Millisecond
+		waited := attempts[i+1].Sub(attempts[i])
+		if waited < e/2 || waited > e+30*time.Millisecond {
+			t.Errorf("attempt %d: expected to wait between %s and %s; actual %s", i+1, e/2, e, waited)
+		}
+	}
+}
+
+func TestReconnectingConnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &ReconnectingConn{
+		Dial: func(context.Context) (net.Conn, error) {
+			return nil, errors.New("unavailable")
+		},
+		MinDelay: time.Hour,
+	}
+
+	time.AfterFunc(20*time.Millisecond, cancel)
+	err := r.Connect(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context canceled; actual %v", err)
+	}
+
+	_ = r.Close()
+	_, err = r.Write([]byte("x"))
+	if !errors.Is(err, net.ErrClosed) {
+		t.Errorf("expected net.ErrClosed after Close; actual %v", err)
+	}
+}
+
+// 핸드셰이크에 실패한 연결은 사용하지 않는다.
+func TestReconnectingConnHandshakeRetry(t *testing.T) {
+	addr, handshakes, _ := echoServer(t)
+
+	failures := 2
+	r := &ReconnectingConn{
+		Dial: func(ctx context.Context) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "tcp", addr)
+		},
+		Handshake: func(_ context.Context, conn net.Conn) error {
+			if failures > 0 {
+				failures--
+				return errors.New("rejected")
+			}
+			_, err := conn.Write([]byte("hello\n"))
+			return err
+		},
+		MinDelay: time.Millisecond,
+	}
+	defer r.Close()
+
+	err := r.Connect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line := <-handshakes; line != "hello\n" {
+		t.Errorf("expected handshake; actual %q", line)
+	}
+	if failures != 0 {
+		t.Errorf("expected handshake to be retried")
+	}
+}
+
+// 읽기 기한이 지나도 연결을 끊지 않는다.
+func TestReconnectingConnDeadline(t *testing.T) {
+	addr, _, _ := echoServer(t)
+
+	var disconnects atomic.Int64
+	r := &ReconnectingConn{
+		Dial: func(ctx context.Context) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "tcp", addr)
+		},
+		OnDisconnect: func(error) { disconnects.Add(1) },
+	}
+	defer r.Close()
+
+	err := r.Connect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_ = r.SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+	_, err = r.Read(make([]byte, 1))
+	var nErr net.Error
+	if !errors.As(err, &nErr) || !nErr.Timeout() {
+		t.Fatalf("expected timeout; actual %v", err)
+	}
+	if disconnects.Load() != 0 {
+		t.Error("expected timeout not to disconnect")
+	}
+}
+
+// 다시 연결하는 동안에도 기한을 지키고 기한이 지나도 계속 연결을 시도한다.
+func TestReconnectingConnDeadlineWhileReconnecting(t *testing.T) {
+	addr, _, conns := echoServer(t)
+
+	var down atomic.Bool
+	r := &ReconnectingConn{
+		Dial: func(ctx context.Context) (net.Conn, error) {
+			if down.Load() {
+				return nil, errors.New("unavailable")
+			}
+			var d net.Dialer
+			return d.DialContext(ctx, "tcp", addr)
+		},
+		Handshake: func(_ context.Context, conn net.Conn) error {
+			_, err := conn.Write([]byte("hello\n"))
+			return err
+		},
+		MinDelay: 5 * time.Millisecond,
+		MaxDelay: 10 * time.Millisecond,
+	}
+	defer r.Close()
+
+	err := r.Connect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	down.Store(true)
+	_ = (<-conns).Close()
+	_, err = r.Read(make([]byte, 1))
+	if err == nil {
+		t.Fatal("expected error from closed connection")
+	}
+
+	for _, op := range []struct {
+		name string
+		set  func(time.Time) error
+		do   func([]byte) (int, error)
+	}{
+		{"read", r.SetReadDeadline, r.Read},
+		{"write", r.SetWriteDeadline, r.Write},
+	} {
+		_ = op.set(time.Now().Add(30 * time.Millisecond))
+		start := time.Now()
+		_, err = op.do([]byte("x"))
+		if !errors.Is(err, os.ErrDeadlineExceeded) {
+			t.Errorf("%s: expected deadline exceeded; actual %v", op.name, err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("%s: expected to return at the deadline; returned after %s", op.name, elapsed)
+		}
+		_ = op.set(time.Time{})
+	}
+
+	// 기한이 지나도 다시 연결하기는 계속된다.
+	down.Store(false)
+	_, err = r.Write([]byte("ping"))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 처음 연결에 실패해도 다시 Connect할 수 있다.
+func TestReconnectingConnRetryConnect(t *testing.T) {
+	addr, _, _ := echoServer(t)
+
+	var up atomic.Bool
+	r := &ReconnectingConn{
+		Dial: func(ctx context.Context) (net.Conn, error) {
+			if !up.Load() {
+				return nil, errors.New("unavailable")
+			}
+			var d net.Dialer
+			return d.DialContext(ctx, "tcp", addr)
+		},
+		MinDelay: time.Millisecond,
+	}
+	defer r.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := r.Connect(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded; actual %v", err)
+	}
+
+	up.Store(true)
+	err = r.Connect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 핸드셰이크에 답하지 않는 상대 때문에 다시 연결하기가 멈추지 않는다.
+func TestReconnectingConnHandshakeTimeout(t *testing.T) {
+	addr, _, _ := echoServer(t)
+
+	var attempts atomic.Int64
+	r := &ReconnectingConn{
+		Dial: func(ctx context.Context) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "tcp", addr)
+		},
+		// 서버는 첫 줄을 받기 전에는 아무것도 보내지 않는다.
+		Handshake: func(ctx context.Context, conn net.Conn) error {
+			if attempts.Add(1) < 3 {
+				_, err := conn.Read(make([]byte, 1))
+				return err
+			}
+			_, err := conn.Write([]byte("hello\n"))
+			return err
+		},
+		HandshakeTimeout: 20 * time.Millisecond,
+		MinDelay:         time.Millisecond,
+	}
+	defer r.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	err := r.Connect(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempts.Load() != 3 {
+		t.Errorf("expected 3 handshake attempts; actual %d", attempts.Load())
+	}
+}
+
+// 끊긴 동안에도 마지막 연결의 주소를 반환한다.
+func TestReconnectingConnAddr(t *testing.T) {
+	addr, _, conns := echoServer(t)
+
+	r := &ReconnectingConn{
+		Dial: func(ctx context.Context) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "tcp", addr)
+		},
+		MinDelay: time.Hour,
+	}
+	defer r.Close()
+
+	err := r.Connect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_ = (<-conns).Close()
+	_, err = r.Read(make([]byte, 1))
+	if err == nil {
+		t.Fatal("expected error from closed connection")
+	}
+	if r.RemoteAddr() == nil || r.RemoteAddr().String() != addr || r.LocalAddr() == nil {
+		t.Errorf("expected last addresses while disconnected; actual %v and %v", r.LocalAddr(), r.RemoteAddr())
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/testaquatic/NetworkProgrammingWithGo/ch04/httpproxy"
+	"github.com/testaquatic/NetworkProgrammingWithGo/ch04/proxy"
+)
+
+var (
+	listen = flag.String("listen", "127.0.0.1:8080", "listen address")
+	realm  = flag.String("realm", "proxy", "realm for Proxy-Authenticate")
+	grace  = flag.Duration("grace", 30*time.Second, "time to wait for active connections on shutdown")
+	deny   = flag.Bool("deny", false, "deny destinations that match no rule")
+	users  = make(userFlag)
+	rules  ruleFlag
+)
+
+func init() {
+	flag.Var(users, "user", "user:password required to connect (repeatable)")
+	flag.Var(&rules, "rule", `destination rule such as "allow *.example.com:443" or "deny 10.0.0.0/8" (repeatable, first match wins)`)
+
+	flag.Usage = func() {
+		fmt.Printf("Usage: %s [options]\nOptions:\n", os.Args[0])
+		flag.PrintDefaults()
+		fmt.Println("\nSet HTTP_PROXY and HTTPS_PROXY to http://user:password@<listen> to use the proxy.")
+		fmt.Println("Go clients ignore these variables for localhost destinations.")
+	}
+}
+
+type userFlag map[string]string
+
+func (u userFlag) String() string {
+	names := make([]string, 0, len(u))
+	for name := range u {
+		names = append(names, name)
+	}
+
+	return strings.Join(names, ",")
+}
+
+func (u userFlag) Set(s string) error {
+	name, password, ok := strings.Cut(s, ":")
+	if !ok || name == "" {
+		return fmt.Errorf("expected user:password")
+	}
+	u[name] = password
+
+	return nil
+}
+
+type ruleFlag []proxy.Rule
+
+func (r *ruleFlag) String() string {
+	return fmt.Sprint(*r)
+}
+
+func (r *ruleFlag) Set(s string) error {
+	rule, err := proxy.ParseRule(s)
+	if err != nil {
+		return err
+	}
+	*r = append(*r, rule)
+
+	return nil
+}
+
+func main() {
+	flag.Parse()
+
+	if flag.NArg() != 0 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	p := &httpproxy.Proxy{
+		Users:       users,
+		Realm:       *realm,
+		Rules:       rules,
+		DefaultDeny: *deny,
+		OnClose:     logConn,
+	}
+
+	srv := &http.Server{
+		Addr:              *listen,
+		Handler:           p,
+		IdleTimeout:       time.Minute,
+		ReadHeaderTimeout: 30 * time.Second,
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		c := make(chan os.Signal, 1)
+		signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+
+		<-c
+		log.Printf("shutting down: waiting up to %s for active connections", *grace)
+
+		ctx, cancel := context.WithTimeout(context.Background(), *grace)
+		defer cancel()
+		// 두 번째 신호를 받으면 바로 종료한다.
+		go func() {
+			select {
+			case <-c:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		if err := srv.Shutdown(ctx); err != nil {
+			log.Printf("shutdown: %v", err)
+		}
+		if err := p.Shutdown(ctx); err != nil {
+			log.Printf("shutdown: %v", err)
+		}
+		_ = srv.Close()
+	}()
+
+	log.Printf("HTTP proxy listening on %s", srv.Addr)
+
+	err := srv.ListenAndServe()
+	if !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
+
+	<-done
+}
+
+func logConn(c proxy.ConnStats) {
+	if c.Err != nil {
+		log.Printf("CONNECT %s -> %v: %v", c.Client, c.Target, c.Err)
+		return
+	}
+
+	log.Printf("CONNECT %s -> %s: sent %d bytes, received %d bytes in %s",
+		c.Client, c.Target, c.Sent, c.Received, c.Duration)
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/testaquatic/NetworkProgrammingWithGo/ch04/proxy"
+	"github.com/testaquatic/NetworkProgrammingWithGo/ch04/proxyproto"
+)
+
+var (
+	listen      = flag.String("listen", "127.0.0.1:8080", "listen address")
+	dialTimeout = flag.Duration("dial-timeout", 10*time.Second, "time to wait when connecting to the target")
+	grace       = flag.Duration("grace", 30*time.Second, "time to wait for active connections on shutdown")
+	strategy    = flag.String("strategy", "round-robin", "backend selection: round-robin, least-conn or two-choices")
+	health      = flag.Duration("health-interval", proxy.DEFAULT_HEALTH_INTERVAL, "interval between backend health checks")
+	control     = flag.String("control", "", "listen address for the fault injection control API")
+	latency     = flag.Duration("latency", 0, "latency to add in both directions")
+	jitter      = flag.Duration("jitter", 0, "random variation added to the latency")
+	bandwidth   = flag.Int64("bandwidth", 0, "bytes per second in each direction: 0 means unlimited")
+	record      = flag.String("record", "", "directory to record sessions into")
+	sendProxy   = flag.Uint("send-proxy", 0, "PROXY protocol version to send to the target: 0 disables")
+	acceptProxy = flag.Bool("accept-proxy", false, "read a PROXY protocol header from each client")
+)
+
+var strategies = map[string]uint8{
+	"round-robin": proxy.ROUND_ROBIN,
+	"least-conn":  proxy.LEAST_CONNECTIONS,
+	"two-choices": proxy.TWO_CHOICES,
+}
+
+func init() {
+	flag.Usage = func() {
+		fmt.Printf("Usage: %s [options] target [target ...]\n", os.Args[0])
+		fmt.Println("Connections are balanced across targets when more than one is given.")
+		fmt.Println("Options:")
+		flag.PrintDefaults()
+	}
+}
+
+func main() {
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	l, err := net.Listen("tcp", *listen)
+	if err != nil {
+		log.Fatalf("binding to tcp %s: %v", *listen, err)
+	}
+	if *acceptProxy {
+		l = &proxyproto.Listener{Listener: l}
+	}
+
+	if *sendProxy > uint(proxyproto.VERSION_2) {
+		log.Fatalf("unknown PROXY protocol version %d", *sendProxy)
+	}
+
+	s := &proxy.Server{
+		Target:        strings.Join(flag.Args(), ","),
+		DialTimeout:   *dialTimeout,
+		OnClose:       logConn,
+		ProxyProtocol: uint8(*sendProxy),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	if flag.NArg() > 1 {
+		st, ok := strategies[*strategy]
+		if !ok {
+			log.Fatalf("unknown strategy %q", *strategy)
+		}
+
+		b, err := proxy.NewBalancer(st, flag.Args()...)
+		if err != nil {
+			log.Fatal(err)
+		}
+		b.HealthInterval = *health
+		b.OnHealthChange = func(b *proxy.Backend) {
+			if b.Healthy() {
+				log.Printf("backend %s is up", b.Address())
+			} else {
+				log.Printf("backend %s is down", b.Address())
+			}
+		}
+
+		// 종료하면 새 연결을 만들지 않으므로 상태 검사도 멈춘다.
+		go b.HealthCheck(ctx)
+		s.Dial = b.Dial
+	}
+
+	if *control != "" || *latency > 0 || *jitter > 0 || *bandwidth > 0 {
+		s.Faults = new(proxy.FaultInjector)
+		faults := proxy.Faults{Latency: *latency, Jitter: *jitter, Bandwidth: *bandwidth}
+		s.Faults.Set(proxy.UPSTREAM, faults)
+		s.Faults.Set(proxy.DOWNSTREAM, faults)
+	}
+
+	if *record != "" {
+		err := os.MkdirAll(*record, 0o755)
+		if err != nil {
+			log.Fatal(err)
+		}
+		s.Recorder = &proxy.Recorder{Dir: *record}
+	}
+
+	if *control != "" {
+		cl, err := net.Listen("tcp", *control)
+		if err != nil {
+			log.Fatalf("binding to tcp %s: %v", *control, err)
+		}
+		log.Printf("fault control API on http://%s/", cl.Addr())
+
+		go func() {
+			err := http.Serve(cl, s.Faults)
+			if err != nil {
+				log.Print(err)
+			}
+		}()
+	}
+
+	go func() {
+		c := make(chan os.Signal, 1)
+		signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+
+		<-c
+		log.Printf("shutting down: waiting up to %s for active connections", *grace)
+		cancel()
+
+		// 유예 시간이 지나거나 신호를 다시 받으면 연결을 끊는다.
+		select {
+		case <-c:
+		case <-time.After(*grace):
+		}
+		_ = s.Close()
+	}()
+
+	log.Printf("proxying %s -> %s", l.Addr(), s.Target)
+
+	err = s.Serve(ctx, l)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func logConn(c proxy.ConnStats) {
+	if c.Err != nil {
+		log.Printf("%s: sent %d bytes, received %d bytes in %s: %v",
+			c.Client, c.Sent, c.Received, c.Duration, c.Err)
+		return
+	}
+
+	log.Printf("%s -> %s: sent %d bytes, received %d bytes in %s",
+		c.Client, c.Target, c.Sent, c.Received, c.Duration)
+}
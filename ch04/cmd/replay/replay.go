@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"time"
+
+	"github.com/testaquatic/NetworkProgrammingWithGo/ch04/proxy"
+)
+
+var (
+	connect  = flag.String("connect", "", "replay the client side against this server address")
+	listen   = flag.String("listen", "", "replay the server side to one client accepted on this address")
+	realtime = flag.Bool("realtime", false, "send with the original timing instead of as fast as possible")
+	timeout  = flag.Duration("timeout", proxy.DEFAULT_REPLAY_TIMEOUT, "time to wait for data from the peer")
+)
+
+func init() {
+	flag.Usage = func() {
+		fmt.Printf("Usage: %s [options] (-connect addr | -listen addr) file\n", os.Args[0])
+		fmt.Println("Replays a session recorded by the proxy and compares the peer's data with the recording.")
+		fmt.Println("Options:")
+		flag.PrintDefaults()
+	}
+}
+
+func main() {
+	flag.Parse()
+
+	if flag.NArg() != 1 || (*connect == "") == (*listen == "") {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	f, err := os.Open(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+
+	rec, err := proxy.NewRecordReader(f)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	r := &proxy.Replayer{Realtime: *realtime, Timeout: *timeout}
+
+	var conn net.Conn
+	if *connect != "" {
+		r.Role = proxy.REPLAY_CLIENT
+		conn, err = net.Dial("tcp", *connect)
+	} else {
+		r.Role = proxy.REPLAY_SERVER
+		conn, err = accept(*listen)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer conn.Close()
+
+	log.Printf("replaying session recorded at %s with %s", rec.Start().Format(time.RFC3339), conn.RemoteAddr())
+
+	start := time.Now()
+	err = r.Replay(conn, rec)
+	if err != nil {
+		var mErr *proxy.MismatchError
+		if errors.As(err, &mErr) {
+			fmt.Fprintln(os.Stderr, mErr)
+			os.Exit(1)
+		}
+		log.Fatal(err)
+	}
+
+	log.Printf("session matched in %s", time.Since(start))
+}
+
+func accept(addr string) (net.Conn, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("binding to tcp %s: %w", addr, err)
+	}
+	defer l.Close()
+
+	log.Printf("waiting for a client on %s", l.Addr())
+
+	return l.Accept()
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/testaquatic/NetworkProgrammingWithGo/ch04/proxy"
+	"github.com/testaquatic/NetworkProgrammingWithGo/ch04/socks5"
+)
+
+var (
+	listen      = flag.String("listen", "127.0.0.1:1080", "listen address")
+	defaultDeny = flag.Bool("deny", false, "deny destinations that match no rule")
+	grace       = flag.Duration("grace", 30*time.Second, "time to wait for active connections on shutdown")
+	users       = make(userFlag)
+	rules       ruleFlag
+)
+
+func init() {
+	flag.Var(users, "user", "user:password required to connect (repeatable)")
+	flag.Var(&rules, "rule", `destination rule such as "allow *.example.com:443" or "deny 10.0.0.0/8" (repeatable, first match wins)`)
+
+	flag.Usage = func() {
+		fmt.Printf("Usage: %s [options]\nOptions:\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+}
+
+type userFlag map[string]string
+
+func (u userFlag) String() string {
+	names := make([]string, 0, len(u))
+	for name := range u {
+		names = append(names, name)
+	}
+
+	return strings.Join(names, ",")
+}
+
+func (u userFlag) Set(s string) error {
+	name, password, ok := strings.Cut(s, ":")
+	if !ok || name == "" {
+		return fmt.Errorf("expected user:password")
+	}
+	u[name] = password
+
+	return nil
+}
+
+type ruleFlag []proxy.Rule
+
+func (r *ruleFlag) String() string {
+	return fmt.Sprint(*r)
+}
+
+func (r *ruleFlag) Set(s string) error {
+	rule, err := proxy.ParseRule(s)
+	if err != nil {
+		return err
+	}
+	*r = append(*r, rule)
+
+	return nil
+}
+
+func main() {
+	flag.Parse()
+
+	if flag.NArg() != 0 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	l, err := net.Listen("tcp", *listen)
+	if err != nil {
+		log.Fatalf("binding to tcp %s: %v", *listen, err)
+	}
+
+	s := &socks5.Server{
+		Users:       users,
+		Rules:       rules,
+		DefaultDeny: *defaultDeny,
+		OnClose:     logConn,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		c := make(chan os.Signal, 1)
+		signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+
+		<-c
+		log.Printf("shutting down: waiting up to %s for active connections", *grace)
+		cancel()
+
+		select {
+		case <-c:
+		case <-time.After(*grace):
+		}
+		_ = s.Close()
+	}()
+
+	log.Printf("SOCKS5 server listening on %s", l.Addr())
+
+	err = s.Serve(ctx, l)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func logConn(c proxy.ConnStats) {
+	if c.Err != nil {
+		log.Printf("%s -> %v: %v", c.Client, c.Target, c.Err)
+		return
+	}
+
+	log.Printf("%s -> %s: sent %d bytes, received %d bytes in %s",
+		c.Client, c.Target, c.Sent, c.Received, c.Duration)
+}
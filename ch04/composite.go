@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+// 페이로드는 요소들을 순서대로 인코딩한 것이다.
+// | Type(1B) | Size(4B) | Payload | Payload | ... |
+type List []Payload
+
+// 인코딩할 수 없는 요소가 있으면 nil을 반환한다.
+func (m List) Bytes() []byte {
+	body, _ := m.body()
+
+	return body
+}
+
+func (m List) String() string {
+	s := make([]string, 0, len(m))
+	for _, p := range m {
+		s = append(s, payloadString(p))
+	}
+
+	return "[" + strings.Join(s, " ") + "]"
+}
+
+func (m List) body() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	for i, p := range m {
+		if p == nil {
+			return nil, fmt.Errorf("invalid List: nil element %d", i)
+		}
+		_, err := p.WriteTo(buf)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (m List) WriteTo(w io.Writer) (int64, error) {
+	body, err := m.body()
+	if err != nil {
+		return 0, err
+	}
+
+	return writeComposite(w, LIST_TYPE, body)
+}
+
+func (m *List) ReadFrom(r io.Reader) (int64, error) {
+	body, n, err := readComposite(r, LIST_TYPE, "List")
+	if err != nil {
+		return n, err
+	}
+
+	list := List{}
+	br := bytes.NewReader(body)
+	d := nestedDecoder(r, br)
+	for br.Len() > 0 {
+		p, err := d.Decode()
+		if err != nil {
+			return n, fmt.Errorf("invalid List element %d: %w", len(list), err)
+		}
+		list = append(list, p)
+	}
+	*m = list
+
+	return n, nil
+}
+
+// 키는 String으로 인코딩하고 키 순서로 정렬한다.
+// | Type(1B) | Size(4B) | String | Payload | String | Payload | ... |
+type Map map[string]Payload
+
+func (m Map) keys() []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// 인코딩할 수 없는 값이 있으면 nil을 반환한다.
+func (m Map) Bytes() []byte {
+	body, _ := m.body()
+
+	return body
+}
+
+func (m Map) String() string {
+	s := make([]string, 0, len(m))
+	for _, k := range m.keys() {
+		s = append(s, k+":"+payloadString(m[k]))
+	}
+
+	return "map[" + strings.Join(s, " ") + "]"
+}
+
+func (m Map) body() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	for _, k := range m.keys() {
+		if m[k] == nil {
+			return nil, fmt.Errorf("invalid Map: nil value for key %q", k)
+		}
+
+		_, err := String(k).WriteTo(buf)
+		if err != nil {
+			return nil, err
+		}
+		_, err = m[k].WriteTo(buf)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (m Map) WriteTo(w io.Writer) (int64, error) {
+	body, err := m.body()
+	if err != nil {
+		return 0, err
+	}
+
+	return writeComposite(w, MAP_TYPE, body)
+}
+
+func (m *Map) ReadFrom(r io.Reader) (int64, error) {
+	body, n, err := readComposite(r, MAP_TYPE, "Map")
+	if err != nil {
+		return n, err
+	}
+
+	result := Map{}
+	br := bytes.NewReader(body)
+	d := nestedDecoder(r, br)
+	for br.Len() > 0 {
+		// 키도 값과 같이 Decoder의 제한을 확인한다.
+		p, err := d.Decode()
+		if err != nil {
+			return n, fmt.Errorf("invalid Map key: %w", err)
+		}
+		key, ok := p.(*String)
+		if !ok {
+			return n, fmt.Errorf("invalid Map key: expected String; got %T", p)
+		}
+
+		if _, ok := result[string(*key)]; ok {
+			return n, fmt.Errorf("invalid Map: duplicate key %q", *key)
+		}
+
+		value, err := d.Decode()
+		if err != nil {
+			return n, fmt.Errorf("invalid Map value for key %q: %w", *key, err)
+		}
+		result[string(*key)] = value
+	}
+	*m = result
+
+	return n, nil
+}
+
+func payloadString(p Payload) string {
+	if p == nil {
+		return "<nil>"
+	}
+
+	return p.String()
+}
+
+func writeComposite(w io.Writer, typ uint8, body []byte) (int64, error) {
+	if uint64(len(body)) > math.MaxUint32 {
+		return 0, ErrMaxPayloadSize
+	}
+
+	n, err := writeHeader(w, typ, uint32(len(body)))
+	if err != nil {
+		return n, err
+	}
+
+	o, err := w.Write(body)
+
+	return n + int64(o), err
+}
+
+// 페이로드 전체를 읽어 중첩된 요소를 디코딩할 수 있도록 반환한다.
+func readComposite(r io.Reader, typ uint8, name string) ([]byte, int64, error) {
+	size, n, err := readHeader(r, typ, name)
+	if err != nil {
+		return nil, n, err
+	}
+
+	body, o, err := readPayload(r, size)
+	n += int64(o)
+	if err != nil {
+		return nil, n, err
+	}
+
+	return body, n, nil
+}
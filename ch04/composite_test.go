@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestCompositePayloadsRoundTrip(t *testing.T) {
+	s := String("Errors are values.")
+	b := Binary("Don't panic.")
+	i := Int64(42)
+	ok := Bool(true)
+	inner := List{&i, &ok}
+	empty := List{}
+
+	list := List{&s, &b, &inner, &empty}
+	m := Map{
+		"name":  &s,
+		"list":  &inner,
+		"empty": &Map{},
+	}
+
+	for _, expected := range []Payload{&list, &m} {
+		buf := new(bytes.Buffer)
+		n, err := expected.WriteTo(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if int(n) != buf.Len() {
+			t.Errorf("[%T] wrote %d bytes; reported %d", expected, buf.Len(), n)
+		}
+
+		actual, err := decode(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(expected, actual) {
+			t.Errorf("value mismatch: %v != %v", expected, actual)
+		}
+		t.Logf("[%T] %v", actual, actual)
+	}
+}
+
+func TestMapEncodingIsSorted(t *testing.T) {
+	a, b := Int8(1), Int8(2)
+
+	first := Map{"b": &b, "a": &a}.Bytes()
+	second := Map{"a": &a, "b": &b}.Bytes()
+	if !bytes.Equal(first, second) {
+		t.Error("Map encoding depends on insertion order")
+	}
+}
+
+func TestMapDuplicateKey(t *testing.T) {
+	buf := new(bytes.Buffer)
+	for range 2 {
+		_, _ = String("key").WriteTo(buf)
+		_, _ = Bool(true).WriteTo(buf)
+	}
+
+	frame := new(bytes.Buffer)
+	_, err := writeComposite(frame, MAP_TYPE, buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = decode(frame)
+	if err == nil {
+		t.Fatal("expected error for duplicate Map key")
+	}
+}
+
+func TestCompositeNilElement(t *testing.T) {
+	i := Int8(1)
+	list := List{&i, nil}
+	m := Map{"a": &i, "b": nil}
+
+	for _, p := range []Payload{&list, &m} {
+		_, err := p.WriteTo(new(bytes.Buffer))
+		if err == nil {
+			t.Errorf("[%T] expected WriteTo error for nil element", p)
+		}
+		if b := p.Bytes(); b != nil {
+			t.Errorf("[%T] expected nil Bytes; actual %v", p, b)
+		}
+	}
+
+	if s := list.String(); s != "[1 <nil>]" {
+		t.Errorf("unexpected List string %q", s)
+	}
+	if s := m.String(); s != "map[a:1 b:<nil>]" {
+		t.Errorf("unexpected Map string %q", s)
+	}
+}
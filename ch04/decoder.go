@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+)
+
+const DEFAULT_MAX_DEPTH = 32
+
+var (
+	ErrTypeNotAllowed = errors.New("type not allowed")
+	ErrMaxDepth       = errors.New("maximum nesting depth exceeded")
+	ErrNestedStream   = errors.New("stream cannot be nested")
+)
+
+// 선언된 페이로드 크기가 제한을 넘으면 반환한다.
+// errors.Is(err, ErrMaxPayloadSize)로 확인할 수 있다.
+type MaxPayloadSizeError struct {
+	Size  uint32
+	Limit uint32
+}
+
+func (e *MaxPayloadSizeError) Error() string {
+	return fmt.Sprintf("%v: %d > %d", ErrMaxPayloadSize, e.Size, e.Limit)
+}
+
+func (e *MaxPayloadSizeError) Is(target error) bool {
+	return target == ErrMaxPayloadSize
+}
+
+// 0인 필드는 기본값을 사용한다.
+type Decoder struct {
+	Registry       *Registry
+	MaxPayloadSize uint32
+	// 비어 있으면 Registry에 등록된 모든 타입을 허용한다.
+	AllowedTypes []uint8
+	MaxDepth     int
+
+	r     io.Reader
+	depth int
+	// 확장 프레임 안쪽을 디코딩하는 중인지
+	inExtended bool
+	// 마지막으로 디코딩한 프레임이 확장 프레임이었는지
+	extended bool
+	// 상대가 확장 프레임을 해석할 수 있다고 알렸는지
+	advertised bool
+}
+
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{
+		Registry:       DefaultRegistry,
+		MaxPayloadSize: MAX_PAYLOAD_SIZE,
+		MaxDepth:       DEFAULT_MAX_DEPTH,
+		r:              r,
+	}
+}
+
+// 이미 읽은 헤더를 페이로드의 ReadFrom에 되돌려 준다.
+// ReadFrom은 이 타입으로 Decoder의 설정을 찾는다.
+type frameReader struct {
+	d      *Decoder
+	header []byte
+}
+
+func (f *frameReader) Read(p []byte) (int, error) {
+	if len(f.header) > 0 {
+		n := copy(p, f.header)
+		f.header = f.header[n:]
+
+		return n, nil
+	}
+
+	return f.d.r.Read(p)
+}
+
+func (d *Decoder) Decode() (Payload, error) {
+	if d.depth > d.maxDepth() {
+		return nil, ErrMaxDepth
+	}
+
+	header := make([]byte, 5)
+	for {
+		_, err := io.ReadFull(d.r, header[:1])
+		if err != nil {
+			return nil, err
+		}
+		_, err = readFull(d.r, header[1:])
+		if err != nil {
+			return nil, err
+		}
+
+		// 광고 프레임은 기록만 하고 다음 프레임을 읽는다.
+		if !bytes.Equal(header, extensionAdvertisement) || d.depth > 0 || d.inExtended {
+			break
+		}
+		d.advertised = true
+	}
+
+	var err error
+	typ := header[0]
+	if typ == EXTENDED_TYPE && d.inExtended {
+		return nil, ErrNestedExtended
+	}
+	// 확장 프레임은 안쪽 프레임의 타입으로 확인한다.
+	if typ != EXTENDED_TYPE && len(d.AllowedTypes) > 0 && !slices.Contains(d.AllowedTypes, typ) {
+		return nil, fmt.Errorf("%w: %d", ErrTypeNotAllowed, typ)
+	}
+
+	// Stream은 연결에서 직접 읽으므로 List나 Map에 포함될 수 없다.
+	if typ == STREAM_TYPE && d.depth > 0 {
+		return nil, ErrNestedStream
+	}
+
+	// 페이로드를 할당하기 전에 크기를 확인한다.
+	size := binary.BigEndian.Uint32(header[1:])
+	if limit := d.maxPayloadSize(); size > limit {
+		return nil, &MaxPayloadSizeError{Size: size, Limit: limit}
+	}
+
+	var payload Payload
+	if typ == EXTENDED_TYPE {
+		payload = new(Extended)
+	} else {
+		payload, err = d.registry().New(typ)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = payload.ReadFrom(&frameReader{d: d, header: header})
+	if err != nil {
+		return nil, err
+	}
+
+	ext, ok := payload.(*Extended)
+	d.extended = ok
+	if ok {
+		return ext.Payload, nil
+	}
+
+	return payload, nil
+}
+
+func (d *Decoder) Extended() bool {
+	return d.extended
+}
+
+// 광고 프레임을 받은 적이 있는지
+func (d *Decoder) ExtensionsAdvertised() bool {
+	return d.advertised
+}
+
+// 중첩된 페이로드를 같은 설정으로 디코딩한다.
+func (d *Decoder) nested(body io.Reader) *Decoder {
+	return &Decoder{
+		Registry:       d.Registry,
+		MaxPayloadSize: d.MaxPayloadSize,
+		AllowedTypes:   d.AllowedTypes,
+		MaxDepth:       d.MaxDepth,
+		r:              body,
+		depth:          d.depth + 1,
+	}
+}
+
+func (d *Decoder) registry() *Registry {
+	if d.Registry == nil {
+		return DefaultRegistry
+	}
+
+	return d.Registry
+}
+
+func (d *Decoder) maxPayloadSize() uint32 {
+	if d.MaxPayloadSize == 0 {
+		return MAX_PAYLOAD_SIZE
+	}
+
+	return d.MaxPayloadSize
+}
+
+func (d *Decoder) maxDepth() int {
+	if d.MaxDepth <= 0 {
+		return DEFAULT_MAX_DEPTH
+	}
+
+	return d.MaxDepth
+}
+
+// Decoder를 통해 읽는 중이면 Decoder의 제한을 사용한다.
+func payloadLimit(r io.Reader) uint32 {
+	if f, ok := r.(*frameReader); ok {
+		return f.d.maxPayloadSize()
+	}
+
+	return MAX_PAYLOAD_SIZE
+}
+
+func nestedDecoder(r io.Reader, body io.Reader) *Decoder {
+	if f, ok := r.(*frameReader); ok {
+		return f.d.nested(body)
+	}
+
+	d := NewDecoder(body)
+	d.depth = 1
+
+	return d
+}
+
+// 확장 프레임의 안쪽 프레임은 중첩 단계를 늘리지 않는다.
+func extendedDecoder(r io.Reader, body io.Reader) *Decoder {
+	d := nestedDecoder(r, body)
+	d.depth--
+	d.inExtended = true
+
+	return d
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestDecoderMaxPayloadSize(t *testing.T) {
+	b := make(Binary, MAX_PAYLOAD_SIZE+1)
+	buf := new(bytes.Buffer)
+	_, err := b.WriteTo(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame := buf.Bytes()
+
+	// 기본 제한
+	_, err = decode(bytes.NewReader(frame))
+	var sizeErr *MaxPayloadSizeError
+	if !errors.As(err, &sizeErr) {
+		t.Fatalf("expected MaxPayloadSizeError; got %v", err)
+	}
+	if sizeErr.Size != MAX_PAYLOAD_SIZE+1 || sizeErr.Limit != MAX_PAYLOAD_SIZE {
+		t.Errorf("unexpected size %d and limit %d", sizeErr.Size, sizeErr.Limit)
+	}
+	if !errors.Is(err, ErrMaxPayloadSize) {
+		t.Error("expected error to match ErrMaxPayloadSize")
+	}
+
+	// 더 큰 제한
+	d := NewDecoder(bytes.NewReader(frame))
+	d.MaxPayloadSize = MAX_PAYLOAD_SIZE + 1
+	actual, err := d.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(actual.Bytes()) != len(b) {
+		t.Errorf("expected %d bytes; got %d", len(b), len(actual.Bytes()))
+	}
+
+	// 더 작은 제한은 중첩된 페이로드에도 적용된다.
+	s := String("Clear is better than clever.")
+	list := List{&s}
+	buf.Reset()
+	_, err = list.WriteTo(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d = NewDecoder(bytes.NewReader(buf.Bytes()))
+	d.MaxPayloadSize = uint32(len(s)) - 1
+	_, err = d.Decode()
+	if !errors.As(err, &sizeErr) || sizeErr.Limit != d.MaxPayloadSize {
+		t.Fatalf("expected MaxPayloadSizeError with limit %d; got %v", d.MaxPayloadSize, err)
+	}
+}
+
+func TestDecoderAllowedTypes(t *testing.T) {
+	s := String("Errors are values.")
+	i := Int32(1)
+	list := List{&s, &i}
+
+	buf := new(bytes.Buffer)
+	for _, p := range []Payload{&s, &list} {
+		_, err := p.WriteTo(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	d := NewDecoder(buf)
+	d.AllowedTypes = []uint8{STRING_TYPE, LIST_TYPE}
+
+	actual, err := d.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&s, actual) {
+		t.Errorf("value mismatch: %v != %v", &s, actual)
+	}
+
+	_, err = d.Decode()
+	if !errors.Is(err, ErrTypeNotAllowed) {
+		t.Fatalf("expected ErrTypeNotAllowed for nested Int32; got %v", err)
+	}
+}
+
+// Map의 키도 허용된 타입이어야 한다.
+func TestDecoderAllowedTypesMapKey(t *testing.T) {
+	b := Bool(true)
+	m := Map{"key": &b}
+
+	buf := new(bytes.Buffer)
+	_, err := m.WriteTo(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewDecoder(buf)
+	d.AllowedTypes = []uint8{MAP_TYPE, BOOL_TYPE}
+	_, err = d.Decode()
+	if !errors.Is(err, ErrTypeNotAllowed) {
+		t.Fatalf("expected ErrTypeNotAllowed for Map key; got %v", err)
+	}
+}
+
+// Map의 키는 String이어야 한다.
+func TestDecoderMapKeyType(t *testing.T) {
+	key, value := Int8(1), Bool(true)
+	body := new(bytes.Buffer)
+	for _, p := range []Payload{&key, &value} {
+		_, err := p.WriteTo(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	_, err := writeComposite(buf, MAP_TYPE, body.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = NewDecoder(buf).Decode()
+	if err == nil {
+		t.Fatal("expected error for non-String Map key")
+	}
+}
+
+func TestDecoderMaxDepth(t *testing.T) {
+	b := Bool(true)
+	var p Payload = &b
+	for range 3 {
+		l := List{p}
+		p = &l
+	}
+
+	buf := new(bytes.Buffer)
+	_, err := p.WriteTo(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame := buf.Bytes()
+
+	d := NewDecoder(bytes.NewReader(frame))
+	d.MaxDepth = 3
+	actual, err := d.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(p, actual) {
+		t.Errorf("value mismatch: %v != %v", p, actual)
+	}
+
+	d = NewDecoder(bytes.NewReader(frame))
+	d.MaxDepth = 2
+	_, err = d.Decode()
+	if !errors.Is(err, ErrMaxDepth) {
+		t.Fatalf("expected ErrMaxDepth; got %v", err)
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+
+	"github.com/testaquatic/NetworkProgrammingWithGo/ch04/tlv"
+)
+
+const EXTENDED_TYPE = tlv.EXTENDED_TYPE
+
+// 확장 프레임 플래그
+const (
+	FLAG_CHECKSUM = tlv.FLAG_CHECKSUM
+	FLAG_GZIP     = tlv.FLAG_GZIP
+	FLAG_DEFLATE  = tlv.FLAG_DEFLATE
+
+	compressionFlags = tlv.COMPRESSION_FLAGS
+	knownFlags       = tlv.KNOWN_FLAGS
+)
+
+var (
+	ErrChecksum        = errors.New("checksum mismatch")
+	ErrNestedExtended  = errors.New("extended frame cannot be nested")
+	ErrInvalidExtended = errors.New("invalid Extended")
+)
+
+// 확장 프레임을 해석할 수 있음을 상대에게 알린다. Decoder는 이 프레임을 반환하지 않고 건너뛴다.
+// 타입 바이트만 읽는 기존 decode는 다섯 바이트를 각각 알 수 없는 타입으로 보므로
+// 이 프레임을 받을 상대는 Decoder를 사용해야 한다.
+// | EXTENDED_TYPE(1B) | Size(4B) = 0 |
+var extensionAdvertisement = []byte{EXTENDED_TYPE, 0, 0, 0, 0}
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// 다른 프레임을 감싸 체크섬과 압축을 적용한다.
+// Decoder는 확장 프레임을 검증하고 압축을 풀어 안쪽 페이로드를 반환한다.
+// | EXTENDED_TYPE(1B) | Size(4B) | Flags(1B) | CRC32C(4B) | Frame |
+type Extended struct {
+	Payload  Payload
+	Checksum bool
+	// 0, FLAG_GZIP, FLAG_DEFLATE 중 하나
+	Compression uint8
+}
+
+func (m *Extended) Bytes() []byte {
+	if m.Payload == nil {
+		return nil
+	}
+
+	return m.Payload.Bytes()
+}
+
+func (m *Extended) String() string {
+	if m.Payload == nil {
+		return ""
+	}
+
+	return m.Payload.String()
+}
+
+func (m *Extended) WriteTo(w io.Writer) (int64, error) {
+	if m.Payload == nil {
+		return 0, fmt.Errorf("%w: nil payload", ErrInvalidExtended)
+	}
+	if _, ok := m.Payload.(*Extended); ok {
+		return 0, ErrNestedExtended
+	}
+
+	raw := new(bytes.Buffer)
+	_, err := m.Payload.WriteTo(raw)
+	if err != nil {
+		return 0, err
+	}
+
+	var flags uint8
+	body := raw.Bytes()
+
+	if m.Compression != 0 {
+		compressed, err := compress(m.Compression, body)
+		if err != nil {
+			return 0, err
+		}
+		// 압축해도 작아지지 않으면 그대로 보낸다.
+		if len(compressed) < len(body) {
+			body = compressed
+			flags |= m.Compression
+		}
+	}
+
+	var sum uint32
+	if m.Checksum {
+		flags |= FLAG_CHECKSUM
+		sum = crc32.Checksum(body, castagnoli)
+	}
+
+	if uint64(len(body))+5 > math.MaxUint32 {
+		return 0, ErrMaxPayloadSize
+	}
+
+	n, err := writeHeader(w, EXTENDED_TYPE, uint32(len(body))+5)
+	if err != nil {
+		return n, err
+	}
+
+	header := make([]byte, 5)
+	header[0] = flags
+	binary.BigEndian.PutUint32(header[1:], sum)
+	o, err := w.Write(header)
+	n += int64(o)
+	if err != nil {
+		return n, err
+	}
+
+	o, err = w.Write(body)
+
+	return n + int64(o), err
+}
+
+func (m *Extended) ReadFrom(r io.Reader) (int64, error) {
+	size, n, err := readHeader(r, EXTENDED_TYPE, "Extended")
+	if err != nil {
+		return n, err
+	}
+	if size < 5 {
+		return n, ErrInvalidExtended
+	}
+
+	buf, o, err := readPayload(r, size)
+	n += int64(o)
+	if err != nil {
+		return n, err
+	}
+
+	flags, sum, body := buf[0], binary.BigEndian.Uint32(buf[1:5]), buf[5:]
+	// 알 수 없는 플래그는 해석할 수 없다.
+	if flags&^knownFlags != 0 || flags&compressionFlags == compressionFlags {
+		return n, fmt.Errorf("%w: flags %#x", ErrInvalidExtended, flags)
+	}
+
+	// 체크섬은 전송된(압축된) 데이터를 대상으로 한다.
+	if flags&FLAG_CHECKSUM != 0 && crc32.Checksum(body, castagnoli) != sum {
+		return n, ErrChecksum
+	}
+
+	m.Checksum = flags&FLAG_CHECKSUM != 0
+	m.Compression = flags & compressionFlags
+
+	if m.Compression != 0 {
+		// 프레임 헤더 5바이트 + 페이로드
+		limit := payloadLimit(r)
+		body, err = decompress(m.Compression, body, int64(limit)+5)
+		if err != nil {
+			return n, err
+		}
+	}
+
+	br := bytes.NewReader(body)
+	m.Payload, err = extendedDecoder(r, br).Decode()
+	if err != nil {
+		return n, err
+	}
+	if br.Len() > 0 {
+		return n, fmt.Errorf("%w: trailing data", ErrInvalidExtended)
+	}
+
+	return n, nil
+}
+
+func compress(method uint8, p []byte) ([]byte, error) {
+	buf := new(bytes.Buffer)
+
+	var (
+		w   io.WriteCloser
+		err error
+	)
+	switch method {
+	case FLAG_GZIP:
+		w = gzip.NewWriter(buf)
+	case FLAG_DEFLATE:
+		w, err = flate.NewWriter(buf, flate.DefaultCompression)
+	default:
+		err = fmt.Errorf("%w: compression %#x", ErrInvalidExtended, method)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = w.Write(p)
+	if err != nil {
+		return nil, err
+	}
+
+	err = w.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// 압축 폭탄을 막기 위해 limit 바이트까지만 풀어낸다.
+func decompress(method uint8, p []byte, limit int64) ([]byte, error) {
+	var (
+		r   io.ReadCloser
+		err error
+	)
+	switch method {
+	case FLAG_GZIP:
+		r, err = gzip.NewReader(bytes.NewReader(p))
+	case FLAG_DEFLATE:
+		r = flate.NewReader(bytes.NewReader(p))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidExtended, err)
+	}
+	defer r.Close()
+
+	buf, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidExtended, err)
+	}
+	if int64(len(buf)) > limit {
+		// 실제 크기는 알 수 없으므로 제한을 넘은 지점을 보고한다.
+		return nil, &MaxPayloadSizeError{Size: uint32(len(buf) - 5), Limit: uint32(limit - 5)}
+	}
+
+	return buf, nil
+}
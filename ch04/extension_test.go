@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExtendedRoundTrip(t *testing.T) {
+	s := String(strings.Repeat("The bigger the interface, the weaker the abstraction. ", 100))
+	i := Int64(42)
+	list := List{&s, &i}
+
+	for _, compression := range []uint8{0, FLAG_GZIP, FLAG_DEFLATE} {
+		for _, checksum := range []bool{false, true} {
+			ext := &Extended{Payload: &list, Checksum: checksum, Compression: compression}
+
+			buf := new(bytes.Buffer)
+			_, err := ext.WriteTo(buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if compression != 0 && buf.Len() >= len(list.Bytes()) {
+				t.Errorf("compression %#x: frame not compressed (%d bytes)", compression, buf.Len())
+			}
+
+			d := NewDecoder(buf)
+			actual, err := d.Decode()
+			if err != nil {
+				t.Fatalf("compression %#x, checksum %t: %v", compression, checksum, err)
+			}
+			if !d.Extended() {
+				t.Error("expected decoder to report extended frame")
+			}
+			if !reflect.DeepEqual(&list, actual) {
+				t.Errorf("value mismatch: %v != %v", &list, actual)
+			}
+		}
+	}
+}
+
+func TestExtendedChecksumMismatch(t *testing.T) {
+	s := String("Clear is better than clever.")
+
+	buf := new(bytes.Buffer)
+	_, err := (&Extended{Payload: &s, Checksum: true}).WriteTo(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frame := buf.Bytes()
+	frame[len(frame)-1] ^= 0xff
+
+	_, err = decode(bytes.NewReader(frame))
+	if !errors.Is(err, ErrChecksum) {
+		t.Fatalf("expected ErrChecksum; got %v", err)
+	}
+}
+
+func TestExtendedDecompressionLimit(t *testing.T) {
+	b := make(Binary, 1<<20)
+
+	buf := new(bytes.Buffer)
+	_, err := (&Extended{Payload: &b, Compression: FLAG_GZIP}).WriteTo(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 압축된 프레임은 작지만 풀어낸 크기는 제한을 넘는다.
+	d := NewDecoder(buf)
+	d.MaxPayloadSize = 64 << 10
+	_, err = d.Decode()
+	if !errors.Is(err, ErrMaxPayloadSize) {
+		t.Fatalf("expected ErrMaxPayloadSize; got %v", err)
+	}
+}
+
+func TestExtendedNested(t *testing.T) {
+	s := String("Don't panic.")
+	inner := &Extended{Payload: &s}
+
+	_, err := (&Extended{Payload: inner}).WriteTo(new(bytes.Buffer))
+	if !errors.Is(err, ErrNestedExtended) {
+		t.Fatalf("expected ErrNestedExtended; got %v", err)
+	}
+
+	// 직접 만든 중첩 프레임도 거부한다.
+	innerFrame := new(bytes.Buffer)
+	_, err = inner.WriteTo(innerFrame)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := new(bytes.Buffer)
+	_, _ = writeHeader(buf, EXTENDED_TYPE, uint32(innerFrame.Len())+5)
+	buf.Write(make([]byte, 5))
+	buf.Write(innerFrame.Bytes())
+
+	_, err = decode(buf)
+	if !errors.Is(err, ErrNestedExtended) {
+		t.Fatalf("expected ErrNestedExtended; got %v", err)
+	}
+}
+
+func TestFramedConnExtensionNegotiation(t *testing.T) {
+	client, server := framedPair(t, "tcp", "127.0.0.1:")
+	defer client.Close()
+	defer server.Close()
+
+	// 서버는 클라이언트가 확장 프레임을 보내기 전까지 기존 형식을 사용한다.
+	server.ExtensionMode = EXTENSIONS_AUTO
+	server.Checksum = true
+	server.Compression = FLAG_GZIP
+
+	s := String(strings.Repeat("Errors are values. ", 100))
+
+	err := server.Send(&s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.Receive()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.Decoder.Extended() {
+		t.Fatal("server sent extended frame before negotiation")
+	}
+
+	client.ExtensionMode = EXTENSIONS_ON
+	client.Checksum = true
+
+	err = client.Send(&s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual, err := server.Receive()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !server.Decoder.Extended() {
+		t.Fatal("client did not send extended frame")
+	}
+	if actual.String() != s.String() {
+		t.Error("value mismatch")
+	}
+
+	err = server.Send(&s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.Receive()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !client.Decoder.Extended() {
+		t.Error("server did not switch to extended frames")
+	}
+}
+
+// 둘 다 AUTO이면 광고 프레임을 주고받은 후 확장 프레임을 사용한다.
+func TestFramedConnExtensionAuto(t *testing.T) {
+	client, server := framedPair(t, "tcp", "127.0.0.1:")
+	defer client.Close()
+	defer server.Close()
+
+	for _, c := range []*FramedConn{client, server} {
+		c.ExtensionMode = EXTENSIONS_AUTO
+		c.Checksum = true
+	}
+
+	s := String("Don't just check errors, handle them gracefully.")
+	exchange := func(from, to *FramedConn) bool {
+		t.Helper()
+
+		err := from.Send(&s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual, err := to.Receive()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual.String() != s.String() {
+			t.Error("value mismatch")
+		}
+
+		return to.Decoder.Extended()
+	}
+
+	// 클라이언트는 서버가 지원하는지 아직 모른다.
+	if exchange(client, server) {
+		t.Error("client sent extended frame before negotiation")
+	}
+	// 서버는 클라이언트의 광고 프레임을 받았다.
+	if !exchange(server, client) {
+		t.Error("server did not use extended frames")
+	}
+	if !exchange(client, server) {
+		t.Error("client did not use extended frames")
+	}
+}
+
+// 광고 프레임은 크기가 0이므로 기존 디코더가 헤더만 읽고 넘어갈 수 있다.
+// 확장 프레임 이전의 decode와 같이 타입 바이트만 먼저 읽는다.
+func legacyDecode(r io.Reader) (Payload, error) {
+	var typ uint8
+	err := binary.Read(r, binary.BigEndian, &typ)
+	if err != nil {
+		return nil, err
+	}
+	var payload Payload
+	switch typ {
+	case BINARY_TYPE:
+		payload = new(Binary)
+	case STRING_TYPE:
+		payload = new(String)
+	default:
+		return nil, errors.New("unknown type")
+	}
+
+	_, err = payload.ReadFrom(io.MultiReader(bytes.NewReader([]byte{typ}), r))
+	if err != nil {
+		return nil, err
+	}
+
+	return payload, nil
+}
+
+func TestExtensionAdvertisement(t *testing.T) {
+	s := String("hello")
+	buf := bytes.NewBuffer(append([]byte{}, extensionAdvertisement...))
+	_, _ = s.WriteTo(buf)
+
+	d := NewDecoder(buf)
+	actual, err := d.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !d.ExtensionsAdvertised() || d.Extended() {
+		t.Error("expected advertisement to be recorded and skipped")
+	}
+	if !reflect.DeepEqual(&s, actual) {
+		t.Errorf("value mismatch: %v != %v", &s, actual)
+	}
+
+	// 타입 바이트만 읽는 기존 decode는 광고 프레임을 건너뛰지 못한다.
+	// 그래서 EXTENSIONS_AUTO는 기본값이 아니다.
+	buf = bytes.NewBuffer(append([]byte{}, extensionAdvertisement...))
+	_, _ = s.WriteTo(buf)
+	unknown := 0
+	for {
+		_, err := legacyDecode(buf)
+		if err == nil {
+			break
+		}
+		unknown++
+	}
+	if unknown != len(extensionAdvertisement) {
+		t.Errorf("expected legacy decoder to fail on each advertisement byte; failed %d times", unknown)
+	}
+
+	// List 안의 광고 프레임은 건너뛰지 않는다.
+	body := new(bytes.Buffer)
+	body.Write(extensionAdvertisement)
+	list := new(bytes.Buffer)
+	_, _ = writeComposite(list, LIST_TYPE, body.Bytes())
+	_, err = NewDecoder(list).Decode()
+	if err == nil {
+		t.Error("expected error for nested advertisement")
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/iotest"
+)
+
+// 매 Read마다 임의 크기의 조각만 반환한다.
+type chunkReader struct {
+	r   io.Reader
+	rnd *rand.Rand
+	max int
+}
+
+func (c *chunkReader) Read(p []byte) (int, error) {
+	if len(p) > 1 {
+		p = p[:1+c.rnd.Intn(min(len(p), c.max))]
+	}
+
+	return c.r.Read(p)
+}
+
+func fragmentPayloads() []Payload {
+	large := make(Binary, 1<<20) // 1MB
+	_, _ = rand.New(rand.NewSource(1)).Read(large)
+
+	s := String("Errors are values.")
+	i := Int32(-7)
+	list := List{&s, &i}
+
+	return []Payload{&large, &s, &i, &list, &Map{"list": &list}}
+}
+
+func encodePayloads(t *testing.T, payloads []Payload) []byte {
+	t.Helper()
+
+	buf := new(bytes.Buffer)
+	for _, p := range payloads {
+		_, err := p.WriteTo(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return buf.Bytes()
+}
+
+func TestDecodeFragmented(t *testing.T) {
+	payloads := fragmentPayloads()
+	stream := encodePayloads(t, payloads)
+
+	readers := map[string]func() io.Reader{
+		"one byte": func() io.Reader {
+			return iotest.OneByteReader(bytes.NewReader(stream))
+		},
+		"random chunks": func() io.Reader {
+			return &chunkReader{
+				r:   bytes.NewReader(stream),
+				rnd: rand.New(rand.NewSource(42)),
+				max: 1500,
+			}
+		},
+	}
+
+	for name, newReader := range readers {
+		t.Run(name, func(t *testing.T) {
+			r := newReader()
+			for _, expected := range payloads {
+				actual, err := decode(r)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(expected, actual) {
+					t.Errorf("value mismatch for %T", expected)
+				}
+			}
+
+			_, err := decode(r)
+			if err != io.EOF {
+				t.Errorf("expected io.EOF after last frame; got %v", err)
+			}
+		})
+	}
+}
+
+func TestDecodeTruncated(t *testing.T) {
+	s := String("The bigger the interface, the weaker the abstraction.")
+	frame := encodePayloads(t, []Payload{&s})
+
+	for i := 1; i < len(frame); i++ {
+		_, err := decode(bytes.NewReader(frame[:i]))
+		if !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Fatalf("truncated at %d: expected io.ErrUnexpectedEOF; got %v", i, err)
+		}
+	}
+
+	_, err := decode(bytes.NewReader(nil))
+	if err != io.EOF {
+		t.Errorf("expected io.EOF on empty stream; got %v", err)
+	}
+}
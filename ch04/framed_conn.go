@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 확장 프레임 사용 방식
+const (
+	// 기존 형식만 사용한다.
+	EXTENSIONS_OFF uint8 = iota
+	// 처음 보낼 때 광고 프레임을 먼저 보내고, 상대가 광고 프레임이나 확장 프레임을 보낸 이후부터 사용한다.
+	// 상대는 ExtensionMode와 관계없이 Decoder를 사용해야 한다.
+	// 광고 프레임을 해석하지 못하는 기존 구현과 통신할 때는 EXTENSIONS_OFF를 사용한다.
+	EXTENSIONS_AUTO
+	// 상대가 확장 프레임을 지원한다고 알고 있을 때 사용한다.
+	EXTENSIONS_ON
+)
+
+// net.Conn 위에서 TLV 프레임을 주고받는다.
+// Send는 여러 고루틴에서 동시에 호출할 수 있다.
+type FramedConn struct {
+	// 0이면 데드라인을 설정하지 않는다.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	Decoder      *Decoder
+
+	// 확장 프레임 설정. 수신한 확장 프레임은 설정과 관계없이 해석한다.
+	ExtensionMode uint8
+	Checksum      bool
+	Compression   uint8
+
+	conn net.Conn
+	// 상대가 확장 프레임을 해석할 수 있는지
+	peerExtended atomic.Bool
+
+	rMu sync.Mutex
+	// 마지막으로 받은 Stream
+	stream *Stream
+
+	wMu    sync.Mutex
+	w      *bufio.Writer
+	closed bool
+	// 프레임 일부만 보냈을 수 있으므로 이후의 Send는 이 오류를 반환한다.
+	wErr error
+	// 광고 프레임을 보냈는지
+	advertised bool
+}
+
+func NewFramedConn(conn net.Conn) *FramedConn {
+	return &FramedConn{
+		Decoder: NewDecoder(bufio.NewReader(conn)),
+		conn:    conn,
+		w:       bufio.NewWriter(conn),
+	}
+}
+
+func (c *FramedConn) Send(p Payload) error {
+	c.wMu.Lock()
+	defer c.wMu.Unlock()
+
+	if c.closed {
+		return net.ErrClosed
+	}
+	if c.wErr != nil {
+		return c.wErr
+	}
+
+	if c.WriteTimeout > 0 {
+		err := c.conn.SetWriteDeadline(time.Now().Add(c.WriteTimeout))
+		if err != nil {
+			return err
+		}
+	}
+
+	// 버퍼에만 쓰므로 실패하지 않는다. 프레임과 함께 보낸다.
+	advertise := c.ExtensionMode == EXTENSIONS_AUTO && !c.advertised
+	if advertise {
+		_, _ = c.w.Write(extensionAdvertisement)
+	}
+
+	// Stream은 메모리에 올리지 않도록 확장 프레임으로 감싸지 않는다.
+	if _, ok := p.(*Stream); !ok && c.useExtensions() {
+		p = &Extended{Payload: p, Checksum: c.Checksum, Compression: c.Compression}
+	}
+
+	_, err := p.WriteTo(c.w)
+	if err == nil {
+		err = c.w.Flush()
+	}
+	if err != nil {
+		// 일부만 기록된 프레임은 복구할 수 없으므로 더는 보내지 않는다.
+		c.wErr = fmt.Errorf("framed conn broken: %w", err)
+		return err
+	}
+	if advertise {
+		c.advertised = true
+	}
+
+	return nil
+}
+
+// 수신한 Stream의 Body는 다음 Receive 호출 전에 읽어야 한다.
+// 읽지 않은 Body는 다음 Receive가 버린다.
+func (c *FramedConn) Receive() (Payload, error) {
+	c.rMu.Lock()
+	defer c.rMu.Unlock()
+
+	if c.ReadTimeout > 0 {
+		err := c.conn.SetReadDeadline(time.Now().Add(c.ReadTimeout))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if c.stream != nil {
+		_, err := io.Copy(io.Discard, c.stream.Body())
+		c.stream = nil
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	p, err := c.Decoder.Decode()
+	if err != nil {
+		return nil, err
+	}
+
+	if s, ok := p.(*Stream); ok {
+		c.stream = s
+	}
+	if c.Decoder.Extended() || c.Decoder.ExtensionsAdvertised() {
+		c.peerExtended.Store(true)
+	}
+
+	return p, nil
+}
+
+func (c *FramedConn) useExtensions() bool {
+	if !c.Checksum && c.Compression == 0 {
+		return false
+	}
+
+	switch c.ExtensionMode {
+	case EXTENSIONS_ON:
+		return true
+	case EXTENSIONS_AUTO:
+		return c.peerExtended.Load()
+	}
+
+	return false
+}
+
+// 전송을 마쳤음을 상대에게 알린다. 수신은 계속할 수 있다.
+func (c *FramedConn) CloseWrite() error {
+	c.wMu.Lock()
+	defer c.wMu.Unlock()
+
+	if c.closed {
+		return net.ErrClosed
+	}
+	c.closed = true
+
+	if cw, ok := c.conn.(interface{ CloseWrite() error }); ok {
+		return cw.CloseWrite()
+	}
+
+	return nil
+}
+
+// 연결을 닫는다. 상대가 읽지 않아 막혀 있는 Send도 오류를 반환한다.
+func (c *FramedConn) Close() error {
+	// wMu를 기다리기 전에 닫아야 막혀 있는 Send가 풀린다.
+	err := c.conn.Close()
+
+	c.wMu.Lock()
+	c.closed = true
+	c.wMu.Unlock()
+
+	if errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+
+	return err
+}
+
+func (c *FramedConn) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+func (c *FramedConn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
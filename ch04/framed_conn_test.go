@@ -0,0 +1,208 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func framedPair(t *testing.T, network, address string) (*FramedConn, *FramedConn) {
+	t.Helper()
+
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	accepted := make(chan net.Conn)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			t.Log(err)
+		}
+		accepted <- conn
+	}()
+
+	client, err := net.Dial(network, listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := <-accepted
+	if server == nil {
+		t.Fatal("accept failed")
+	}
+
+	return NewFramedConn(client), NewFramedConn(server)
+}
+
+func TestFramedConnConcurrentSend(t *testing.T) {
+	client, server := framedPair(t, "tcp", "127.0.0.1:")
+	defer client.Close()
+	defer server.Close()
+
+	const senders, frames = 8, 100
+
+	var wg sync.WaitGroup
+	for i := range senders {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for j := range frames {
+				s := String(fmt.Sprintf("sender %d frame %d", i, j))
+				err := client.Send(&s)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		_ = client.CloseWrite()
+	}()
+
+	received := make(map[string]bool)
+	for {
+		p, err := server.Receive()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		received[p.String()] = true
+	}
+
+	if len(received) != senders*frames {
+		t.Errorf("expected %d distinct frames; got %d", senders*frames, len(received))
+	}
+
+	late := String("late")
+	err := client.Send(&late)
+	if !errors.Is(err, net.ErrClosed) {
+		t.Errorf("expected net.ErrClosed after CloseWrite; got %v", err)
+	}
+}
+
+func TestFramedConnReadTimeout(t *testing.T) {
+	client, server := framedPair(t, "tcp", "127.0.0.1:")
+	defer client.Close()
+	defer server.Close()
+
+	server.ReadTimeout = 100 * time.Millisecond
+
+	_, err := server.Receive()
+	var nErr net.Error
+	if !errors.As(err, &nErr) || !nErr.Timeout() {
+		t.Fatalf("expected timeout; got %v", err)
+	}
+}
+
+func TestFramedConnUnixStream(t *testing.T) {
+	dir, err := os.MkdirTemp("", "framed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	client, server := framedPair(t, "unix", filepath.Join(dir, "framed.sock"))
+	defer client.Close()
+	defer server.Close()
+
+	go func() {
+		b := Binary("Clear is better than clever.")
+		// 읽지 않은 Stream Body는 다음 Receive에서 버려진다.
+		for _, p := range []Payload{NewStream(io.LimitReader(zeroReader{}, 1<<20)), &b} {
+			err := client.Send(p)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+		}
+		_ = client.Close()
+	}()
+
+	p, err := server.Receive()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := p.(*Stream); !ok {
+		t.Fatalf("expected *Stream; got %T", p)
+	}
+
+	p, err = server.Receive()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.String() != "Clear is better than clever." {
+		t.Errorf("unexpected payload %q", p)
+	}
+
+	_, err = server.Receive()
+	if err != io.EOF {
+		t.Errorf("expected io.EOF; got %v", err)
+	}
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+
+	return len(p), nil
+}
+
+// 상대가 읽지 않아 막혀 있는 Send가 있어도 Close는 바로 반환한다.
+func TestFramedConnCloseBlockedSend(t *testing.T) {
+	client, server := framedPair(t, "tcp", "127.0.0.1:")
+	defer server.Close()
+
+	sent := make(chan error)
+	go func() {
+		b := make(Binary, 8<<20)
+		sent <- client.Send(&b)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	closed := make(chan error)
+	go func() { closed <- client.Close() }()
+
+	for _, c := range []chan error{closed, sent} {
+		select {
+		case <-c:
+		case <-time.After(2 * time.Second):
+			t.Fatal("expected Close to release the blocked Send")
+		}
+	}
+}
+
+// 프레임 일부만 보낸 후에는 다음 프레임을 보내지 않는다.
+func TestFramedConnBrokenSend(t *testing.T) {
+	client, server := framedPair(t, "tcp", "127.0.0.1:")
+	defer client.Close()
+	defer server.Close()
+
+	client.WriteTimeout = 50 * time.Millisecond
+	b := make(Binary, 8<<20)
+	err := client.Send(&b)
+	if err == nil {
+		t.Fatal("expected write timeout")
+	}
+
+	s := String("next")
+	next := client.Send(&s)
+	if !errors.Is(next, err) {
+		t.Errorf("expected later Send to return %v; got %v", err, next)
+	}
+}
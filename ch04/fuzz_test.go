@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"reflect"
+	"runtime"
+	"testing"
+	"testing/quick"
+	"time"
+)
+
+func encode(t testing.TB, p Payload) []byte {
+	t.Helper()
+
+	buf := new(bytes.Buffer)
+	n, err := p.WriteTo(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int(n) != buf.Len() {
+		t.Fatalf("[%T] wrote %d bytes; reported %d", p, buf.Len(), n)
+	}
+
+	return buf.Bytes()
+}
+
+func FuzzDecode(f *testing.F) {
+	s := String("Errors are values.")
+	i := Int64(math.MinInt64)
+	list := List{&s, &i}
+	for _, p := range []Payload{
+		&s, &i, &list,
+		&Map{"list": &list},
+		&Extended{Payload: &list, Checksum: true, Compression: FLAG_GZIP},
+		NewStream(bytes.NewReader([]byte("Don't panic."))),
+	} {
+		f.Add(encode(f, p))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		d := NewDecoder(bytes.NewReader(data))
+		d.MaxPayloadSize = 1 << 20
+
+		for {
+			p, err := d.Decode()
+			if err != nil {
+				return
+			}
+
+			if s, ok := p.(*Stream); ok {
+				_, err = io.Copy(io.Discard, s.Body())
+				if err != nil {
+					return
+				}
+				continue
+			}
+
+			// 디코딩한 값을 다시 인코딩하면 같은 값으로 디코딩된다.
+			first := encode(t, p)
+			again, err := decode(bytes.NewReader(first))
+			if err != nil {
+				t.Fatalf("[%T] decoding re-encoded payload: %v", p, err)
+			}
+			if second := encode(t, again); !bytes.Equal(first, second) {
+				t.Fatalf("[%T] re-encoded payload mismatch:\n%x\n%x", p, first, second)
+			}
+		}
+	})
+}
+
+func roundTrip(t *testing.T, p Payload) Payload {
+	t.Helper()
+
+	actual, err := decode(bytes.NewReader(encode(t, p)))
+	if err != nil {
+		t.Fatalf("[%T] %v", p, err)
+	}
+
+	return actual
+}
+
+func TestPropertyRoundTrip(t *testing.T) {
+	check := func(name string, f any) {
+		t.Helper()
+
+		err := quick.Check(f, nil)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+
+	check("Binary", func(v []byte) bool {
+		m := Binary(v)
+		return bytes.Equal(roundTrip(t, &m).Bytes(), v)
+	})
+	check("String", func(v string) bool {
+		m := String(v)
+		return roundTrip(t, &m).String() == v
+	})
+	check("Int8", func(v int8) bool { m := Int8(v); return reflect.DeepEqual(roundTrip(t, &m), &m) })
+	check("Int16", func(v int16) bool { m := Int16(v); return reflect.DeepEqual(roundTrip(t, &m), &m) })
+	check("Int32", func(v int32) bool { m := Int32(v); return reflect.DeepEqual(roundTrip(t, &m), &m) })
+	check("Int64", func(v int64) bool { m := Int64(v); return reflect.DeepEqual(roundTrip(t, &m), &m) })
+	check("Uint8", func(v uint8) bool { m := Uint8(v); return reflect.DeepEqual(roundTrip(t, &m), &m) })
+	check("Uint16", func(v uint16) bool { m := Uint16(v); return reflect.DeepEqual(roundTrip(t, &m), &m) })
+	check("Uint32", func(v uint32) bool { m := Uint32(v); return reflect.DeepEqual(roundTrip(t, &m), &m) })
+	check("Uint64", func(v uint64) bool { m := Uint64(v); return reflect.DeepEqual(roundTrip(t, &m), &m) })
+	check("Bool", func(v bool) bool { m := Bool(v); return reflect.DeepEqual(roundTrip(t, &m), &m) })
+	check("Float64", func(v float64) bool {
+		m := Float64(v)
+		actual, ok := roundTrip(t, &m).(*Float64)
+		// NaN도 비트 단위로 같아야 한다.
+		return ok && math.Float64bits(float64(*actual)) == math.Float64bits(v)
+	})
+	check("Time", func(sec int64, nsec uint32) bool {
+		tm := time.Unix(sec, int64(nsec%uint32(time.Second)))
+		m := Time(tm)
+		actual, ok := roundTrip(t, &m).(*Time)
+		return ok && time.Time(*actual).Equal(tm)
+	})
+	check("List", func(v []string) bool {
+		m := List{}
+		for _, s := range v {
+			e := String(s)
+			m = append(m, &e)
+		}
+		return reflect.DeepEqual(roundTrip(t, &m), &m)
+	})
+	check("Map", func(v map[string]int32) bool {
+		m := Map{}
+		for k, i := range v {
+			e := Int32(i)
+			m[k] = &e
+		}
+		return reflect.DeepEqual(roundTrip(t, &m), &m)
+	})
+	check("Extended", func(v []byte, checksum bool, compression uint8) bool {
+		b := Binary(v)
+		m := &Extended{Payload: &b, Checksum: checksum, Compression: []uint8{0, FLAG_GZIP, FLAG_DEFLATE}[compression%3]}
+		return bytes.Equal(roundTrip(t, m).Bytes(), v)
+	})
+	check("Stream", func(v []byte, chunkSize uint8) bool {
+		m := NewStream(bytes.NewReader(v))
+		m.ChunkSize = int(chunkSize)
+		s, ok := roundTrip(t, m).(*Stream)
+		if !ok {
+			return false
+		}
+		body, err := io.ReadAll(s.Body())
+		return err == nil && bytes.Equal(body, v)
+	})
+}
+
+func TestDecodeDoesNotTrustDeclaredSize(t *testing.T) {
+	// 1GB를 선언했지만 실제 데이터는 없다.
+	d := NewDecoder(bytes.NewReader([]byte{BINARY_TYPE, 0x40, 0, 0, 0}))
+	d.MaxPayloadSize = math.MaxUint32
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, err := d.Decode()
+	runtime.ReadMemStats(&after)
+
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("expected io.ErrUnexpectedEOF; got %v", err)
+	}
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+		t.Errorf("allocated %d bytes for empty payload", allocated)
+	}
+}
@@ -0,0 +1,361 @@
+package httpproxy
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/testaquatic/NetworkProgrammingWithGo/ch04/proxy"
+)
+
+const DEFAULT_DIAL_TIMEOUT = 10 * time.Second
+
+var errNotAllowed = errors.New("destination not allowed")
+
+// 다음 홉으로 전달하지 않는 헤더 (RFC 9110 7.6.1)
+var hopHeaders = []string{
+	"Connection",
+	"Proxy-Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// CONNECT 터널과 절대 URI 요청을 중계하는 HTTP 포워드 프록시.
+// http.Transport.Proxy에 이 프록시의 주소를 지정해 사용한다.
+type Proxy struct {
+	// 비어 있으면 인증하지 않는다.
+	Users map[string]string
+	Realm string
+	// 처음 일치한 규칙을 따른다. socks5 프록시와 같은 규칙을 사용한다.
+	// 호스트 이름은 찾은 주소마다 규칙을 적용하고 허용된 주소로만 연결한다.
+	Rules []proxy.Rule
+	// 일치하는 규칙이 없을 때 거부한다.
+	DefaultDeny bool
+	// nil이면 Dial을 사용하는 http.Transport를 만든다.
+	Transport http.RoundTripper
+	// nil이면 net.Dialer를 사용한다.
+	Dial func(ctx context.Context, network, address string) (net.Conn, error)
+	// 터널이 끝날 때마다 호출한다.
+	OnClose func(proxy.ConnStats)
+
+	once      sync.Once
+	transport http.RoundTripper
+
+	tunnels proxy.Tracker
+}
+
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !p.authorized(r) {
+		realm := p.Realm
+		if realm == "" {
+			realm = "proxy"
+		}
+		w.Header().Set("Proxy-Authenticate", fmt.Sprintf("Basic realm=%q", realm))
+		http.Error(w, "proxy authentication required", http.StatusProxyAuthRequired)
+		return
+	}
+
+	if r.Method == http.MethodConnect {
+		p.tunnel(w, r)
+		return
+	}
+
+	if !r.URL.IsAbs() || r.URL.Host == "" {
+		http.Error(w, "absolute URI required", http.StatusBadRequest)
+		return
+	}
+	if r.URL.Scheme != "http" {
+		http.Error(w, "unsupported scheme "+r.URL.Scheme, http.StatusBadRequest)
+		return
+	}
+
+	p.forward(w, r)
+}
+
+func (p *Proxy) authorized(r *http.Request) bool {
+	if len(p.Users) == 0 {
+		return true
+	}
+
+	scheme, credentials, ok := strings.Cut(r.Header.Get("Proxy-Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Basic") {
+		return false
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(credentials))
+	if err != nil {
+		return false
+	}
+
+	user, password, ok := strings.Cut(string(decoded), ":")
+	if !ok {
+		return false
+	}
+
+	expected, ok := p.Users[user]
+
+	return ok && subtle.ConstantTimeCompare([]byte(expected), []byte(password)) == 1
+}
+
+func (p *Proxy) forward(w http.ResponseWriter, r *http.Request) {
+	address := hostPort(r.URL.Host, "80")
+	// Transport를 지정했으면 연결할 때 규칙을 적용할 수 없으므로 여기서 확인한다.
+	_, err := p.resolve(r.Context(), address)
+	if err != nil {
+		resolveError(w, err)
+		return
+	}
+
+	out := r.Clone(r.Context())
+	out.RequestURI = ""
+	out.Close = false
+	removeHopHeaders(out.Header)
+
+	if ip, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		if prior := out.Header.Get("X-Forwarded-For"); prior != "" {
+			ip = prior + ", " + ip
+		}
+		out.Header.Set("X-Forwarded-For", ip)
+	}
+
+	resp, err := p.roundTripper().RoundTrip(out)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	removeHopHeaders(resp.Header)
+	for key, values := range resp.Header {
+		for _, v := range values {
+			w.Header().Add(key, v)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+
+	_, _ = io.Copy(w, resp.Body)
+}
+
+func (p *Proxy) roundTripper() http.RoundTripper {
+	if p.Transport != nil {
+		return p.Transport
+	}
+
+	p.once.Do(func() {
+		p.transport = &http.Transport{
+			DialContext:           p.dial,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+			ResponseHeaderTimeout: time.Minute,
+		}
+	})
+
+	return p.transport
+}
+
+// 규칙이 허용한 주소에 차례로 연결한다.
+func (p *Proxy) dial(ctx context.Context, network, address string) (net.Conn, error) {
+	addresses, err := p.resolve(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+
+	var conn net.Conn
+	for _, address := range addresses {
+		if p.Dial != nil {
+			conn, err = p.Dial(ctx, network, address)
+		} else {
+			d := net.Dialer{Timeout: DEFAULT_DIAL_TIMEOUT}
+			conn, err = d.DialContext(ctx, network, address)
+		}
+		if err == nil {
+			return conn, nil
+		}
+	}
+
+	return nil, err
+}
+
+// 규칙이 없으면 address를 그대로 반환한다.
+func (p *Proxy) resolve(ctx context.Context, address string) ([]string, error) {
+	if len(p.Rules) == 0 && !p.DefaultDeny {
+		return []string{address}, nil
+	}
+
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port %q", portStr)
+	}
+
+	ips := []net.IP{net.ParseIP(host)}
+	if ips[0] == nil {
+		addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+		if err != nil {
+			return nil, err
+		}
+
+		ips = ips[:0]
+		for _, addr := range addrs {
+			ips = append(ips, net.IP(addr.Unmap().AsSlice()))
+		}
+	}
+
+	var addresses []string
+	for _, ip := range ips {
+		if proxy.Allowed(p.Rules, p.DefaultDeny, host, ip, uint16(port)) {
+			addresses = append(addresses, net.JoinHostPort(ip.String(), portStr))
+		}
+	}
+	if len(addresses) == 0 {
+		return nil, errNotAllowed
+	}
+
+	return addresses, nil
+}
+
+func resolveError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errNotAllowed) {
+		http.Error(w, "destination not allowed", http.StatusForbidden)
+		return
+	}
+
+	http.Error(w, err.Error(), http.StatusBadGateway)
+}
+
+func (p *Proxy) tunnel(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	stats := proxy.ConnStats{Client: addr(r.RemoteAddr)}
+
+	// CONNECT 요청의 대상은 authority 형식이다.
+	address := r.Host
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		http.Error(w, "invalid CONNECT target", http.StatusBadRequest)
+		return
+	}
+
+	target, err := p.dial(r.Context(), "tcp", address)
+	if err != nil {
+		resolveError(w, err)
+		return
+	}
+	defer target.Close()
+	stats.Target = target.RemoteAddr()
+
+	conn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer conn.Close()
+
+	p.tunnels.Add(conn)
+	defer p.tunnels.Remove(conn)
+
+	_ = conn.SetDeadline(time.Time{})
+	_, err = io.WriteString(conn, "HTTP/1.1 200 Connection Established\r\n\r\n")
+	if err == nil {
+		// 클라이언트가 응답을 기다리지 않고 보낸 데이터는 버퍼에 남아 있다.
+		client := &bufferedConn{Conn: conn, r: rw.Reader}
+		stats.Sent, stats.Received, err = proxy.Pipe(client, target)
+	}
+
+	if p.OnClose != nil {
+		stats.Err = err
+		stats.Duration = time.Since(start)
+		p.OnClose(stats)
+	}
+}
+
+// http.Server.Shutdown은 CONNECT 터널을 기다리지 않으므로 함께 호출한다.
+// 터널이 모두 끝나거나 ctx가 끝날 때까지 기다리고 남은 터널은 끊는다.
+func (p *Proxy) Shutdown(ctx context.Context) error {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		if p.tunnels.Len() == 0 {
+			return nil
+		}
+		if ctx.Err() != nil {
+			p.tunnels.CloseAll()
+			return ctx.Err()
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-ticker.C:
+		}
+	}
+}
+
+func removeHopHeaders(h http.Header) {
+	// Connection 헤더에 나열한 헤더도 홉 사이에서만 사용한다.
+	for _, v := range h.Values("Connection") {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				h.Del(name)
+			}
+		}
+	}
+	for _, name := range hopHeaders {
+		h.Del(name)
+	}
+}
+
+func hostPort(host, defaultPort string) string {
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+
+	return net.JoinHostPort(strings.Trim(host, "[]"), defaultPort)
+}
+
+func addr(s string) net.Addr {
+	a, err := net.ResolveTCPAddr("tcp", s)
+	if err != nil {
+		return nil
+	}
+
+	return a
+}
+
+// 하이재킹한 연결의 버퍼에 남은 데이터를 먼저 읽는다.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	if c.r.Buffered() > 0 {
+		return c.r.Read(p)
+	}
+
+	return c.Conn.Read(p)
+}
+
+func (c *bufferedConn) CloseWrite() error {
+	if cw, ok := c.Conn.(interface{ CloseWrite() error }); ok {
+		return cw.CloseWrite()
+	}
+
+	return c.Close()
+}
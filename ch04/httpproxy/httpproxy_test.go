@@ -0,0 +1,295 @@
+package httpproxy
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/testaquatic/NetworkProgrammingWithGo/ch04/proxy"
+)
+
+func startProxy(t *testing.T, p *Proxy) *url.URL {
+	t.Helper()
+
+	ts := httptest.NewServer(p)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_ = p.Shutdown(ctx)
+		ts.Close()
+	})
+
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return u
+}
+
+func client(proxyURL *url.URL, tlsConfig *tls.Config) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy:           http.ProxyURL(proxyURL),
+			TLSClientConfig: tlsConfig,
+		},
+		Timeout: 5 * time.Second,
+	}
+}
+
+func TestForward(t *testing.T) {
+	var header http.Header
+	backend := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			header = r.Header.Clone()
+			w.Header().Set("Connection", "X-Backend-Hop")
+			w.Header().Set("X-Backend-Hop", "1")
+			_, _ = io.WriteString(w, "hello "+r.URL.Path)
+		},
+	))
+	defer backend.Close()
+
+	proxyURL := startProxy(t, &Proxy{Users: map[string]string{"alice": "secret"}})
+	proxyURL.User = url.UserPassword("alice", "secret")
+
+	req, err := http.NewRequest(http.MethodGet, backend.URL+"/path", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Connection", "X-Client-Hop")
+	req.Header.Set("X-Client-Hop", "1")
+	req.Header.Set("X-End-To-End", "1")
+
+	resp, err := client(proxyURL, nil).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(b) != "hello /path" {
+		t.Errorf("expected %q; actual %q", "hello /path", b)
+	}
+	if resp.Header.Get("X-Backend-Hop") != "" {
+		t.Error("expected X-Backend-Hop to be stripped from response")
+	}
+
+	for _, name := range []string{"Proxy-Authorization", "X-Client-Hop"} {
+		if header.Get(name) != "" {
+			t.Errorf("expected %s to be stripped from request", name)
+		}
+	}
+	if header.Get("X-End-To-End") != "1" {
+		t.Error("expected X-End-To-End to be forwarded")
+	}
+	if header.Get("X-Forwarded-For") != "127.0.0.1" {
+		t.Errorf("expected X-Forwarded-For 127.0.0.1; actual %q", header.Get("X-Forwarded-For"))
+	}
+}
+
+func TestConnect(t *testing.T) {
+	backend := httptest.NewTLSServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.WriteString(w, "secure")
+		},
+	))
+	defer backend.Close()
+
+	closed := make(chan proxy.ConnStats, 1)
+	proxyURL := startProxy(t, &Proxy{
+		OnClose: func(c proxy.ConnStats) { closed <- c },
+	})
+
+	c := client(proxyURL, backend.Client().Transport.(*http.Transport).TLSClientConfig)
+	resp, err := c.Get(backend.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "secure" {
+		t.Errorf("expected %q; actual %q", "secure", b)
+	}
+
+	c.CloseIdleConnections()
+	select {
+	case stats := <-closed:
+		if stats.Sent == 0 || stats.Received == 0 {
+			t.Errorf("expected bytes in both directions; actual %+v", stats)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("tunnel did not close")
+	}
+}
+
+// 200 응답을 기다리지 않고 보낸 데이터도 전달해야 한다.
+func TestConnectPipelined(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _ = io.Copy(conn, conn)
+	}()
+
+	proxyURL := startProxy(t, &Proxy{})
+
+	conn, err := net.Dial("tcp", proxyURL.Host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	target := l.Addr().String()
+	_, err = io.WriteString(conn, "CONNECT "+target+" HTTP/1.1\r\nHost: "+target+"\r\n\r\nearly")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, &http.Request{Method: http.MethodConnect})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status %d; actual %d", http.StatusOK, resp.StatusCode)
+	}
+
+	buf := make([]byte, len("early"))
+	_, err = io.ReadFull(br, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "early" {
+		t.Errorf("expected %q; actual %q", "early", buf)
+	}
+}
+
+func TestProxyAuth(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {},
+	))
+	defer backend.Close()
+
+	proxyURL := startProxy(t, &Proxy{Users: map[string]string{"alice": "secret"}})
+
+	tests := []struct {
+		name   string
+		user   *url.Userinfo
+		status int
+	}{
+		{"valid", url.UserPassword("alice", "secret"), http.StatusOK},
+		{"wrong password", url.UserPassword("alice", "guess"), http.StatusProxyAuthRequired},
+		{"no credentials", nil, http.StatusProxyAuthRequired},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			u := *proxyURL
+			u.User = tc.user
+
+			resp, err := client(&u, nil).Get(backend.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = resp.Body.Close()
+
+			if resp.StatusCode != tc.status {
+				t.Errorf("expected status %d; actual %d", tc.status, resp.StatusCode)
+			}
+			if tc.status == http.StatusProxyAuthRequired && resp.Header.Get("Proxy-Authenticate") == "" {
+				t.Error("expected Proxy-Authenticate header")
+			}
+		})
+	}
+}
+
+func TestAllowList(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {},
+	))
+	defer backend.Close()
+
+	proxyURL := startProxy(t, &Proxy{Rules: []proxy.Rule{{Host: "192.0.2.0/24"}}, DefaultDeny: true})
+
+	resp, err := client(proxyURL, nil).Get(backend.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected status %d; actual %d", http.StatusForbidden, resp.StatusCode)
+	}
+
+	tlsBackend := httptest.NewTLSServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {},
+	))
+	defer tlsBackend.Close()
+
+	// CONNECT가 거부되면 클라이언트는 오류를 반환한다.
+	_, err = client(proxyURL, nil).Get(tlsBackend.URL)
+	if err == nil {
+		t.Error("expected CONNECT to be refused")
+	}
+}
+
+// socks5 프록시와 같은 규칙을 사용하고 호스트 이름은 찾은 주소에도 규칙을 적용한다.
+func TestResolveRules(t *testing.T) {
+	p := &Proxy{Rules: []proxy.Rule{
+		{Deny: true, Host: "127.0.0.0/8"},
+		{Deny: true, Host: "::1"},
+		{Host: "192.0.2.0/24", Port: 80},
+	}, DefaultDeny: true}
+
+	tests := []struct {
+		address string
+		allowed bool
+	}{
+		{"127.0.0.1:80", false},
+		// 이름으로 요청해도 찾은 주소가 거부 범위에 있으면 거부한다.
+		{"localhost:80", false},
+		{"[::1]:8080", false},
+		{"192.0.2.1:80", true},
+		{"192.0.2.1:443", false},
+		{"example.com", false},
+	}
+
+	for _, tc := range tests {
+		addresses, err := p.resolve(context.Background(), tc.address)
+		if tc.allowed != (err == nil) {
+			t.Errorf("%q: expected allowed %t; actual %v %v", tc.address, tc.allowed, addresses, err)
+		}
+		if err == nil {
+			for _, address := range addresses {
+				if address != "192.0.2.1:80" {
+					t.Errorf("%q: expected denied address to be filtered; actual %v", tc.address, addresses)
+				}
+			}
+		}
+	}
+
+	if addresses, err := new(Proxy).resolve(context.Background(), "anything:1"); err != nil || addresses[0] != "anything:1" {
+		t.Errorf("expected no rules to allow everything; actual %v %v", addresses, err)
+	}
+}
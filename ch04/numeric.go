@@ -0,0 +1,307 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strconv"
+	"time"
+)
+
+type fixed interface {
+	~int8 | ~int16 | ~int32 | ~int64 | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~float64
+}
+
+func fixedBytes[T fixed](v T) []byte {
+	buf := new(bytes.Buffer)
+	_ = binary.Write(buf, binary.BigEndian, v)
+
+	return buf.Bytes()
+}
+
+func writeFixed[T fixed](w io.Writer, typ uint8, v T) (int64, error) {
+	n, err := writeHeader(w, typ, uint32(binary.Size(v)))
+	if err != nil {
+		return n, err
+	}
+
+	err = binary.Write(w, binary.BigEndian, v)
+	if err != nil {
+		return n, err
+	}
+
+	return n + int64(binary.Size(v)), nil
+}
+
+func readFixed[T fixed](r io.Reader, typ uint8, name string, v *T) (int64, error) {
+	size, n, err := readHeader(r, typ, name)
+	if err != nil {
+		return n, err
+	}
+	// 고정 길이 타입은 크기가 정확히 일치해야 한다.
+	if int(size) != binary.Size(*v) {
+		return n, errors.New("invalid " + name)
+	}
+
+	err = binary.Read(r, binary.BigEndian, v)
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return n, err
+	}
+
+	return n + int64(size), nil
+}
+
+type Int8 int8
+
+func (m Int8) Bytes() []byte {
+	return fixedBytes(m)
+}
+
+func (m Int8) String() string {
+	return strconv.FormatInt(int64(m), 10)
+}
+
+func (m Int8) WriteTo(w io.Writer) (int64, error) {
+	return writeFixed(w, INT8_TYPE, m)
+}
+
+func (m *Int8) ReadFrom(r io.Reader) (int64, error) {
+	return readFixed(r, INT8_TYPE, "Int8", m)
+}
+
+type Int16 int16
+
+func (m Int16) Bytes() []byte {
+	return fixedBytes(m)
+}
+
+func (m Int16) String() string {
+	return strconv.FormatInt(int64(m), 10)
+}
+
+func (m Int16) WriteTo(w io.Writer) (int64, error) {
+	return writeFixed(w, INT16_TYPE, m)
+}
+
+func (m *Int16) ReadFrom(r io.Reader) (int64, error) {
+	return readFixed(r, INT16_TYPE, "Int16", m)
+}
+
+type Int32 int32
+
+func (m Int32) Bytes() []byte {
+	return fixedBytes(m)
+}
+
+func (m Int32) String() string {
+	return strconv.FormatInt(int64(m), 10)
+}
+
+func (m Int32) WriteTo(w io.Writer) (int64, error) {
+	return writeFixed(w, INT32_TYPE, m)
+}
+
+func (m *Int32) ReadFrom(r io.Reader) (int64, error) {
+	return readFixed(r, INT32_TYPE, "Int32", m)
+}
+
+type Int64 int64
+
+func (m Int64) Bytes() []byte {
+	return fixedBytes(m)
+}
+
+func (m Int64) String() string {
+	return strconv.FormatInt(int64(m), 10)
+}
+
+func (m Int64) WriteTo(w io.Writer) (int64, error) {
+	return writeFixed(w, INT64_TYPE, m)
+}
+
+func (m *Int64) ReadFrom(r io.Reader) (int64, error) {
+	return readFixed(r, INT64_TYPE, "Int64", m)
+}
+
+type Uint8 uint8
+
+func (m Uint8) Bytes() []byte {
+	return fixedBytes(m)
+}
+
+func (m Uint8) String() string {
+	return strconv.FormatUint(uint64(m), 10)
+}
+
+func (m Uint8) WriteTo(w io.Writer) (int64, error) {
+	return writeFixed(w, UINT8_TYPE, m)
+}
+
+func (m *Uint8) ReadFrom(r io.Reader) (int64, error) {
+	return readFixed(r, UINT8_TYPE, "Uint8", m)
+}
+
+type Uint16 uint16
+
+func (m Uint16) Bytes() []byte {
+	return fixedBytes(m)
+}
+
+func (m Uint16) String() string {
+	return strconv.FormatUint(uint64(m), 10)
+}
+
+func (m Uint16) WriteTo(w io.Writer) (int64, error) {
+	return writeFixed(w, UINT16_TYPE, m)
+}
+
+func (m *Uint16) ReadFrom(r io.Reader) (int64, error) {
+	return readFixed(r, UINT16_TYPE, "Uint16", m)
+}
+
+type Uint32 uint32
+
+func (m Uint32) Bytes() []byte {
+	return fixedBytes(m)
+}
+
+func (m Uint32) String() string {
+	return strconv.FormatUint(uint64(m), 10)
+}
+
+func (m Uint32) WriteTo(w io.Writer) (int64, error) {
+	return writeFixed(w, UINT32_TYPE, m)
+}
+
+func (m *Uint32) ReadFrom(r io.Reader) (int64, error) {
+	return readFixed(r, UINT32_TYPE, "Uint32", m)
+}
+
+type Uint64 uint64
+
+func (m Uint64) Bytes() []byte {
+	return fixedBytes(m)
+}
+
+func (m Uint64) String() string {
+	return strconv.FormatUint(uint64(m), 10)
+}
+
+func (m Uint64) WriteTo(w io.Writer) (int64, error) {
+	return writeFixed(w, UINT64_TYPE, m)
+}
+
+func (m *Uint64) ReadFrom(r io.Reader) (int64, error) {
+	return readFixed(r, UINT64_TYPE, "Uint64", m)
+}
+
+type Float64 float64
+
+func (m Float64) Bytes() []byte {
+	return fixedBytes(m)
+}
+
+func (m Float64) String() string {
+	return strconv.FormatFloat(float64(m), 'g', -1, 64)
+}
+
+func (m Float64) WriteTo(w io.Writer) (int64, error) {
+	return writeFixed(w, FLOAT64_TYPE, m)
+}
+
+func (m *Float64) ReadFrom(r io.Reader) (int64, error) {
+	return readFixed(r, FLOAT64_TYPE, "Float64", m)
+}
+
+type Bool bool
+
+func (m Bool) Bytes() []byte {
+	if m {
+		return []byte{1}
+	}
+
+	return []byte{0}
+}
+
+func (m Bool) String() string {
+	return strconv.FormatBool(bool(m))
+}
+
+func (m Bool) WriteTo(w io.Writer) (int64, error) {
+	return writeFixed(w, BOOL_TYPE, m.Bytes()[0])
+}
+
+func (m *Bool) ReadFrom(r io.Reader) (int64, error) {
+	var b uint8
+	n, err := readFixed(r, BOOL_TYPE, "Bool", &b)
+	if err != nil {
+		return n, err
+	}
+
+	// 0과 1 이외의 값은 허용하지 않는다.
+	if b > 1 {
+		return n, errors.New("invalid Bool")
+	}
+	*m = b == 1
+
+	return n, nil
+}
+
+// | Type(1B) | Size(4B) | Seconds(8B) | Nanoseconds(4B) |
+type Time time.Time
+
+const timeSize = 8 + 4
+
+func (m Time) Bytes() []byte {
+	t := time.Time(m)
+	b := make([]byte, timeSize)
+	binary.BigEndian.PutUint64(b[:8], uint64(t.Unix()))
+	binary.BigEndian.PutUint32(b[8:], uint32(t.Nanosecond()))
+
+	return b
+}
+
+func (m Time) String() string {
+	return time.Time(m).Format(time.RFC3339Nano)
+}
+
+func (m Time) WriteTo(w io.Writer) (int64, error) {
+	n, err := writeHeader(w, TIME_TYPE, timeSize)
+	if err != nil {
+		return n, err
+	}
+
+	o, err := w.Write(m.Bytes())
+
+	return n + int64(o), err
+}
+
+func (m *Time) ReadFrom(r io.Reader) (int64, error) {
+	size, n, err := readHeader(r, TIME_TYPE, "Time")
+	if err != nil {
+		return n, err
+	}
+	if size != timeSize {
+		return n, errors.New("invalid Time")
+	}
+
+	b := make([]byte, timeSize)
+	o, err := readFull(r, b)
+	n += int64(o)
+	if err != nil {
+		return n, err
+	}
+
+	nsec := binary.BigEndian.Uint32(b[8:])
+	if nsec >= uint32(time.Second) {
+		return n, errors.New("invalid Time")
+	}
+	sec := int64(binary.BigEndian.Uint64(b[:8]))
+	*m = Time(time.Unix(sec, int64(nsec)).UTC())
+
+	return n, nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFixedPayloadsRoundTrip(t *testing.T) {
+	i8, i16, i32, i64 := Int8(math.MinInt8), Int16(-2), Int32(math.MaxInt32), Int64(math.MinInt64)
+	u8, u16, u32, u64 := Uint8(math.MaxUint8), Uint16(2), Uint32(3), Uint64(math.MaxUint64)
+	f64, b1, b2 := Float64(math.Pi), Bool(true), Bool(false)
+	payloads := []Payload{&i8, &i16, &i32, &i64, &u8, &u16, &u32, &u64, &f64, &b1, &b2}
+
+	for _, expected := range payloads {
+		buf := new(bytes.Buffer)
+		n, err := expected.WriteTo(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if int(n) != buf.Len() {
+			t.Errorf("[%T] wrote %d bytes; reported %d", expected, buf.Len(), n)
+		}
+
+		actual, err := decode(buf)
+		if err != nil {
+			t.Fatalf("[%T] %v", expected, err)
+		}
+		if !reflect.DeepEqual(expected, actual) {
+			t.Errorf("value mismatch: %v != %v", expected, actual)
+		}
+	}
+}
+
+func TestTimeRoundTrip(t *testing.T) {
+	for _, tm := range []time.Time{
+		time.Date(2024, 2, 29, 12, 30, 45, 123456789, time.UTC),
+		time.Unix(0, 0).UTC(),
+		{},
+	} {
+		expected := Time(tm)
+		buf := new(bytes.Buffer)
+		_, err := expected.WriteTo(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		actual, err := decode(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		at, ok := actual.(*Time)
+		if !ok {
+			t.Fatalf("expected *Time; got %T", actual)
+		}
+		if !time.Time(*at).Equal(tm) {
+			t.Errorf("value mismatch: %v != %v", expected, at)
+		}
+	}
+}
+
+func TestFixedPayloadInvalidSize(t *testing.T) {
+	// Int32 타입이지만 크기가 2바이트
+	_, err := decode(bytes.NewReader([]byte{INT32_TYPE, 0, 0, 0, 2, 0, 1}))
+	if err == nil {
+		t.Fatal("expected error for invalid Int32 size")
+	}
+
+	_, err = decode(bytes.NewReader([]byte{BOOL_TYPE, 0, 0, 0, 1, 2}))
+	if err == nil {
+		t.Fatal("expected error for invalid Bool value")
+	}
+}
@@ -1,61 +1,213 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"crypto/tls"
 	"flag"
 	"fmt"
-	"net"
+	"io"
 	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 )
 
 var (
-	count    = flag.Int("c", 3, "number of pings: <= 0 means forever")
-	interval = flag.Duration("i", time.Second, "interval between pings")
-	timeout  = flag.Duration("W", 5*time.Second, "time to wait for a reply")
+	count       = flag.Int("c", 3, "number of pings per target: <= 0 means forever")
+	interval    = flag.Duration("i", time.Second, "interval between pings")
+	timeout     = flag.Duration("W", 5*time.Second, "time to wait for a reply")
+	file        = flag.String("f", "", `file with one host:port per line: "-" reads standard input`)
+	concurrency = flag.Int("concurrency", 16, "maximum number of pings in flight")
+	format      = flag.String("o", "table", "output format: table, json or csv")
+	mode        = flag.String("m", "tcp", "probe mode: tcp, tls, http or udp")
+	insecure    = flag.Bool("k", false, "do not verify server certificates")
 )
 
+var modes = map[string]uint8{
+	"tcp":  PROBE_TCP,
+	"tls":  PROBE_TLS,
+	"http": PROBE_HTTP,
+	"udp":  PROBE_UDP,
+}
+
 func init() {
 	flag.Usage = func() {
-		fmt.Printf("Usage: %s [options] host:port\nOptions:\n", os.Args[0])
+		fmt.Printf("Usage: %s [options] host:port [host:port ...]\nOptions:\n", os.Args[0])
 		flag.PrintDefaults()
+		fmt.Println("In http mode targets may be URLs and status codes of 400 or above count as failures.")
+		fmt.Println("The udp mode expects the target to echo each datagram back.")
+		fmt.Println("Summaries go to standard error with json and csv output.")
+		fmt.Println("Exit status is 0 if every ping succeeds, 1 if any ping fails and 2 on usage errors.")
 	}
 }
 
 func main() {
 	flag.Parse()
 
-	if flag.NArg() != 1 {
+	targets := flag.Args()
+	if *file != "" {
+		t, err := readTargets(*file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		targets = append(targets, t...)
+	}
+	m, ok := modes[*mode]
+	if len(targets) == 0 || *concurrency <= 0 || !ok {
 		flag.Usage()
-		os.Exit(1)
+		os.Exit(2)
 	}
 
-	target := flag.Arg(0)
-	fmt.Println("PING", target)
+	out, err := newResultWriter(*format, os.Stdout, targets)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	summaryOut := io.Writer(os.Stdout)
+	if *format != "table" {
+		summaryOut = os.Stderr
+	}
 
 	if *count <= 0 {
-		fmt.Println("CTRL+C to stop.")
+		fmt.Fprintln(os.Stderr, "CTRL+C to stop.")
 	}
 
-	msg := 0
+	p := &pinger{
+		mode:      m,
+		count:     *count,
+		interval:  *interval,
+		timeout:   *timeout,
+		tlsConfig: &tls.Config{InsecureSkipVerify: *insecure},
+		sem:       make(chan struct{}, *concurrency),
+		out:       out,
+	}
 
-	for (*count <= 0) || (msg < *count) {
-		msg++
-		fmt.Print(msg, " ")
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	start := time.Now()
+	stats := p.run(ctx, targets)
+	stop()
 
-		start := time.Now()
-		c, err := net.DialTimeout("tcp", target, *timeout)
-		dur := time.Since(start)
+	failed := false
+	for i, s := range stats {
+		_, _ = fmt.Fprintln(summaryOut)
+		s.summary(summaryOut, targets[i], time.Since(start))
+		if s.sent == 0 || s.received < s.sent {
+			failed = true
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
 
+// 빈 줄과 #으로 시작하는 줄은 건너뛴다.
+func readTargets(name string) ([]string, error) {
+	r := io.Reader(os.Stdin)
+	if name != "-" {
+		f, err := os.Open(name)
 		if err != nil {
-			fmt.Printf("fail in %s: %v\n", dur, err)
-			if nErr, ok := err.(net.Error); !ok || !nErr.Timeout() {
-				os.Exit(1)
-			}
-		} else {
-			c.Close()
-			fmt.Println(dur)
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var targets []string
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		targets = append(targets, line)
+	}
+
+	return targets, s.Err()
+}
+
+// 한 번의 연결 시도 결과
+type pingResult struct {
+	Time   time.Time `json:"time"`
+	Target string    `json:"target"`
+	Seq    int       `json:"seq"`
+	// 연결에 사용한 주소. 이름을 찾지 못하면 비어 있다.
+	Address string `json:"address,omitempty"`
+	// ipv4 또는 ipv6
+	Family  string        `json:"family,omitempty"`
+	RTT     time.Duration `json:"-"`
+	Err     error         `json:"-"`
+	Timeout bool          `json:"timeout,omitempty"`
+	Phases  []pingPhase   `json:"phases,omitempty"`
+	// HTTP 응답의 상태 코드
+	Status int      `json:"status,omitempty"`
+	TLS    *tlsInfo `json:"tls,omitempty"`
+}
+
+// 여러 대상에 동시에 연결 시간을 잰다.
+type pinger struct {
+	// PROBE_TCP, PROBE_TLS, PROBE_HTTP 또는 PROBE_UDP
+	mode uint8
+	// 0 이하면 ctx가 끝날 때까지 계속한다.
+	count    int
+	interval time.Duration
+	// 프로브 하나의 모든 단계에 걸리는 시간을 제한한다.
+	timeout   time.Duration
+	tlsConfig *tls.Config
+	// 동시에 진행하는 연결 수를 제한한다.
+	sem chan struct{}
+	out resultWriter
+}
+
+// 대상마다 통계를 targets와 같은 순서로 반환한다.
+func (p *pinger) run(ctx context.Context, targets []string) []*pingStats {
+	stats := make([]*pingStats, len(targets))
+
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stats[i] = p.ping(ctx, target)
+		}()
+	}
+	wg.Wait()
+
+	return stats
+}
+
+// count번 또는 ctx가 끝날 때까지 연결 시간을 잰다.
+// 중단된 프로브는 통계에 넣지 않는다.
+func (p *pinger) ping(ctx context.Context, target string) *pingStats {
+	stats := new(pingStats)
+
+	for seq := 1; ; seq++ {
+		select {
+		case <-ctx.Done():
+			return stats
+		case p.sem <- struct{}{}:
 		}
+		r := p.probe(ctx, target, seq)
+		<-p.sem
 
-		time.Sleep(*interval)
+		if ctx.Err() != nil {
+			return stats
+		}
+		stats.add(r.RTT, r.Err)
+		_ = p.out.write(r)
+
+		if p.count > 0 && seq == p.count {
+			return stats
+		}
+
+		select {
+		case <-ctx.Done():
+			return stats
+		case <-time.After(p.interval):
+		}
 	}
 }
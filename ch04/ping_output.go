@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 결과를 받는 대로 출력한다. 여러 고루틴에서 동시에 사용할 수 있다.
+type resultWriter interface {
+	write(r pingResult) error
+}
+
+func newResultWriter(format string, w io.Writer, targets []string) (resultWriter, error) {
+	switch format {
+	case "table":
+		width := len("TARGET")
+		for _, t := range targets {
+			width = max(width, len(t))
+		}
+		tw := &tableWriter{w: w, width: width}
+		return tw, tw.header()
+	case "json":
+		return &jsonWriter{enc: json.NewEncoder(w)}, nil
+	case "csv":
+		cw := &csvWriter{w: csv.NewWriter(w)}
+		return cw, cw.header()
+	default:
+		return nil, fmt.Errorf("unknown output format %q", format)
+	}
+}
+
+// 열의 너비를 미리 정해 두고 한 줄씩 출력한다.
+type tableWriter struct {
+	mu    sync.Mutex
+	w     io.Writer
+	width int
+}
+
+func (t *tableWriter) header() error {
+	_, err := fmt.Fprintf(t.w, "%-*s %5s %-47s %-6s %12s  %s\n",
+		t.width, "TARGET", "SEQ", "ADDRESS", "FAMILY", "RTT", "RESULT")
+
+	return err
+}
+
+func (t *tableWriter) write(r pingResult) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	result := "ok"
+	switch {
+	case r.Timeout:
+		result = "timeout"
+	case r.Err != nil:
+		result = r.Err.Error()
+	}
+	if details := r.details(); details != "" {
+		result += " " + details
+	}
+
+	_, err := fmt.Fprintf(t.w, "%-*s %5d %-47s %-6s %12s  %s\n",
+		t.width, r.Target, r.Seq, dash(r.Address), dash(r.Family), r.RTT.Round(time.Microsecond), result)
+
+	return err
+}
+
+// 상태 코드, 인증서, 단계별 시간을 한 줄로 나타낸다.
+func (r pingResult) details() string {
+	var s []string
+	if r.Status != 0 {
+		s = append(s, "status="+strconv.Itoa(r.Status))
+	}
+	if r.TLS != nil {
+		s = append(s, "tls="+strings.ReplaceAll(r.TLS.Version, " ", ""))
+		if !r.TLS.NotAfter.IsZero() {
+			s = append(s, "expires="+r.TLS.NotAfter.Format(time.DateOnly))
+		}
+	}
+	if p := formatPhases(r.Phases); p != "" {
+		s = append(s, p)
+	}
+
+	return strings.Join(s, " ")
+}
+
+// dns=0.512ms connect=1.204ms
+func formatPhases(phases []pingPhase) string {
+	s := make([]string, 0, len(phases))
+	for _, p := range phases {
+		s = append(s, fmt.Sprintf("%s=%.3fms", p.Name, ms(p.Duration)))
+	}
+
+	return strings.Join(s, " ")
+}
+
+func (p pingPhase) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Name string  `json:"name"`
+		MS   float64 `json:"ms"`
+	}{p.Name, ms(p.Duration)})
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+
+	return s
+}
+
+// 한 줄에 JSON 객체 하나를 출력한다.
+type jsonWriter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func (j *jsonWriter) write(r pingResult) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	var errMsg string
+	if r.Err != nil {
+		errMsg = r.Err.Error()
+	}
+
+	return j.enc.Encode(struct {
+		pingResult
+		RTT   float64 `json:"rtt_ms"`
+		Error string  `json:"error,omitempty"`
+	}{r, ms(r.RTT), errMsg})
+}
+
+type csvWriter struct {
+	mu sync.Mutex
+	w  *csv.Writer
+}
+
+func (c *csvWriter) header() error {
+	return c.writeRecord([]string{
+		"time", "target", "seq", "address", "family", "rtt_ms", "timeout", "error",
+		"status", "tls_version", "cert_not_after", "phases",
+	})
+}
+
+func (c *csvWriter) write(r pingResult) error {
+	var errMsg, status, tlsVersion, notAfter string
+	if r.Err != nil {
+		errMsg = r.Err.Error()
+	}
+	if r.Status != 0 {
+		status = strconv.Itoa(r.Status)
+	}
+	if r.TLS != nil {
+		tlsVersion = r.TLS.Version
+		notAfter = r.TLS.NotAfter.Format(time.RFC3339)
+	}
+
+	return c.writeRecord([]string{
+		r.Time.Format(time.RFC3339Nano),
+		r.Target,
+		strconv.Itoa(r.Seq),
+		r.Address,
+		r.Family,
+		strconv.FormatFloat(ms(r.RTT), 'f', 3, 64),
+		strconv.FormatBool(r.Timeout),
+		errMsg,
+		status,
+		tlsVersion,
+		notAfter,
+		formatPhases(r.Phases),
+	})
+}
+
+// 결과를 바로 볼 수 있도록 줄마다 내보낸다.
+func (c *csvWriter) writeRecord(record []string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	err := c.w.Write(record)
+	if err != nil {
+		return err
+	}
+	c.w.Flush()
+
+	return c.w.Error()
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 프로브 방식
+const (
+	PROBE_TCP uint8 = iota
+	PROBE_TLS
+	PROBE_HTTP
+	PROBE_UDP
+)
+
+// 프로브 한 단계에 걸린 시간
+type pingPhase struct {
+	Name     string
+	Duration time.Duration
+}
+
+// 서버 인증서 정보
+type tlsInfo struct {
+	Version   string    `json:"version"`
+	Subject   string    `json:"subject,omitempty"`
+	Issuer    string    `json:"issuer,omitempty"`
+	NotBefore time.Time `json:"not_before"`
+	NotAfter  time.Time `json:"not_after"`
+}
+
+func newTLSInfo(state tls.ConnectionState) *tlsInfo {
+	info := &tlsInfo{Version: tls.VersionName(state.Version)}
+	if len(state.PeerCertificates) > 0 {
+		cert := state.PeerCertificates[0]
+		info.Subject = cert.Subject.CommonName
+		if info.Subject == "" && len(cert.DNSNames) > 0 {
+			info.Subject = cert.DNSNames[0]
+		}
+		info.Issuer = cert.Issuer.CommonName
+		info.NotBefore, info.NotAfter = cert.NotBefore, cert.NotAfter
+	}
+
+	return info
+}
+
+func (p *pinger) probe(ctx context.Context, target string, seq int) pingResult {
+	r := pingResult{Time: time.Now(), Target: target, Seq: seq}
+
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	var err error
+	switch p.mode {
+	case PROBE_TLS:
+		err = p.probeTLS(ctx, target, &r)
+	case PROBE_HTTP:
+		err = p.probeHTTP(ctx, target, &r)
+	case PROBE_UDP:
+		err = p.probeUDP(ctx, target, &r)
+	default:
+		var c net.Conn
+		c, err = p.dial(ctx, "tcp", target, &r)
+		if err == nil {
+			_ = c.Close()
+		}
+	}
+
+	r.RTT = time.Since(r.Time)
+	if err != nil {
+		r.Err = err
+		var nErr net.Error
+		r.Timeout = errors.Is(err, context.DeadlineExceeded) || errors.As(err, &nErr) && nErr.Timeout()
+	}
+
+	return r
+}
+
+func (r *pingResult) phase(name string, start time.Time) {
+	r.Phases = append(r.Phases, pingPhase{Name: name, Duration: time.Since(start)})
+}
+
+func (r *pingResult) setAddress(addr string) {
+	r.Address = addr
+
+	ap, err := netip.ParseAddrPort(addr)
+	if err != nil {
+		return
+	}
+	r.Family = "ipv6"
+	if ap.Addr().Unmap().Is4() {
+		r.Family = "ipv4"
+	}
+}
+
+// 이름을 찾은 다음 주소를 차례로 시도한다. 두 단계의 시간을 따로 잰다.
+func (p *pinger) dial(ctx context.Context, network, target string, r *pingResult) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(target)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	addrs, err := lookup(ctx, network, host, port)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := netip.ParseAddr(host); err != nil {
+		r.phase("dns", start)
+	}
+
+	start = time.Now()
+	var d net.Dialer
+	var c net.Conn
+	for _, addr := range addrs {
+		c, err = d.DialContext(ctx, network, addr.String())
+		if err == nil {
+			break
+		}
+		// 실패해도 마지막으로 시도한 주소를 알 수 있다.
+		r.setAddress(addr.String())
+	}
+	if err != nil {
+		return nil, err
+	}
+	r.setAddress(c.RemoteAddr().String())
+	if network == "tcp" {
+		r.phase("connect", start)
+	}
+
+	return c, nil
+}
+
+func lookup(ctx context.Context, network, host, port string) ([]netip.AddrPort, error) {
+	n, err := net.DefaultResolver.LookupPort(ctx, network, port)
+	if err != nil {
+		return nil, err
+	}
+
+	ips, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs := make([]netip.AddrPort, 0, len(ips))
+	for _, ip := range ips {
+		addrs = append(addrs, netip.AddrPortFrom(ip.Unmap(), uint16(n)))
+	}
+
+	return addrs, nil
+}
+
+func (p *pinger) probeTLS(ctx context.Context, target string, r *pingResult) error {
+	c, err := p.dial(ctx, "tcp", target, r)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	cfg := p.tlsConfig.Clone()
+	if cfg == nil {
+		cfg = new(tls.Config)
+	}
+	if cfg.ServerName == "" {
+		cfg.ServerName, _, _ = net.SplitHostPort(target)
+	}
+
+	start := time.Now()
+	tc := tls.Client(c, cfg)
+	err = tc.HandshakeContext(ctx)
+	if err != nil {
+		return err
+	}
+	r.phase("tls", start)
+	r.TLS = newTLSInfo(tc.ConnectionState())
+
+	return nil
+}
+
+// 상태 코드가 400 이상이면 실패로 센다.
+func (p *pinger) probeHTTP(ctx context.Context, target string, r *pingResult) error {
+	if !strings.Contains(target, "://") {
+		target = "http://" + target
+	}
+
+	// 추적 함수는 다른 고루틴에서 호출할 수 있고 Do가 반환한 뒤에 호출될 수도 있다.
+	var mu sync.Mutex
+	done := false
+	record := func(f func()) {
+		mu.Lock()
+		defer mu.Unlock()
+		if !done {
+			f()
+		}
+	}
+
+	var dnsStart, connectStart, tlsStart, wroteRequest time.Time
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			record(func() { dnsStart = time.Now() })
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			record(func() { r.phase("dns", dnsStart) })
+		},
+		ConnectStart: func(_, addr string) {
+			record(func() {
+				connectStart = time.Now()
+				r.setAddress(addr)
+			})
+		},
+		ConnectDone: func(_, _ string, err error) {
+			if err == nil {
+				record(func() { r.phase("connect", connectStart) })
+			}
+		},
+		TLSHandshakeStart: func() {
+			record(func() { tlsStart = time.Now() })
+		},
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			if err == nil {
+				record(func() {
+					r.phase("tls", tlsStart)
+					r.TLS = newTLSInfo(state)
+				})
+			}
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			record(func() { r.setAddress(info.Conn.RemoteAddr().String()) })
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			record(func() { wroteRequest = time.Now() })
+		},
+		GotFirstResponseByte: func() {
+			record(func() { r.phase("ttfb", wroteRequest) })
+		},
+	}
+
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace), http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+
+	// 프로브마다 새로 연결한다.
+	transport := &http.Transport{
+		Proxy:             http.ProxyFromEnvironment,
+		TLSClientConfig:   p.tlsConfig,
+		DisableKeepAlives: true,
+	}
+	defer transport.CloseIdleConnections()
+
+	resp, err := (&http.Client{Transport: transport}).Do(req)
+	record(func() { done = true })
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	start := time.Now()
+	_, err = io.Copy(io.Discard, resp.Body)
+	if err != nil {
+		return err
+	}
+	r.phase("body", start)
+
+	r.Status = resp.StatusCode
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("status %s", resp.Status)
+	}
+
+	return nil
+}
+
+// 데이터그램을 보내고 같은 내용이 돌아오기를 기다린다.
+func (p *pinger) probeUDP(ctx context.Context, target string, r *pingResult) error {
+	c, err := p.dial(ctx, "udp", target, r)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = c.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() {
+		_ = c.SetDeadline(time.Unix(1, 0))
+	})
+	defer stop()
+
+	msg := fmt.Appendf(nil, "ping %d %d", r.Seq, r.Time.UnixNano())
+
+	start := time.Now()
+	_, err = c.Write(msg)
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, 1024)
+	for {
+		n, err := c.Read(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		// 보낸 내용과 다른 데이터그램은 건너뛴다.
+		if bytes.Equal(buf[:n], msg) {
+			r.phase("echo", start)
+			return nil
+		}
+	}
+}
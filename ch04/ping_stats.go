@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// 프로브 결과를 모아 ping(8) 형식의 요약을 만든다.
+type pingStats struct {
+	sent     int
+	received int
+	min, max time.Duration
+	// 표준 편차를 구하기 위한 합
+	sum, sumSq float64
+}
+
+func (s *pingStats) add(rtt time.Duration, err error) {
+	s.sent++
+	if err != nil {
+		return
+	}
+
+	s.received++
+	if s.received == 1 || rtt < s.min {
+		s.min = rtt
+	}
+	s.max = max(s.max, rtt)
+
+	f := float64(rtt)
+	s.sum += f
+	s.sumSq += f * f
+}
+
+// 응답을 받지 못한 프로브의 비율(%)
+func (s *pingStats) loss() float64 {
+	if s.sent == 0 {
+		return 0
+	}
+
+	return 100 * float64(s.sent-s.received) / float64(s.sent)
+}
+
+func (s *pingStats) avg() time.Duration {
+	if s.received == 0 {
+		return 0
+	}
+
+	return time.Duration(s.sum / float64(s.received))
+}
+
+func (s *pingStats) stddev() time.Duration {
+	if s.received == 0 {
+		return 0
+	}
+
+	mean := s.sum / float64(s.received)
+	// 부동소수점 오차로 음수가 될 수 있다.
+	variance := max(s.sumSq/float64(s.received)-mean*mean, 0)
+
+	return time.Duration(math.Sqrt(variance))
+}
+
+func (s *pingStats) summary(w io.Writer, target string, elapsed time.Duration) {
+	_, _ = fmt.Fprintf(w, "--- %s ping statistics ---\n", target)
+	_, _ = fmt.Fprintf(w, "%d probes sent, %d received, %.1f%% loss, time %s\n",
+		s.sent, s.received, s.loss(), elapsed.Round(time.Millisecond))
+	if s.received > 0 {
+		_, _ = fmt.Fprintf(w, "rtt min/avg/max/stddev = %.3f/%.3f/%.3f/%.3f ms\n",
+			ms(s.min), ms(s.avg()), ms(s.max), ms(s.stddev()))
+	}
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
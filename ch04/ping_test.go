@@ -0,0 +1,401 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPingStats(t *testing.T) {
+	s := new(pingStats)
+	for _, rtt := range []time.Duration{2 * time.Millisecond, 4 * time.Millisecond, 6 * time.Millisecond} {
+		s.add(rtt, nil)
+	}
+	s.add(0, errors.New("refused"))
+
+	if s.sent != 4 || s.received != 3 {
+		t.Errorf("expected 4 sent, 3 received; actual %d sent, %d received", s.sent, s.received)
+	}
+	if s.loss() != 25 {
+		t.Errorf("expected 25%% loss; actual %.1f%%", s.loss())
+	}
+	if s.min != 2*time.Millisecond || s.max != 6*time.Millisecond || s.avg() != 4*time.Millisecond {
+		t.Errorf("unexpected min/avg/max %s/%s/%s", s.min, s.avg(), s.max)
+	}
+	// sqrt(((2-4)^2 + 0 + (6-4)^2) / 3) ms
+	if d := s.stddev() - 1632993*time.Nanosecond; d < -time.Microsecond || d > time.Microsecond {
+		t.Errorf("expected stddev 1.633ms; actual %s", s.stddev())
+	}
+
+	buf := new(bytes.Buffer)
+	s.summary(buf, "example:80", time.Second)
+	expected := "--- example:80 ping statistics ---\n" +
+		"4 probes sent, 3 received, 25.0% loss, time 1s\n" +
+		"rtt min/avg/max/stddev = 2.000/4.000/6.000/1.633 ms\n"
+	if buf.String() != expected {
+		t.Errorf("expected\n%s\nactual\n%s", expected, buf)
+	}
+}
+
+func TestPingStatsNoReplies(t *testing.T) {
+	s := new(pingStats)
+	s.add(time.Second, errors.New("timeout"))
+
+	buf := new(bytes.Buffer)
+	s.summary(buf, "example:80", time.Second)
+	if s.loss() != 100 || strings.Contains(buf.String(), "rtt") {
+		t.Errorf("unexpected summary:\n%s", buf)
+	}
+}
+
+type collectWriter struct {
+	mu      sync.Mutex
+	results []pingResult
+}
+
+func (c *collectWriter) write(r pingResult) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.results = append(c.results, r)
+
+	return nil
+}
+
+func newPinger(count int, interval time.Duration) (*pinger, *collectWriter) {
+	out := new(collectWriter)
+
+	return &pinger{
+		count:    count,
+		interval: interval,
+		timeout:  time.Second,
+		sem:      make(chan struct{}, 2),
+		out:      out,
+	}, out
+}
+
+func listen(t *testing.T, network string) net.Listener {
+	t.Helper()
+
+	address := "127.0.0.1:"
+	if network == "tcp6" {
+		address = "[::1]:"
+	}
+	l, err := net.Listen(network, address)
+	if err != nil {
+		t.Skip(err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			_ = c.Close()
+		}
+	}()
+
+	return l
+}
+
+func TestPing(t *testing.T) {
+	tcp4 := listen(t, "tcp4").Addr().String()
+	tcp6 := listen(t, "tcp6").Addr().String()
+
+	// 연결을 거부해도 멈추지 않고 실패로 센다.
+	l, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	refused := l.Addr().String()
+	_ = l.Close()
+
+	p, out := newPinger(3, time.Millisecond)
+	targets := []string{tcp4, tcp6, refused}
+	stats := p.run(context.Background(), targets)
+
+	expected := []struct {
+		received int
+		family   string
+	}{{3, "ipv4"}, {3, "ipv6"}, {0, "ipv4"}}
+	for i, s := range stats {
+		if s.sent != 3 || s.received != expected[i].received {
+			t.Errorf("%s: expected %d of 3 replies; actual %d of %d",
+				targets[i], expected[i].received, s.received, s.sent)
+		}
+	}
+
+	if len(out.results) != 9 {
+		t.Fatalf("expected 9 results; actual %d", len(out.results))
+	}
+	for _, r := range out.results {
+		i := slices.Index(targets, r.Target)
+		if r.Address != targets[i] || r.Family != expected[i].family {
+			t.Errorf("%s: unexpected address %q family %q", r.Target, r.Address, r.Family)
+		}
+		if (r.Err == nil) != (i != 2) {
+			t.Errorf("%s: unexpected error %v", r.Target, r.Err)
+		}
+	}
+}
+
+func TestPingCanceled(t *testing.T) {
+	target := listen(t, "tcp4").Addr().String()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	// count가 0이면 ctx가 끝날 때까지 계속한다.
+	p, _ := newPinger(0, time.Hour)
+	s := p.run(ctx, []string{target})[0]
+	if s.sent != 1 || s.received != 1 {
+		t.Errorf("expected a single reply before cancellation; actual %d of %d", s.received, s.sent)
+	}
+}
+
+func TestResultWriter(t *testing.T) {
+	results := []pingResult{
+		{
+			Time:    time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+			Target:  "localhost:80",
+			Seq:     1,
+			Address: "127.0.0.1:80",
+			Family:  "ipv4",
+			RTT:     1500 * time.Microsecond,
+			Phases: []pingPhase{
+				{"dns", 500 * time.Microsecond},
+				{"connect", time.Millisecond},
+			},
+			Status: http.StatusOK,
+			TLS: &tlsInfo{
+				Version:  "TLS 1.3",
+				NotAfter: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			Time:    time.Date(2024, 1, 2, 3, 4, 6, 0, time.UTC),
+			Target:  "localhost:80",
+			Seq:     2,
+			RTT:     time.Second,
+			Err:     errors.New("i/o timeout"),
+			Timeout: true,
+		},
+	}
+
+	tests := []struct {
+		format   string
+		expected string
+	}{
+		{
+			"table",
+			"TARGET         SEQ ADDRESS                                         FAMILY          RTT  RESULT\n" +
+				"localhost:80     1 127.0.0.1:80                                    ipv4          1.5ms  ok " +
+				"status=200 tls=TLS1.3 expires=2025-01-01 dns=0.500ms connect=1.000ms\n" +
+				"localhost:80     2 -                                               -                1s  timeout\n",
+		},
+		{
+			"json",
+			`{"time":"2024-01-02T03:04:05Z","target":"localhost:80","seq":1,"address":"127.0.0.1:80","family":"ipv4",` +
+				`"phases":[{"name":"dns","ms":0.5},{"name":"connect","ms":1}],"status":200,` +
+				`"tls":{"version":"TLS 1.3","not_before":"0001-01-01T00:00:00Z","not_after":"2025-01-01T00:00:00Z"},"rtt_ms":1.5}` + "\n" +
+				`{"time":"2024-01-02T03:04:06Z","target":"localhost:80","seq":2,"timeout":true,"rtt_ms":1000,"error":"i/o timeout"}` + "\n",
+		},
+		{
+			"csv",
+			"time,target,seq,address,family,rtt_ms,timeout,error,status,tls_version,cert_not_after,phases\n" +
+				"2024-01-02T03:04:05Z,localhost:80,1,127.0.0.1:80,ipv4,1.500,false,,200,TLS 1.3,2025-01-01T00:00:00Z,dns=0.500ms connect=1.000ms\n" +
+				"2024-01-02T03:04:06Z,localhost:80,2,,,1000.000,true,i/o timeout,,,,\n",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.format, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			w, err := newResultWriter(tc.format, buf, []string{"localhost:80"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, r := range results {
+				err = w.write(r)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if buf.String() != tc.expected {
+				t.Errorf("expected\n%s\nactual\n%s", tc.expected, buf)
+			}
+		})
+	}
+
+	_, err := newResultWriter("xml", io.Discard, nil)
+	if err == nil {
+		t.Error("expected unknown format error")
+	}
+}
+
+func TestReadTargets(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "targets")
+	err := os.WriteFile(name, []byte("# services\nexample.com:443\n\n  [::1]:22  \n"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	targets, err := readTargets(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(targets, []string{"example.com:443", "[::1]:22"}) {
+		t.Errorf("unexpected targets %q", targets)
+	}
+}
+
+func phaseNames(r pingResult) []string {
+	var names []string
+	for _, p := range r.Phases {
+		names = append(names, p.Name)
+	}
+
+	return names
+}
+
+func TestProbeTCP(t *testing.T) {
+	port := listen(t, "tcp4").Addr().(*net.TCPAddr).Port
+
+	p, _ := newPinger(1, 0)
+	tests := []struct {
+		target string
+		phases []string
+	}{
+		{net.JoinHostPort("127.0.0.1", strconv.Itoa(port)), []string{"connect"}},
+		{net.JoinHostPort("localhost", strconv.Itoa(port)), []string{"dns", "connect"}},
+	}
+
+	for _, tc := range tests {
+		r := p.probe(context.Background(), tc.target, 1)
+		if r.Err != nil {
+			t.Fatal(r.Err)
+		}
+		if !slices.Equal(phaseNames(r), tc.phases) {
+			t.Errorf("%s: expected phases %q; actual %q", tc.target, tc.phases, phaseNames(r))
+		}
+	}
+}
+
+func TestProbeTLS(t *testing.T) {
+	ts := httptest.NewTLSServer(http.NotFoundHandler())
+	defer ts.Close()
+
+	p, _ := newPinger(1, 0)
+	p.mode = PROBE_TLS
+
+	// 신뢰하지 않는 인증서는 실패로 센다.
+	r := p.probe(context.Background(), ts.Listener.Addr().String(), 1)
+	if r.Err == nil {
+		t.Error("expected certificate verification error")
+	}
+
+	p.tlsConfig = ts.Client().Transport.(*http.Transport).TLSClientConfig
+	r = p.probe(context.Background(), ts.Listener.Addr().String(), 1)
+	if r.Err != nil {
+		t.Fatal(r.Err)
+	}
+	if !slices.Equal(phaseNames(r), []string{"connect", "tls"}) {
+		t.Errorf("unexpected phases %q", phaseNames(r))
+	}
+	cert := ts.Certificate()
+	if r.TLS == nil || r.TLS.Version == "" || !r.TLS.NotAfter.Equal(cert.NotAfter) {
+		t.Errorf("unexpected certificate info %+v", r.TLS)
+	}
+}
+
+func TestProbeHTTP(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/fail" {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+			_, _ = io.WriteString(w, "hello")
+		},
+	))
+	defer ts.Close()
+
+	p, _ := newPinger(1, 0)
+	p.mode = PROBE_HTTP
+	p.tlsConfig = ts.Client().Transport.(*http.Transport).TLSClientConfig
+
+	r := p.probe(context.Background(), ts.URL, 1)
+	if r.Err != nil {
+		t.Fatal(r.Err)
+	}
+	if r.Status != http.StatusOK || r.TLS == nil {
+		t.Errorf("unexpected result %+v", r)
+	}
+	if !slices.Equal(phaseNames(r), []string{"connect", "tls", "ttfb", "body"}) {
+		t.Errorf("unexpected phases %q", phaseNames(r))
+	}
+
+	r = p.probe(context.Background(), ts.URL+"/fail", 1)
+	if r.Err == nil || r.Status != http.StatusServiceUnavailable {
+		t.Errorf("expected failure with status 503; actual %d: %v", r.Status, r.Err)
+	}
+}
+
+func TestProbeUDP(t *testing.T) {
+	s, err := net.ListenPacket("udp", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			n, addr, err := s.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			// 엉뚱한 데이터그램을 먼저 보내도 건너뛰어야 한다.
+			_, _ = s.WriteTo([]byte("noise"), addr)
+			_, _ = s.WriteTo(buf[:n], addr)
+		}
+	}()
+
+	p, _ := newPinger(1, 0)
+	p.mode = PROBE_UDP
+
+	r := p.probe(context.Background(), s.LocalAddr().String(), 1)
+	if r.Err != nil {
+		t.Fatal(r.Err)
+	}
+	if !slices.Equal(phaseNames(r), []string{"echo"}) || r.Address != s.LocalAddr().String() {
+		t.Errorf("unexpected result %+v", r)
+	}
+
+	// 응답하지 않으면 시간 초과로 센다.
+	silent, err := net.ListenPacket("udp", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer silent.Close()
+
+	p.timeout = 100 * time.Millisecond
+	r = p.probe(context.Background(), silent.LocalAddr().String(), 1)
+	if !r.Timeout {
+		t.Errorf("expected timeout; actual %v", r.Err)
+	}
+}
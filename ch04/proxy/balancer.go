@@ -0,0 +1,233 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 백엔드 선택 방식
+const (
+	ROUND_ROBIN uint8 = iota
+	LEAST_CONNECTIONS
+	// 무작위로 고른 두 백엔드 중 연결이 적은 쪽을 사용한다.
+	TWO_CHOICES
+)
+
+const (
+	DEFAULT_HEALTH_INTERVAL = 5 * time.Second
+	DEFAULT_HEALTH_TIMEOUT  = 2 * time.Second
+)
+
+var ErrNoBackend = errors.New("no healthy backend")
+
+type Backend struct {
+	address string
+	active  atomic.Int64
+	healthy atomic.Bool
+}
+
+func (b *Backend) Address() string {
+	return b.address
+}
+
+// 중계 중인 연결 수
+func (b *Backend) Active() int64 {
+	return b.active.Load()
+}
+
+func (b *Backend) Healthy() bool {
+	return b.healthy.Load()
+}
+
+// 여러 백엔드에 연결을 나눈다. Dial을 Server.Dial로 사용한다.
+type Balancer struct {
+	Strategy uint8
+	// 0이면 기본값을 사용한다.
+	HealthInterval time.Duration
+	HealthTimeout  time.Duration
+	// 상태 검사 결과가 바뀔 때 호출한다.
+	OnHealthChange func(b *Backend)
+
+	backends []*Backend
+	next     atomic.Uint64
+}
+
+func NewBalancer(strategy uint8, addresses ...string) (*Balancer, error) {
+	if strategy > TWO_CHOICES {
+		return nil, fmt.Errorf("invalid strategy %d", strategy)
+	}
+	if len(addresses) == 0 {
+		return nil, errors.New("no backends")
+	}
+
+	b := &Balancer{Strategy: strategy}
+	for _, addr := range addresses {
+		backend := &Backend{address: addr}
+		// 상태 검사 전에는 정상으로 본다.
+		backend.healthy.Store(true)
+		b.backends = append(b.backends, backend)
+	}
+
+	return b, nil
+}
+
+func (b *Balancer) Backends() []*Backend {
+	return b.backends
+}
+
+// address는 무시하고 백엔드를 골라 연결한다.
+// 연결에 실패하면 아직 시도하지 않은 다른 백엔드를 시도한다.
+func (b *Balancer) Dial(ctx context.Context, network, _ string) (net.Conn, error) {
+	var (
+		d     net.Dialer
+		tried = make(map[*Backend]bool)
+		errs  []error
+	)
+
+	for {
+		backend := b.pick(tried)
+		if backend == nil {
+			if len(errs) == 0 {
+				return nil, ErrNoBackend
+			}
+			return nil, errors.Join(errs...)
+		}
+		tried[backend] = true
+
+		backend.active.Add(1)
+		conn, err := d.DialContext(ctx, network, backend.address)
+		if err != nil {
+			backend.active.Add(-1)
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			errs = append(errs, fmt.Errorf("dialing %s: %w", backend.address, err))
+			continue
+		}
+
+		return &backendConn{Conn: conn, backend: backend}, nil
+	}
+}
+
+func (b *Balancer) pick(exclude map[*Backend]bool) *Backend {
+	candidates := make([]*Backend, 0, len(b.backends))
+	for _, backend := range b.backends {
+		if backend.Healthy() && !exclude[backend] {
+			candidates = append(candidates, backend)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	switch b.Strategy {
+	case LEAST_CONNECTIONS:
+		// 연결 수가 같으면 차례대로 고른다.
+		offset := int((b.next.Add(1) - 1) % uint64(len(candidates)))
+		best := candidates[offset]
+		for i := 1; i < len(candidates); i++ {
+			c := candidates[(offset+i)%len(candidates)]
+			if c.Active() < best.Active() {
+				best = c
+			}
+		}
+		return best
+	case TWO_CHOICES:
+		if len(candidates) == 1 {
+			return candidates[0]
+		}
+		i := rand.IntN(len(candidates))
+		j := rand.IntN(len(candidates) - 1)
+		if j >= i {
+			j++
+		}
+		if candidates[j].Active() < candidates[i].Active() {
+			return candidates[j]
+		}
+		return candidates[i]
+	}
+
+	return candidates[int((b.next.Add(1)-1)%uint64(len(candidates)))]
+}
+
+// ctx가 취소될 때까지 주기적으로 백엔드에 TCP로 연결해 상태를 확인한다.
+// 연결할 수 없는 백엔드는 다시 연결될 때까지 선택하지 않는다.
+func (b *Balancer) HealthCheck(ctx context.Context) {
+	interval := b.HealthInterval
+	if interval <= 0 {
+		interval = DEFAULT_HEALTH_INTERVAL
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		b.checkAll(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (b *Balancer) checkAll(ctx context.Context) {
+	timeout := b.HealthTimeout
+	if timeout <= 0 {
+		timeout = DEFAULT_HEALTH_TIMEOUT
+	}
+
+	var wg sync.WaitGroup
+	for _, backend := range b.backends {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			checkCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			var d net.Dialer
+			conn, err := d.DialContext(checkCtx, "tcp", backend.address)
+			if err == nil {
+				_ = conn.Close()
+			} else if ctx.Err() != nil {
+				// 종료 중에는 상태를 바꾸지 않는다.
+				return
+			}
+
+			healthy := err == nil
+			if backend.healthy.Swap(healthy) != healthy && b.OnHealthChange != nil {
+				b.OnHealthChange(backend)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// 연결이 닫히면 백엔드의 연결 수를 줄인다.
+type backendConn struct {
+	net.Conn
+	backend *Backend
+	once    sync.Once
+}
+
+func (c *backendConn) CloseWrite() error {
+	if cw, ok := c.Conn.(interface{ CloseWrite() error }); ok {
+		return cw.CloseWrite()
+	}
+
+	return c.Close()
+}
+
+func (c *backendConn) Close() error {
+	c.once.Do(func() { c.backend.active.Add(-1) })
+
+	return c.Conn.Close()
+}
@@ -0,0 +1,253 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// 연결을 받고 닫지 않는 백엔드
+func backend(t *testing.T) net.Listener {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+
+	go func() {
+		var conns []net.Conn
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				// 리스너를 닫으면 받은 연결도 닫는다.
+				for _, c := range conns {
+					_ = c.Close()
+				}
+				return
+			}
+			conns = append(conns, conn)
+		}
+	}()
+
+	return l
+}
+
+func backends(t *testing.T, n int) []string {
+	t.Helper()
+
+	addrs := make([]string, n)
+	for i := range addrs {
+		addrs[i] = backend(t).Addr().String()
+	}
+
+	return addrs
+}
+
+func TestRoundRobin(t *testing.T) {
+	addrs := backends(t, 3)
+	b, err := NewBalancer(ROUND_ROBIN, addrs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 6; i++ {
+		conn, err := b.Dial(context.Background(), "tcp", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if actual := conn.RemoteAddr().String(); actual != addrs[i%3] {
+			t.Errorf("%d: expected %s; actual %s", i, addrs[i%3], actual)
+		}
+		_ = conn.Close()
+	}
+}
+
+func TestLeastConnections(t *testing.T) {
+	addrs := backends(t, 3)
+	b, err := NewBalancer(LEAST_CONNECTIONS, addrs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conns := make([]net.Conn, 3)
+	for i := range conns {
+		conns[i], err = b.Dial(context.Background(), "tcp", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, backend := range b.Backends() {
+		if backend.Active() != 1 {
+			t.Errorf("%s: expected 1 active connection; actual %d", backend.Address(), backend.Active())
+		}
+	}
+
+	// 연결이 끝난 백엔드가 다음 연결을 받는다.
+	freed := conns[1].RemoteAddr().String()
+	_ = conns[1].Close()
+	_ = conns[1].Close()
+
+	conn, err := b.Dial(context.Background(), "tcp", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := conn.RemoteAddr().String(); actual != freed {
+		t.Errorf("expected %s; actual %s", freed, actual)
+	}
+	if b.Backends()[1].Active() != 1 {
+		t.Errorf("expected closing twice to be counted once; active %d", b.Backends()[1].Active())
+	}
+}
+
+func TestTwoChoices(t *testing.T) {
+	addrs := backends(t, 2)
+	b, err := NewBalancer(TWO_CHOICES, addrs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 후보가 둘뿐이므로 항상 연결이 적은 쪽을 고른다.
+	for i := 0; i < 10; i++ {
+		_, err := b.Dial(context.Background(), "tcp", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, backend := range b.Backends() {
+		if backend.Active() != 5 {
+			t.Errorf("%s: expected 5 active connections; actual %d", backend.Address(), backend.Active())
+		}
+	}
+}
+
+func TestDialFailover(t *testing.T) {
+	dead, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadAddr := dead.Addr().String()
+	_ = dead.Close()
+
+	alive := backend(t).Addr().String()
+
+	b, err := NewBalancer(ROUND_ROBIN, deadAddr, alive)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := b.Dial(context.Background(), "tcp", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := conn.RemoteAddr().String(); actual != alive {
+		t.Errorf("expected %s; actual %s", alive, actual)
+	}
+}
+
+func TestHealthCheck(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	_ = l.Close()
+
+	b, err := NewBalancer(ROUND_ROBIN, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.HealthInterval = 20 * time.Millisecond
+
+	changes := make(chan bool, 2)
+	b.OnHealthChange = func(backend *Backend) { changes <- backend.Healthy() }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go b.HealthCheck(ctx)
+
+	select {
+	case healthy := <-changes:
+		if healthy {
+			t.Fatal("expected backend to be marked down")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("backend was not marked down")
+	}
+
+	_, err = b.Dial(context.Background(), "tcp", "")
+	if !errors.Is(err, ErrNoBackend) {
+		t.Errorf("expected ErrNoBackend; actual %v", err)
+	}
+
+	// 같은 주소에서 다시 시작하면 복구된다.
+	l, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer l.Close()
+
+	select {
+	case healthy := <-changes:
+		if !healthy {
+			t.Fatal("expected backend to be marked up")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("backend was not marked up")
+	}
+}
+
+func TestServeBalanced(t *testing.T) {
+	addrs := []string{echoAfterEOF(t).Addr().String(), echoAfterEOF(t).Addr().String()}
+	b, err := NewBalancer(ROUND_ROBIN, addrs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	targets := make(chan string, 2)
+	s := &Server{
+		Dial:    b.Dial,
+		OnClose: func(c ConnStats) { targets <- c.Target.String() },
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.Serve(ctx, l) }()
+
+	for i := 0; i < 2; i++ {
+		conn, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = conn.(*net.TCPConn).CloseWrite()
+		buf := make([]byte, 1)
+		_, _ = conn.Read(buf)
+		_ = conn.Close()
+
+		if actual := <-targets; actual != addrs[i] {
+			t.Errorf("%d: expected %s; actual %s", i, addrs[i], actual)
+		}
+	}
+
+	for _, backend := range b.Backends() {
+		if backend.Active() != 0 {
+			t.Errorf("%s: expected no active connections; actual %d", backend.Address(), backend.Active())
+		}
+	}
+
+	cancel()
+	err = <-done
+	if err != nil {
+		t.Error(err)
+	}
+}
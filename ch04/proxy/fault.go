@@ -0,0 +1,318 @@
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 장애를 적용할 방향
+const (
+	// 클라이언트 -> 대상
+	UPSTREAM uint8 = iota
+	// 대상 -> 클라이언트
+	DOWNSTREAM
+)
+
+var ErrInjected = errors.New("injected fault")
+
+// 한 방향에 적용할 장애. 0이면 적용하지 않는다.
+// 확률은 0과 1 사이의 값이고 Write 호출마다 적용한다.
+type Faults struct {
+	Latency time.Duration
+	// Latency에 -Jitter ~ +Jitter를 더한다.
+	Jitter time.Duration
+	// 초당 바이트
+	Bandwidth int64
+	// 지정한 바이트를 전달한 후 연결을 닫거나(FIN) 재설정한다(RST).
+	DropAfter  int64
+	ResetAfter int64
+	DropRate   float64
+	ResetRate  float64
+	// 바이트마다 비트 하나를 뒤집을 확률
+	CorruptRate float64
+}
+
+// Server.Faults로 사용한다. 장애는 실행 중에 바꿀 수 있고
+// 이미 중계 중인 연결에도 다음 Write부터 적용한다.
+type FaultInjector struct {
+	mu     sync.RWMutex
+	faults [2]Faults
+}
+
+func (f *FaultInjector) Set(direction uint8, faults Faults) {
+	f.mu.Lock()
+	f.faults[direction&1] = faults
+	f.mu.Unlock()
+}
+
+func (f *FaultInjector) Get(direction uint8) Faults {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return f.faults[direction&1]
+}
+
+// 모든 장애를 없앤다.
+func (f *FaultInjector) Reset() {
+	f.mu.Lock()
+	f.faults = [2]Faults{}
+	f.mu.Unlock()
+}
+
+func (f *FaultInjector) wrap(client, target net.Conn) (net.Conn, net.Conn) {
+	up := &faultConn{Conn: target, peer: client, injector: f, direction: UPSTREAM}
+	down := &faultConn{Conn: client, peer: target, injector: f, direction: DOWNSTREAM}
+
+	return down, up
+}
+
+// Write에 장애를 적용한다. 한 방향은 한 고루틴에서만 쓰므로 잠그지 않는다.
+type faultConn struct {
+	net.Conn
+	peer      net.Conn
+	injector  *FaultInjector
+	direction uint8
+
+	written int64
+}
+
+func (c *faultConn) CloseWrite() error {
+	if cw, ok := c.Conn.(interface{ CloseWrite() error }); ok {
+		return cw.CloseWrite()
+	}
+
+	return c.Close()
+}
+
+func (c *faultConn) Write(p []byte) (int, error) {
+	f := c.injector.Get(c.direction)
+
+	delay := f.Latency
+	if f.Jitter > 0 {
+		delay += rand.N(2*f.Jitter+1) - f.Jitter
+	}
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+
+	// 지정한 바이트까지 전달한 후에 적용할 장애
+	var after func() error
+	switch {
+	case f.ResetRate > 0 && rand.Float64() < f.ResetRate:
+		return 0, c.reset()
+	case f.DropRate > 0 && rand.Float64() < f.DropRate:
+		return 0, c.drop()
+	case f.ResetAfter > 0 && c.written+int64(len(p)) >= f.ResetAfter:
+		p, after = p[:max(f.ResetAfter-c.written, 0)], c.reset
+	case f.DropAfter > 0 && c.written+int64(len(p)) >= f.DropAfter:
+		p, after = p[:max(f.DropAfter-c.written, 0)], c.drop
+	}
+
+	if f.CorruptRate > 0 {
+		p = corrupt(p, f.CorruptRate)
+	}
+
+	n, err := c.throttle(p, f.Bandwidth)
+	if err != nil {
+		return n, err
+	}
+	if after != nil {
+		return n, after()
+	}
+
+	return n, nil
+}
+
+// 대역폭을 넘지 않도록 나눠서 기록한다.
+func (c *faultConn) throttle(p []byte, bandwidth int64) (int, error) {
+	if bandwidth <= 0 {
+		n, err := c.Conn.Write(p)
+		c.written += int64(n)
+		return n, err
+	}
+
+	// 0.1초 분량씩 기록한다.
+	size := max(bandwidth/10, 1)
+	total := 0
+	for len(p) > 0 {
+		chunk := p[:min(int64(len(p)), size)]
+		n, err := c.Conn.Write(chunk)
+		total += n
+		c.written += int64(n)
+		if err != nil {
+			return total, err
+		}
+		p = p[n:]
+
+		time.Sleep(time.Duration(int64(n) * int64(time.Second) / bandwidth))
+	}
+
+	return total, nil
+}
+
+func (c *faultConn) drop() error {
+	_ = c.Conn.Close()
+	_ = c.peer.Close()
+
+	return fmt.Errorf("%w: drop after %d bytes", ErrInjected, c.written)
+}
+
+func (c *faultConn) reset() error {
+	conns := []net.Conn{c.Conn, c.peer}
+	// 반대 방향이 먼저 연결을 닫을 수 있으므로 닫기 전에 모두 설정한다.
+	for _, conn := range conns {
+		// 닫을 때 RST를 보낸다.
+		if tcp, ok := conn.(*net.TCPConn); ok {
+			_ = tcp.SetLinger(0)
+		}
+	}
+	for _, conn := range conns {
+		_ = conn.Close()
+	}
+
+	return fmt.Errorf("%w: reset after %d bytes", ErrInjected, c.written)
+}
+
+func corrupt(p []byte, rate float64) []byte {
+	var out []byte
+	for i := range p {
+		if rand.Float64() >= rate {
+			continue
+		}
+		// 호출한 쪽의 버퍼는 바꾸지 않는다.
+		if out == nil {
+			out = make([]byte, len(p))
+			copy(out, p)
+		}
+		out[i] ^= 1 << rand.IntN(8)
+	}
+	if out == nil {
+		return p
+	}
+
+	return out
+}
+
+// 제어 API의 JSON 형식. 시간은 "100ms"와 같은 문자열이다.
+type faultsJSON struct {
+	Latency     string  `json:"latency,omitempty"`
+	Jitter      string  `json:"jitter,omitempty"`
+	Bandwidth   int64   `json:"bandwidth,omitempty"`
+	DropAfter   int64   `json:"drop_after,omitempty"`
+	ResetAfter  int64   `json:"reset_after,omitempty"`
+	DropRate    float64 `json:"drop_rate,omitempty"`
+	ResetRate   float64 `json:"reset_rate,omitempty"`
+	CorruptRate float64 `json:"corrupt_rate,omitempty"`
+}
+
+func (f Faults) toJSON() faultsJSON {
+	j := faultsJSON{
+		Bandwidth:   f.Bandwidth,
+		DropAfter:   f.DropAfter,
+		ResetAfter:  f.ResetAfter,
+		DropRate:    f.DropRate,
+		ResetRate:   f.ResetRate,
+		CorruptRate: f.CorruptRate,
+	}
+	if f.Latency > 0 {
+		j.Latency = f.Latency.String()
+	}
+	if f.Jitter > 0 {
+		j.Jitter = f.Jitter.String()
+	}
+
+	return j
+}
+
+func (j faultsJSON) faults() (Faults, error) {
+	f := Faults{
+		Bandwidth:   j.Bandwidth,
+		DropAfter:   j.DropAfter,
+		ResetAfter:  j.ResetAfter,
+		DropRate:    j.DropRate,
+		ResetRate:   j.ResetRate,
+		CorruptRate: j.CorruptRate,
+	}
+
+	var err error
+	if j.Latency != "" {
+		f.Latency, err = time.ParseDuration(j.Latency)
+		if err != nil {
+			return f, fmt.Errorf("latency: %w", err)
+		}
+	}
+	if j.Jitter != "" {
+		f.Jitter, err = time.ParseDuration(j.Jitter)
+		if err != nil {
+			return f, fmt.Errorf("jitter: %w", err)
+		}
+	}
+
+	for _, rate := range []float64{f.DropRate, f.ResetRate, f.CorruptRate} {
+		if rate < 0 || rate > 1 {
+			return f, fmt.Errorf("invalid rate %v", rate)
+		}
+	}
+	if f.Latency < 0 || f.Jitter < 0 || f.Bandwidth < 0 || f.DropAfter < 0 || f.ResetAfter < 0 {
+		return f, errors.New("negative value")
+	}
+
+	return f, nil
+}
+
+var directions = map[string]uint8{
+	"upstream":   UPSTREAM,
+	"downstream": DOWNSTREAM,
+}
+
+// 제어 API
+//
+//	GET    /                현재 설정
+//	PUT    /{direction}     방향별 설정을 바꾼다. direction은 upstream 또는 downstream
+//	DELETE /                모든 장애를 없앤다.
+func (f *FaultInjector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(r.URL.Path, "/")
+
+	switch {
+	case r.Method == http.MethodGet && path == "":
+	case r.Method == http.MethodDelete && path == "":
+		f.Reset()
+	case r.Method == http.MethodPut:
+		direction, ok := directions[path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		var j faultsJSON
+		dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<10))
+		dec.DisallowUnknownFields()
+		err := dec.Decode(&j)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		faults, err := j.faults()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.Set(direction, faults)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]faultsJSON{
+		"upstream":   f.Get(UPSTREAM).toJSON(),
+		"downstream": f.Get(DOWNSTREAM).toJSON(),
+	})
+}
@@ -0,0 +1,210 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// 장애를 적용하는 프록시를 실행하고 주소를 반환한다.
+func faultProxy(t *testing.T, f *FaultInjector) (string, chan ConnStats) {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats := make(chan ConnStats, 1)
+	s := &Server{
+		Target:  lenientEchoAfterEOF(t).Addr().String(),
+		Faults:  f,
+		OnClose: func(c ConnStats) { stats <- c },
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = s.Serve(ctx, l)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		_ = s.Close()
+		<-done
+	})
+
+	return l.Addr().String(), stats
+}
+
+// 데이터를 보내고 상대가 보낸 데이터를 끝까지 읽는다.
+func exchange(t *testing.T, addr string, msg []byte) ([]byte, error) {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	_, err = conn.Write(msg)
+	if err != nil {
+		return nil, err
+	}
+	_ = conn.(*net.TCPConn).CloseWrite()
+
+	return io.ReadAll(conn)
+}
+
+func TestFaultLatency(t *testing.T) {
+	f := new(FaultInjector)
+	f.Set(UPSTREAM, Faults{Latency: 100 * time.Millisecond})
+	f.Set(DOWNSTREAM, Faults{Latency: 100 * time.Millisecond})
+	addr, _ := faultProxy(t, f)
+
+	start := time.Now()
+	reply, err := exchange(t, addr, []byte("slow"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(reply) != "SLOW" {
+		t.Errorf("expected %q; actual %q", "SLOW", reply)
+	}
+	if d := time.Since(start); d < 200*time.Millisecond {
+		t.Errorf("expected at least 200ms; actual %s", d)
+	}
+}
+
+func TestFaultBandwidth(t *testing.T) {
+	f := new(FaultInjector)
+	f.Set(DOWNSTREAM, Faults{Bandwidth: 10 << 10})
+	addr, _ := faultProxy(t, f)
+
+	// 10KB/s로 5KB를 받으려면 0.5초가 걸린다.
+	start := time.Now()
+	reply, err := exchange(t, addr, bytes.Repeat([]byte("a"), 5<<10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reply) != 5<<10 {
+		t.Errorf("expected %d bytes; actual %d", 5<<10, len(reply))
+	}
+	if d := time.Since(start); d < 400*time.Millisecond {
+		t.Errorf("expected at least 400ms; actual %s", d)
+	}
+}
+
+func TestFaultDropAfter(t *testing.T) {
+	f := new(FaultInjector)
+	f.Set(DOWNSTREAM, Faults{DropAfter: 3})
+	addr, stats := faultProxy(t, f)
+
+	reply, err := exchange(t, addr, []byte("truncated"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(reply) != "TRU" {
+		t.Errorf("expected %q; actual %q", "TRU", reply)
+	}
+
+	c := <-stats
+	if !errors.Is(c.Err, ErrInjected) {
+		t.Errorf("expected ErrInjected; actual %v", c.Err)
+	}
+	if c.Received != 3 {
+		t.Errorf("expected 3 bytes received; actual %d", c.Received)
+	}
+}
+
+func TestFaultResetAfter(t *testing.T) {
+	f := new(FaultInjector)
+	f.Set(UPSTREAM, Faults{ResetAfter: 1})
+	addr, _ := faultProxy(t, f)
+
+	_, err := exchange(t, addr, []byte("reset"))
+	if !errors.Is(err, syscall.ECONNRESET) {
+		t.Errorf("expected connection reset; actual %v", err)
+	}
+}
+
+func TestFaultCorrupt(t *testing.T) {
+	f := new(FaultInjector)
+	f.Set(DOWNSTREAM, Faults{CorruptRate: 1})
+	addr, _ := faultProxy(t, f)
+
+	reply, err := exchange(t, addr, []byte("corrupt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []byte("CORRUPT")
+	if len(reply) != len(expected) {
+		t.Fatalf("expected %d bytes; actual %d", len(expected), len(reply))
+	}
+	// 모든 바이트에서 비트 하나가 바뀐다.
+	for i := range expected {
+		if reply[i] == expected[i] {
+			t.Errorf("byte %d was not corrupted: %q", i, reply)
+		}
+	}
+}
+
+func TestFaultControlAPI(t *testing.T) {
+	f := new(FaultInjector)
+	srv := httptest.NewServer(f)
+	defer srv.Close()
+
+	req, err := http.NewRequest(http.MethodPut, srv.URL+"/downstream",
+		strings.NewReader(`{"latency": "50ms", "drop_after": 10}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200; actual %d", resp.StatusCode)
+	}
+
+	expected := Faults{Latency: 50 * time.Millisecond, DropAfter: 10}
+	if actual := f.Get(DOWNSTREAM); actual != expected {
+		t.Errorf("expected %+v; actual %+v", expected, actual)
+	}
+
+	req, _ = http.NewRequest(http.MethodPut, srv.URL+"/upstream", strings.NewReader(`{"drop_rate": 2}`))
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status 400 for invalid rate; actual %d", resp.StatusCode)
+	}
+
+	req, _ = http.NewRequest(http.MethodDelete, srv.URL+"/", nil)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var current map[string]map[string]any
+	err = json.NewDecoder(resp.Body).Decode(&current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(current["downstream"]) != 0 || f.Get(DOWNSTREAM) != (Faults{}) {
+		t.Errorf("expected faults to be cleared; actual %v", current)
+	}
+}
@@ -0,0 +1,184 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/testaquatic/NetworkProgrammingWithGo/ch04/proxyproto"
+)
+
+// 연결 하나를 중계한 결과
+type ConnStats struct {
+	Client net.Addr
+	Target net.Addr
+	// 클라이언트 -> 대상
+	Sent int64
+	// 대상 -> 클라이언트
+	Received int64
+	Duration time.Duration
+	Err      error
+}
+
+// 받은 연결을 Target으로 중계한다.
+type Server struct {
+	Target string
+	// 0이면 제한하지 않는다.
+	DialTimeout time.Duration
+	// nil이면 net.Dialer로 Target에 연결한다.
+	Dial func(ctx context.Context, network, address string) (net.Conn, error)
+	// 연결이 끝날 때마다 호출한다.
+	OnClose func(ConnStats)
+	// nil이 아니면 중계하는 데이터에 장애를 적용한다.
+	Faults *FaultInjector
+	// nil이 아니면 세션을 파일로 기록한다.
+	Recorder *Recorder
+	// proxyproto.VERSION_1 또는 VERSION_2면 대상에 클라이언트 주소를 헤더로 보낸다.
+	ProxyProtocol uint8
+
+	conns Tracker
+}
+
+// ctx가 취소되면 더 이상 연결을 받지 않고 중계 중인 연결이 끝나기를 기다린다.
+// 기다리지 않으려면 Close를 호출한다.
+func (s *Server) Serve(ctx context.Context, l net.Listener) error {
+	return Serve(ctx, l, func(conn net.Conn) {
+		stats := s.handle(ctx, conn)
+		if s.OnClose != nil {
+			s.OnClose(stats)
+		}
+	})
+}
+
+// 중계 중인 모든 연결을 끊는다.
+func (s *Server) Close() error {
+	s.conns.CloseAll()
+
+	return nil
+}
+
+func (s *Server) handle(ctx context.Context, client net.Conn) (stats ConnStats) {
+	start := time.Now()
+	stats.Client = client.RemoteAddr()
+
+	s.conns.Add(client)
+	defer func() {
+		s.conns.Remove(client)
+		_ = client.Close()
+		stats.Duration = time.Since(start)
+	}()
+
+	target, err := s.dial(ctx)
+	if err != nil {
+		stats.Err = err
+		return stats
+	}
+	stats.Target = target.RemoteAddr()
+
+	s.conns.Add(target)
+	defer func() {
+		s.conns.Remove(target)
+		_ = target.Close()
+	}()
+
+	if s.ProxyProtocol != 0 {
+		_, err = proxyproto.NewHeader(s.ProxyProtocol, client).WriteTo(target)
+		if err != nil {
+			stats.Err = fmt.Errorf("proxy protocol: %w", err)
+			return stats
+		}
+	}
+
+	// 장애는 실제 연결에 적용하고 기록은 장애를 적용하기 전의 데이터를 대상으로 한다.
+	c, t := client, target
+	if s.Faults != nil {
+		c, t = s.Faults.wrap(c, t)
+	}
+	if s.Recorder != nil {
+		f, rw, err := s.Recorder.create(client.RemoteAddr())
+		if err != nil {
+			stats.Err = fmt.Errorf("recording: %w", err)
+			return stats
+		}
+		defer func() {
+			err := rw.Flush()
+			if cErr := f.Close(); err == nil {
+				err = cErr
+			}
+			if err != nil && stats.Err == nil {
+				stats.Err = fmt.Errorf("recording: %w", err)
+			}
+		}()
+		c, t = rw.wrap(c, t)
+	}
+
+	stats.Sent, stats.Received, stats.Err = Pipe(c, t)
+
+	return stats
+}
+
+func (s *Server) dial(ctx context.Context) (net.Conn, error) {
+	if s.DialTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.DialTimeout)
+		defer cancel()
+	}
+
+	if s.Dial != nil {
+		return s.Dial(ctx, "tcp", s.Target)
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", s.Target)
+	if err != nil {
+		return nil, fmt.Errorf("dialing %s: %w", s.Target, err)
+	}
+
+	return conn, nil
+}
+
+// 두 연결 사이에서 양방향으로 데이터를 복사한다.
+// 한쪽 방향이 끝나면 상대에게 CloseWrite로 알리고 두 방향이 모두 끝나면 반환한다.
+// sent는 client -> target, received는 target -> client 방향의 바이트 수다.
+func Pipe(client, target net.Conn) (sent, received int64, err error) {
+	errs := make(chan error, 1)
+	go func() {
+		var err error
+		received, err = copyHalf(client, target)
+		errs <- err
+	}()
+
+	sent, err = copyHalf(target, client)
+	rErr := <-errs
+	if err == nil {
+		err = rErr
+	}
+
+	return sent, received, err
+}
+
+func copyHalf(dst, src net.Conn) (int64, error) {
+	n, err := io.Copy(dst, src)
+	if err != nil {
+		// 반대 방향도 더 진행할 수 없으므로 모두 닫는다.
+		_ = dst.Close()
+		_ = src.Close()
+		// 반대 방향에서 먼저 닫은 경우
+		if errors.Is(err, net.ErrClosed) || errors.Is(err, io.ErrClosedPipe) {
+			err = nil
+		}
+		return n, err
+	}
+
+	if cw, ok := dst.(interface{ CloseWrite() error }); ok {
+		_ = cw.CloseWrite()
+	} else {
+		// 반만 닫을 수 없으면 연결을 닫아 끝났음을 알린다.
+		_ = dst.Close()
+	}
+
+	return n, nil
+}
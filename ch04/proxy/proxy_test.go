@@ -0,0 +1,304 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/testaquatic/NetworkProgrammingWithGo/ch04/proxyproto"
+)
+
+// 받은 데이터를 끝까지 읽은 후에 대문자로 바꿔 돌려보낸다.
+func echoAfterEOF(t *testing.T) net.Listener {
+	t.Helper()
+
+	return upperEcho(t, func(err error) { t.Error(err) })
+}
+
+// 장애를 주입해 연결이 끊길 수 있을 때 사용한다. 읽기 오류를 무시한다.
+func lenientEchoAfterEOF(t *testing.T) net.Listener {
+	t.Helper()
+
+	return upperEcho(t, func(error) {})
+}
+
+func upperEcho(t *testing.T, onErr func(error)) net.Listener {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+
+				buf, err := io.ReadAll(conn)
+				if err != nil {
+					onErr(err)
+					return
+				}
+				_, _ = conn.Write(bytes.ToUpper(buf))
+			}()
+		}
+	}()
+
+	return l
+}
+
+func TestServeHalfClose(t *testing.T) {
+	target := echoAfterEOF(t)
+
+	l, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats := make(chan ConnStats, 1)
+	s := &Server{
+		Target:  target.Addr().String(),
+		OnClose: func(c ConnStats) { stats <- c },
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.Serve(ctx, l) }()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 대상은 EOF를 받아야 응답한다.
+	err = conn.(*net.TCPConn).CloseWrite()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reply, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(reply) != "HELLO" {
+		t.Errorf("expected reply %q; actual %q", "HELLO", reply)
+	}
+
+	c := <-stats
+	if c.Err != nil {
+		t.Error(c.Err)
+	}
+	if c.Duration <= 0 {
+		t.Error("expected a positive duration")
+	}
+	if c.Sent != 5 || c.Received != 5 {
+		t.Errorf("expected 5 bytes each way; sent %d, received %d", c.Sent, c.Received)
+	}
+
+	cancel()
+	err = <-done
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestServeGracefulShutdown(t *testing.T) {
+	target := echoAfterEOF(t)
+
+	l, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &Server{Target: target.Addr().String()}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.Serve(ctx, l) }()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("in flight"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cancel()
+
+	// 중계 중인 연결이 있으므로 Serve는 반환하지 않는다.
+	select {
+	case <-done:
+		t.Fatal("Serve returned with an active connection")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	_, err = net.DialTimeout("tcp", l.Addr().String(), time.Second)
+	if err == nil {
+		t.Error("expected new connections to be refused")
+	}
+
+	_ = conn.(*net.TCPConn).CloseWrite()
+	reply, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(reply) != "IN FLIGHT" {
+		t.Errorf("expected reply %q; actual %q", "IN FLIGHT", reply)
+	}
+
+	select {
+	case err = <-done:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Serve did not return")
+	}
+}
+
+func TestServeClose(t *testing.T) {
+	target := echoAfterEOF(t)
+
+	l, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &Server{Target: target.Addr().String()}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.Serve(ctx, l) }()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// 연결이 중계되기 시작할 때까지 기다린다.
+	_, _ = conn.Write([]byte("x"))
+	time.Sleep(50 * time.Millisecond)
+
+	cancel()
+	_ = s.Close()
+
+	select {
+	case err = <-done:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Serve did not return after Close")
+	}
+}
+
+func TestPipeWaitsForBothDirections(t *testing.T) {
+	client, clientPeer := net.Pipe()
+	target, targetPeer := net.Pipe()
+
+	var (
+		wg             sync.WaitGroup
+		sent, received int64
+		err            error
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		sent, received, err = Pipe(clientPeer, target)
+	}()
+
+	go func() {
+		_, _ = client.Write([]byte("ping"))
+		_, _ = io.ReadAll(client)
+	}()
+
+	buf := make([]byte, 4)
+	_, _ = io.ReadFull(targetPeer, buf)
+	_, _ = targetPeer.Write([]byte("pong!"))
+	_ = targetPeer.Close()
+
+	wg.Wait()
+	_ = client.Close()
+
+	if err != nil {
+		t.Error(err)
+	}
+	if sent != 4 || received != 5 {
+		t.Errorf("expected sent 4, received 5; actual %d, %d", sent, received)
+	}
+}
+
+func TestServeProxyProtocol(t *testing.T) {
+	bl, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bl.Close()
+
+	// 대상은 헤더에서 읽은 클라이언트 주소를 돌려보낸다.
+	backend := &proxyproto.Listener{Listener: bl}
+	go func() {
+		for {
+			conn, err := backend.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, _ = io.WriteString(conn, conn.RemoteAddr().String())
+			}()
+		}
+	}()
+
+	for _, version := range []uint8{proxyproto.VERSION_1, proxyproto.VERSION_2} {
+		l, err := net.Listen("tcp", "127.0.0.1:")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		s := &Server{Target: bl.Addr().String(), ProxyProtocol: version}
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() { done <- s.Serve(ctx, l) }()
+
+		conn, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		reply, err := io.ReadAll(conn)
+		_ = conn.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(reply) != conn.LocalAddr().String() {
+			t.Errorf("v%d: expected client address %s; actual %q", version, conn.LocalAddr(), reply)
+		}
+
+		cancel()
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	}
+}
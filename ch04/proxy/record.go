@@ -0,0 +1,215 @@
+package proxy
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const RECORD_MAGIC = "TCPREC\x00\x01"
+
+// 이벤트 하나의 최대 크기
+const MAX_EVENT_SIZE = 16 << 20 // 16MB
+
+var ErrInvalidRecord = errors.New("invalid record")
+
+// 기록한 데이터 한 조각. Data가 비어 있으면 그 방향의 전송이 끝났음을 나타낸다.
+type Event struct {
+	// UPSTREAM 또는 DOWNSTREAM
+	Direction uint8
+	// 세션을 시작한 후 지난 시간
+	Offset time.Duration
+	Data   []byte
+}
+
+// 세션을 파일 형식으로 기록한다. 여러 고루틴에서 동시에 사용할 수 있다.
+//
+//	| RECORD_MAGIC(8B) | Start(8B, Unix ns) |
+//	| Direction(1B) | Offset(8B, ns) | Length(4B) | Data | ...
+type RecordWriter struct {
+	mu    sync.Mutex
+	w     *bufio.Writer
+	start time.Time
+	err   error
+}
+
+func NewRecordWriter(w io.Writer) (*RecordWriter, error) {
+	rw := &RecordWriter{w: bufio.NewWriter(w), start: time.Now()}
+
+	header := make([]byte, len(RECORD_MAGIC)+8)
+	copy(header, RECORD_MAGIC)
+	binary.BigEndian.PutUint64(header[len(RECORD_MAGIC):], uint64(rw.start.UnixNano()))
+
+	_, err := rw.w.Write(header)
+	if err != nil {
+		return nil, err
+	}
+
+	return rw, nil
+}
+
+func (rw *RecordWriter) Write(direction uint8, p []byte) error {
+	if len(p) > MAX_EVENT_SIZE {
+		// 너무 큰 데이터는 나눠서 기록한다.
+		err := rw.Write(direction, p[:MAX_EVENT_SIZE])
+		if err != nil {
+			return err
+		}
+		return rw.Write(direction, p[MAX_EVENT_SIZE:])
+	}
+
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+
+	if rw.err != nil {
+		return rw.err
+	}
+
+	header := make([]byte, 13)
+	header[0] = direction
+	binary.BigEndian.PutUint64(header[1:9], uint64(time.Since(rw.start)))
+	binary.BigEndian.PutUint32(header[9:], uint32(len(p)))
+
+	_, rw.err = rw.w.Write(header)
+	if rw.err == nil {
+		_, rw.err = rw.w.Write(p)
+	}
+
+	return rw.err
+}
+
+// 해당 방향의 전송이 끝났음을 기록한다.
+func (rw *RecordWriter) CloseDirection(direction uint8) error {
+	return rw.Write(direction, nil)
+}
+
+func (rw *RecordWriter) Flush() error {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+
+	if rw.err != nil {
+		return rw.err
+	}
+
+	return rw.w.Flush()
+}
+
+type RecordReader struct {
+	r     *bufio.Reader
+	start time.Time
+}
+
+func NewRecordReader(r io.Reader) (*RecordReader, error) {
+	br := bufio.NewReader(r)
+
+	header := make([]byte, len(RECORD_MAGIC)+8)
+	_, err := io.ReadFull(br, header)
+	if err != nil || string(header[:len(RECORD_MAGIC)]) != RECORD_MAGIC {
+		return nil, fmt.Errorf("%w: missing header", ErrInvalidRecord)
+	}
+
+	start := int64(binary.BigEndian.Uint64(header[len(RECORD_MAGIC):]))
+
+	return &RecordReader{r: br, start: time.Unix(0, start)}, nil
+}
+
+// 세션을 시작한 시각
+func (rr *RecordReader) Start() time.Time {
+	return rr.start
+}
+
+// 기록이 끝나면 io.EOF를 반환한다.
+func (rr *RecordReader) Next() (Event, error) {
+	var e Event
+
+	header := make([]byte, 13)
+	_, err := io.ReadFull(rr.r, header)
+	if err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = fmt.Errorf("%w: truncated event", ErrInvalidRecord)
+		}
+		return e, err
+	}
+
+	e.Direction = header[0]
+	e.Offset = time.Duration(binary.BigEndian.Uint64(header[1:9]))
+	size := binary.BigEndian.Uint32(header[9:])
+
+	if e.Direction > DOWNSTREAM || e.Offset < 0 || size > MAX_EVENT_SIZE {
+		return e, fmt.Errorf("%w: event header %x", ErrInvalidRecord, header)
+	}
+
+	if size > 0 {
+		e.Data = make([]byte, size)
+		_, err = io.ReadFull(rr.r, e.Data)
+		if err != nil {
+			return e, fmt.Errorf("%w: truncated event", ErrInvalidRecord)
+		}
+	}
+
+	return e, nil
+}
+
+// 세션마다 Dir에 파일 하나를 만들어 기록한다.
+type Recorder struct {
+	Dir string
+}
+
+func (r *Recorder) create(client net.Addr) (*os.File, *RecordWriter, error) {
+	// 파일 이름에 쓸 수 없는 문자를 바꾼다.
+	addr := strings.NewReplacer(":", "_", "[", "", "]", "").Replace(client.String())
+	name := fmt.Sprintf("%s-%s.rec", time.Now().Format("20060102T150405.000000"), addr)
+
+	f, err := os.OpenFile(filepath.Join(r.Dir, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rw, err := NewRecordWriter(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, nil, err
+	}
+
+	return f, rw, nil
+}
+
+func (rw *RecordWriter) wrap(client, target net.Conn) (net.Conn, net.Conn) {
+	return &recordConn{Conn: client, rw: rw, direction: DOWNSTREAM},
+		&recordConn{Conn: target, rw: rw, direction: UPSTREAM}
+}
+
+// 연결에 기록한 데이터를 direction 방향으로 기록한다.
+type recordConn struct {
+	net.Conn
+	rw        *RecordWriter
+	direction uint8
+}
+
+func (c *recordConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	if n > 0 {
+		// 기록에 실패해도 중계는 계속한다.
+		_ = c.rw.Write(c.direction, p[:n])
+	}
+
+	return n, err
+}
+
+func (c *recordConn) CloseWrite() error {
+	_ = c.rw.CloseDirection(c.direction)
+
+	if cw, ok := c.Conn.(interface{ CloseWrite() error }); ok {
+		return cw.CloseWrite()
+	}
+
+	return c.Close()
+}
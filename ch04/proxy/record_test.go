@@ -0,0 +1,215 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRecordRoundTrip(t *testing.T) {
+	buf := new(bytes.Buffer)
+	rw, err := NewRecordWriter(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Event{
+		{Direction: UPSTREAM, Data: []byte("hello")},
+		{Direction: DOWNSTREAM, Data: []byte("world")},
+		{Direction: UPSTREAM},
+		{Direction: DOWNSTREAM},
+	}
+	for _, e := range expected {
+		err = rw.Write(e.Direction, e.Data)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = rw.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr, err := NewRecordReader(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var last time.Duration
+	for i, e := range expected {
+		actual, err := rr.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual.Direction != e.Direction || !bytes.Equal(actual.Data, e.Data) {
+			t.Errorf("%d: expected %+v; actual %+v", i, e, actual)
+		}
+		if actual.Offset < last {
+			t.Errorf("%d: offset went backwards", i)
+		}
+		last = actual.Offset
+	}
+
+	_, err = rr.Next()
+	if err != io.EOF {
+		t.Errorf("expected EOF; actual %v", err)
+	}
+}
+
+func TestRecordInvalid(t *testing.T) {
+	_, err := NewRecordReader(bytes.NewReader([]byte("not a record")))
+	if !errors.Is(err, ErrInvalidRecord) {
+		t.Errorf("expected ErrInvalidRecord; actual %v", err)
+	}
+
+	buf := new(bytes.Buffer)
+	rw, _ := NewRecordWriter(buf)
+	_ = rw.Write(UPSTREAM, []byte("truncated"))
+	_ = rw.Flush()
+
+	rr, err := NewRecordReader(bytes.NewReader(buf.Bytes()[:buf.Len()-1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = rr.Next()
+	if !errors.Is(err, ErrInvalidRecord) {
+		t.Errorf("expected ErrInvalidRecord; actual %v", err)
+	}
+}
+
+// 프록시로 세션을 기록하고 같은 대상과 가짜 서버에 재생한다.
+func TestRecordAndReplay(t *testing.T) {
+	target := echoAfterEOF(t)
+	dir := t.TempDir()
+
+	l, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &Server{Target: target.Addr().String(), Recorder: &Recorder{Dir: dir}}
+	closed := make(chan struct{})
+	s.OnClose = func(ConnStats) { close(closed) }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = s.Serve(ctx, l) }()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = conn.Write([]byte("record me"))
+	_ = conn.(*net.TCPConn).CloseWrite()
+	_, _ = io.ReadAll(conn)
+	_ = conn.Close()
+	<-closed
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.rec"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("expected one recording; actual %v (%v)", files, err)
+	}
+
+	open := func() *RecordReader {
+		f, err := os.Open(files[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = f.Close() })
+
+		rr, err := NewRecordReader(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rr
+	}
+
+	t.Run("client", func(t *testing.T) {
+		conn, err := net.Dial("tcp", target.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+
+		r := &Replayer{Role: REPLAY_CLIENT, Realtime: true}
+		err = r.Replay(conn, open())
+		if err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("server", func(t *testing.T) {
+		server, client := tcpPair(t)
+
+		done := make(chan error)
+		go func() {
+			r := &Replayer{Role: REPLAY_SERVER}
+			done <- r.Replay(server, open())
+		}()
+
+		_, _ = client.Write([]byte("record me"))
+		_ = client.(*net.TCPConn).CloseWrite()
+		reply, _ := io.ReadAll(client)
+		if string(reply) != "RECORD ME" {
+			t.Errorf("expected %q; actual %q", "RECORD ME", reply)
+		}
+
+		err := <-done
+		if err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("mismatch", func(t *testing.T) {
+		server, client := tcpPair(t)
+
+		done := make(chan error)
+		go func() {
+			r := &Replayer{Role: REPLAY_SERVER, Timeout: time.Second}
+			done <- r.Replay(server, open())
+		}()
+
+		_, _ = client.Write([]byte("record us"))
+		_ = client.(*net.TCPConn).CloseWrite()
+
+		var mErr *MismatchError
+		err := <-done
+		if !errors.As(err, &mErr) {
+			t.Fatalf("expected MismatchError; actual %v", err)
+		}
+		if mErr.Offset != 7 || mErr.Direction != UPSTREAM {
+			t.Errorf("expected upstream mismatch at byte 7; actual %+v", mErr)
+		}
+	})
+}
+
+func tcpPair(t *testing.T) (net.Conn, net.Conn) {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	client, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = client.Close()
+		_ = server.Close()
+	})
+
+	return server, client
+}
@@ -0,0 +1,145 @@
+package proxy
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// 재생할 때 맡을 역할
+const (
+	// UPSTREAM 데이터를 보내고 DOWNSTREAM 데이터를 기대한다.
+	REPLAY_CLIENT uint8 = iota
+	// DOWNSTREAM 데이터를 보내고 UPSTREAM 데이터를 기대한다.
+	REPLAY_SERVER
+)
+
+const DEFAULT_REPLAY_TIMEOUT = 5 * time.Second
+
+// 상대가 보낸 데이터가 기록과 다르다.
+type MismatchError struct {
+	Direction uint8
+	// 해당 방향에서 처음으로 다른 바이트의 위치
+	Offset   int64
+	Expected []byte
+	Actual   []byte
+}
+
+func (e *MismatchError) Error() string {
+	return fmt.Sprintf("mismatch at byte %d: expected %q; actual %q", e.Offset, e.Expected, e.Actual)
+}
+
+// 기록한 세션을 연결 위에서 재생한다.
+// 기록된 순서대로 자기 쪽 데이터는 보내고 상대 쪽 데이터는 받아서 비교한다.
+type Replayer struct {
+	Role uint8
+	// true면 기록된 시각에 맞춰 보낸다. false면 기다리지 않고 보낸다.
+	Realtime bool
+	// 상대의 데이터를 기다리는 시간. 0이면 기본값을 사용한다.
+	Timeout time.Duration
+}
+
+func (r *Replayer) Replay(conn net.Conn, rec *RecordReader) error {
+	send := UPSTREAM
+	if r.Role == REPLAY_SERVER {
+		send = DOWNSTREAM
+	}
+
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = DEFAULT_REPLAY_TIMEOUT
+	}
+
+	var (
+		start    = time.Now()
+		received int64
+		buf      []byte
+	)
+	for {
+		e, err := rec.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if e.Direction == send {
+			if r.Realtime {
+				time.Sleep(time.Until(start.Add(e.Offset)))
+			}
+
+			if len(e.Data) == 0 {
+				err = closeWrite(conn)
+			} else {
+				_, err = conn.Write(e.Data)
+			}
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		err = conn.SetReadDeadline(time.Now().Add(timeout))
+		if err != nil {
+			return err
+		}
+
+		if len(e.Data) == 0 {
+			// 상대도 전송을 마쳐야 한다.
+			extra := make([]byte, 1)
+			n, err := conn.Read(extra)
+			switch {
+			case n == 0 && err == io.EOF:
+				continue
+			case n > 0:
+				return &MismatchError{Direction: e.Direction, Offset: received, Actual: extra}
+			}
+			return fmt.Errorf("waiting for end of stream at byte %d: %w", received, err)
+		}
+
+		if cap(buf) < len(e.Data) {
+			buf = make([]byte, len(e.Data))
+		}
+		buf = buf[:len(e.Data)]
+
+		n, err := io.ReadFull(conn, buf)
+		i := mismatch(e.Data, buf[:n])
+		if i < 0 && (err == io.EOF || err == io.ErrUnexpectedEOF) {
+			// 상대가 기록보다 일찍 전송을 마쳤다.
+			i = n
+		}
+		if i >= 0 {
+			return &MismatchError{
+				Direction: e.Direction,
+				Offset:    received + int64(i),
+				Expected:  e.Data[i:min(i+16, len(e.Data))],
+				Actual:    buf[i:min(i+16, n)],
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("reading at byte %d: %w", received+int64(n), err)
+		}
+		received += int64(n)
+	}
+}
+
+// 처음으로 다른 위치. 같으면 -1을 반환한다.
+func mismatch(expected, actual []byte) int {
+	for i := range actual {
+		if expected[i] != actual[i] {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func closeWrite(conn net.Conn) error {
+	if cw, ok := conn.(interface{ CloseWrite() error }); ok {
+		return cw.CloseWrite()
+	}
+
+	return conn.Close()
+}
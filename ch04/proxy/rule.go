@@ -0,0 +1,88 @@
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// socks5와 HTTP 프록시가 함께 사용하는 목적지 허용 규칙
+// Host는 모든 목적지를 뜻하는 "*", CIDR(10.0.0.0/8), IP 주소,
+// 도메인(example.com), 하위 도메인(*.example.com) 중 하나다.
+type Rule struct {
+	Deny bool
+	Host string
+	// 0이면 모든 포트
+	Port uint16
+}
+
+// "allow *.example.com:443", "deny 10.0.0.0/8" 형식의 규칙을 해석한다.
+func ParseRule(s string) (Rule, error) {
+	var r Rule
+
+	fields := strings.Fields(s)
+	if len(fields) != 2 {
+		return r, fmt.Errorf("invalid rule %q", s)
+	}
+
+	switch fields[0] {
+	case "allow":
+	case "deny":
+		r.Deny = true
+	default:
+		return r, fmt.Errorf("invalid rule action %q", fields[0])
+	}
+
+	r.Host = fields[1]
+	// IPv6 주소의 콜론과 포트를 구분한다.
+	if host, port, err := net.SplitHostPort(fields[1]); err == nil {
+		p, err := strconv.ParseUint(port, 10, 16)
+		if err != nil {
+			return r, fmt.Errorf("invalid rule port %q", port)
+		}
+		r.Host, r.Port = host, uint16(p)
+	}
+
+	if strings.Contains(r.Host, "/") {
+		_, _, err := net.ParseCIDR(r.Host)
+		if err != nil {
+			return r, err
+		}
+	}
+
+	return r, nil
+}
+
+// host는 요청한 이름, ip는 연결할 주소다. 도메인 요청이면 둘 다 비교한다.
+func (r *Rule) Match(host string, ip net.IP, port uint16) bool {
+	if r.Port != 0 && r.Port != port {
+		return false
+	}
+
+	switch {
+	case r.Host == "*":
+		return true
+	case strings.Contains(r.Host, "/"):
+		_, network, err := net.ParseCIDR(r.Host)
+		return err == nil && ip != nil && network.Contains(ip)
+	case net.ParseIP(r.Host) != nil:
+		return ip != nil && net.ParseIP(r.Host).Equal(ip)
+	case strings.HasPrefix(r.Host, "*."):
+		return strings.HasSuffix(strings.ToLower(host), strings.ToLower(r.Host[1:]))
+	}
+
+	return strings.EqualFold(strings.TrimSuffix(host, "."), r.Host)
+}
+
+// 처음 일치한 규칙을 따른다. 일치하는 규칙이 없으면 deny를 따른다.
+// CIDR와 IP 규칙을 적용하려면 host를 찾은 주소마다 호출한다.
+func Allowed(rules []Rule, deny bool, host string, ip net.IP, port uint16) bool {
+	for i := range rules {
+		if rules[i].Match(host, ip, port) {
+			return !rules[i].Deny
+		}
+	}
+
+	return !deny
+}
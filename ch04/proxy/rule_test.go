@@ -0,0 +1,57 @@
+package proxy
+
+import (
+	"net"
+	"testing"
+)
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		rule     string
+		expected Rule
+		ok       bool
+	}{
+		{"allow *", Rule{Host: "*"}, true},
+		{"deny 10.0.0.0/8", Rule{Deny: true, Host: "10.0.0.0/8"}, true},
+		{"allow *.example.com:443", Rule{Host: "*.example.com", Port: 443}, true},
+		{"allow [::1]:22", Rule{Host: "::1", Port: 22}, true},
+		{"deny fe80::/10", Rule{Deny: true, Host: "fe80::/10"}, true},
+		{"block example.com", Rule{}, false},
+		{"allow 10.0.0.0/33", Rule{}, false},
+		{"allow", Rule{}, false},
+	}
+
+	for _, tc := range tests {
+		actual, err := ParseRule(tc.rule)
+		if (err == nil) != tc.ok {
+			t.Errorf("%q: unexpected error %v", tc.rule, err)
+			continue
+		}
+		if tc.ok && actual != tc.expected {
+			t.Errorf("%q: expected %+v; actual %+v", tc.rule, tc.expected, actual)
+		}
+	}
+}
+
+func TestRuleMatch(t *testing.T) {
+	ip := net.ParseIP("192.0.2.10")
+
+	tests := []struct {
+		rule  Rule
+		host  string
+		match bool
+	}{
+		{Rule{Host: "192.0.2.0/24"}, "192.0.2.10", true},
+		{Rule{Host: "192.0.2.10"}, "192.0.2.10", true},
+		{Rule{Host: "*.example.com"}, "www.Example.com", true},
+		{Rule{Host: "*.example.com"}, "example.com", false},
+		{Rule{Host: "example.com"}, "example.com.", true},
+		{Rule{Host: "example.com", Port: 80}, "example.com", false},
+	}
+
+	for _, tc := range tests {
+		if actual := tc.rule.Match(tc.host, ip, 443); actual != tc.match {
+			t.Errorf("%+v %q: expected %t; actual %t", tc.rule, tc.host, tc.match, actual)
+		}
+	}
+}
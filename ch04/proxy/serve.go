@@ -0,0 +1,84 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+)
+
+// 받은 연결마다 handle을 새 고루틴에서 호출한다. handle이 반환하면 연결을 닫는다.
+// ctx가 취소되면 더 이상 연결을 받지 않고 처리 중인 handle이 모두 끝나기를 기다린다.
+func Serve(ctx context.Context, l net.Listener, handle func(conn net.Conn)) error {
+	stop := make(chan struct{})
+	defer close(stop)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = l.Close()
+		case <-stop:
+		}
+	}()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("accept: %w", err)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer conn.Close()
+
+			handle(conn)
+		}()
+	}
+}
+
+// 처리 중인 연결을 모아 두었다가 한꺼번에 끊는다. 0값을 바로 사용할 수 있다.
+type Tracker struct {
+	mu    sync.Mutex
+	conns map[net.Conn]struct{}
+}
+
+func (t *Tracker) Add(c net.Conn) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.conns == nil {
+		t.conns = make(map[net.Conn]struct{})
+	}
+	t.conns[c] = struct{}{}
+}
+
+func (t *Tracker) Remove(c net.Conn) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.conns, c)
+}
+
+func (t *Tracker) Len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return len(t.conns)
+}
+
+// 추적 중인 모든 연결을 닫는다. 닫은 연결은 각자 Remove할 때까지 남아 있다.
+func (t *Tracker) CloseAll() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for c := range t.conns {
+		_ = c.Close()
+	}
+}
@@ -1,9 +1,9 @@
 package main
 
 import (
-	"io"
-	"log"
 	"net"
+
+	tcpproxy "github.com/testaquatic/NetworkProgrammingWithGo/ch04/proxy"
 )
 
 func proxyConn(source, destination string) error {
@@ -19,16 +19,8 @@ func proxyConn(source, destination string) error {
 	}
 	defer connDestination.Close()
 
-	// `connDestination` -> `connSource`
-	go func() {
-		_, err := io.Copy(connSource, connDestination)
-		if err != nil {
-			log.Println(err)
-		}
-	}()
-
-	// `connSource` -> `connDestination`
-	_, err = io.Copy(connDestination, connSource)
+	// 양방향 복사가 모두 끝날 때까지 기다린다.
+	_, _, err = tcpproxy.Pipe(connSource, connDestination)
 
 	return err
 }
@@ -0,0 +1,124 @@
+package proxyproto
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// 헤더를 기다리는 기본 시간
+const DEFAULT_HEADER_TIMEOUT = 5 * time.Second
+
+// 받은 연결에서 PROXY 프로토콜 헤더를 읽는 리스너.
+// 헤더는 연결을 처음 사용할 때 읽으므로 느린 클라이언트가 Accept를 막지 않는다.
+// 헤더를 보낼 수 있는 프록시만 연결할 수 있는 곳에서 사용한다.
+type Listener struct {
+	net.Listener
+	// 0이면 DEFAULT_HEADER_TIMEOUT
+	HeaderTimeout time.Duration
+	// true면 헤더가 없는 연결도 받는다.
+	Optional bool
+}
+
+func (l *Listener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := l.HeaderTimeout
+	if timeout <= 0 {
+		timeout = DEFAULT_HEADER_TIMEOUT
+	}
+
+	return &Conn{Conn: conn, r: bufio.NewReader(conn), timeout: timeout, optional: l.Optional}, nil
+}
+
+// RemoteAddr와 LocalAddr가 헤더의 주소를 반환하는 연결.
+// 두 메서드도 헤더를 읽을 때까지 기다린다. 헤더를 읽지 못하면 Read가 오류를 반환한다.
+type Conn struct {
+	net.Conn
+	r        *bufio.Reader
+	timeout  time.Duration
+	optional bool
+
+	once     sync.Once
+	header   *Header
+	err      error
+	mu       sync.Mutex
+	deadline time.Time
+}
+
+// 받은 헤더를 반환한다. 헤더가 없이 받은 연결이면 nil이다.
+func (c *Conn) Header() (*Header, error) {
+	c.once.Do(c.readHeader)
+
+	return c.header, c.err
+}
+
+func (c *Conn) readHeader() {
+	_ = c.Conn.SetReadDeadline(time.Now().Add(c.timeout))
+
+	c.header, c.err = ReadHeader(c.r)
+	if errors.Is(c.err, ErrNoHeader) && c.optional {
+		c.err = nil
+	}
+
+	// 헤더를 읽기 전에 설정한 기한을 되돌린다.
+	c.mu.Lock()
+	_ = c.Conn.SetReadDeadline(c.deadline)
+	c.mu.Unlock()
+}
+
+func (c *Conn) Read(p []byte) (int, error) {
+	_, err := c.Header()
+	if err != nil {
+		return 0, err
+	}
+
+	return c.r.Read(p)
+}
+
+func (c *Conn) RemoteAddr() net.Addr {
+	if h, _ := c.Header(); h != nil && h.Source != nil {
+		return h.Source
+	}
+
+	return c.Conn.RemoteAddr()
+}
+
+func (c *Conn) LocalAddr() net.Addr {
+	if h, _ := c.Header(); h != nil && h.Destination != nil {
+		return h.Destination
+	}
+
+	return c.Conn.LocalAddr()
+}
+
+func (c *Conn) SetDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.deadline = t
+
+	return c.Conn.SetDeadline(t)
+}
+
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.deadline = t
+
+	return c.Conn.SetReadDeadline(t)
+}
+
+func (c *Conn) CloseWrite() error {
+	if cw, ok := c.Conn.(interface{ CloseWrite() error }); ok {
+		return cw.CloseWrite()
+	}
+
+	return c.Close()
+}
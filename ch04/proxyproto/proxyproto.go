@@ -0,0 +1,322 @@
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// HAProxy PROXY 프로토콜 버전
+const (
+	VERSION_1 uint8 = iota + 1
+	VERSION_2
+)
+
+// v1 헤더의 최대 길이
+const MAX_V1_LENGTH = 107
+
+// v2 헤더의 시작
+const V2_SIGNATURE = "\r\n\r\n\x00\r\nQUIT\n"
+
+const (
+	v2CmdLocal uint8 = 0x20
+	v2CmdProxy uint8 = 0x21
+
+	v2FamilyUnspec uint8 = 0x00
+	v2FamilyTCP4   uint8 = 0x11
+	v2FamilyUDP4   uint8 = 0x12
+	v2FamilyTCP6   uint8 = 0x21
+	v2FamilyUDP6   uint8 = 0x22
+)
+
+var (
+	ErrNoHeader      = errors.New("no proxy protocol header")
+	ErrInvalidHeader = errors.New("invalid proxy protocol header")
+)
+
+// 프록시가 받은 연결의 원래 주소
+type Header struct {
+	// VERSION_1 또는 VERSION_2
+	Version uint8
+	// 둘 다 nil이면 주소를 알 수 없거나 프록시 자신이 연 연결이다.
+	// 이때는 실제 연결의 주소를 사용한다.
+	// *net.TCPAddr 또는 *net.UDPAddr이다.
+	Source      net.Addr
+	Destination net.Addr
+}
+
+// 연결 하나의 주소로 헤더를 만든다.
+func NewHeader(version uint8, conn net.Conn) *Header {
+	return &Header{Version: version, Source: conn.RemoteAddr(), Destination: conn.LocalAddr()}
+}
+
+// 헤더를 읽는다. 첫 바이트부터 헤더가 아니면 ErrNoHeader를 반환하고 아무것도 읽지 않는다.
+func ReadHeader(r *bufio.Reader) (*Header, error) {
+	version, err := detect(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if version == VERSION_1 {
+		return readV1(r)
+	}
+
+	return readV2(r)
+}
+
+// 데이터를 소비하지 않고 버전을 확인한다.
+func detect(r *bufio.Reader) (uint8, error) {
+	for i := 1; i <= len(V2_SIGNATURE); i++ {
+		b, err := r.Peek(i)
+		if err != nil {
+			return 0, err
+		}
+
+		switch {
+		case bytes.HasPrefix([]byte(V2_SIGNATURE), b):
+			if i == len(V2_SIGNATURE) {
+				return VERSION_2, nil
+			}
+		case bytes.HasPrefix([]byte("PROXY "), b):
+			if i == len("PROXY ") {
+				return VERSION_1, nil
+			}
+		default:
+			return 0, ErrNoHeader
+		}
+	}
+
+	return 0, ErrNoHeader
+}
+
+// PROXY TCP4 192.0.2.1 192.0.2.2 56324 443\r\n
+func readV1(r *bufio.Reader) (*Header, error) {
+	var line []byte
+	for len(line) < MAX_V1_LENGTH {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, fmt.Errorf("%w: v1 header too long", ErrInvalidHeader)
+	}
+
+	fields := strings.Split(string(line[:len(line)-2]), " ")
+	h := &Header{Version: VERSION_1}
+
+	switch {
+	case len(fields) >= 2 && fields[1] == "UNKNOWN":
+		return h, nil
+	case len(fields) != 6:
+		return nil, fmt.Errorf("%w: %q", ErrInvalidHeader, line)
+	}
+
+	src, err := parseV1Addr(fields[1], fields[2], fields[4])
+	if err != nil {
+		return nil, err
+	}
+	dst, err := parseV1Addr(fields[1], fields[3], fields[5])
+	if err != nil {
+		return nil, err
+	}
+	h.Source, h.Destination = src, dst
+
+	return h, nil
+}
+
+func parseV1Addr(family, host, port string) (net.Addr, error) {
+	ip, err := netip.ParseAddr(host)
+	if err != nil || ip.Zone() != "" {
+		return nil, fmt.Errorf("%w: address %q", ErrInvalidHeader, host)
+	}
+	if (family == "TCP4") != ip.Is4() || (family != "TCP4" && family != "TCP6") {
+		return nil, fmt.Errorf("%w: %s address %q", ErrInvalidHeader, family, host)
+	}
+
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("%w: port %q", ErrInvalidHeader, port)
+	}
+
+	return net.TCPAddrFromAddrPort(netip.AddrPortFrom(ip, uint16(p))), nil
+}
+
+// | V2_SIGNATURE(12B) | Version/Command(1B) | Family(1B) | Length(2B) |
+// | Source | Destination | Source Port(2B) | Destination Port(2B) | TLV ... |
+func readV2(r *bufio.Reader) (*Header, error) {
+	b := make([]byte, len(V2_SIGNATURE)+4)
+	_, err := io.ReadFull(r, b)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd, family := b[12], b[13]
+	body := make([]byte, binary.BigEndian.Uint16(b[14:]))
+	_, err = io.ReadFull(r, body)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &Header{Version: VERSION_2}
+	switch cmd {
+	case v2CmdLocal:
+		return h, nil
+	case v2CmdProxy:
+	default:
+		return nil, fmt.Errorf("%w: v2 command %#x", ErrInvalidHeader, cmd)
+	}
+
+	size := 0
+	switch family {
+	case v2FamilyTCP4, v2FamilyUDP4:
+		size = net.IPv4len
+	case v2FamilyTCP6, v2FamilyUDP6:
+		size = net.IPv6len
+	default:
+		// 유닉스 소켓 등 지원하지 않는 주소는 알 수 없는 것으로 취급한다.
+		return h, nil
+	}
+	if len(body) < 2*size+4 {
+		return nil, fmt.Errorf("%w: v2 address too short", ErrInvalidHeader)
+	}
+
+	src, _ := netip.AddrFromSlice(body[:size])
+	dst, _ := netip.AddrFromSlice(body[size : 2*size])
+	srcPort := binary.BigEndian.Uint16(body[2*size:])
+	dstPort := binary.BigEndian.Uint16(body[2*size+2:])
+
+	if family&0x0f == 0x02 {
+		h.Source = net.UDPAddrFromAddrPort(netip.AddrPortFrom(src, srcPort))
+		h.Destination = net.UDPAddrFromAddrPort(netip.AddrPortFrom(dst, dstPort))
+	} else {
+		h.Source = net.TCPAddrFromAddrPort(netip.AddrPortFrom(src, srcPort))
+		h.Destination = net.TCPAddrFromAddrPort(netip.AddrPortFrom(dst, dstPort))
+	}
+
+	return h, nil
+}
+
+func (h *Header) WriteTo(w io.Writer) (int64, error) {
+	var b []byte
+	var err error
+
+	switch h.Version {
+	case VERSION_1:
+		b, err = h.appendV1(nil)
+	case VERSION_2:
+		b, err = h.appendV2(nil)
+	default:
+		err = fmt.Errorf("unsupported proxy protocol version %d", h.Version)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := w.Write(b)
+
+	return int64(n), err
+}
+
+func (h *Header) appendV1(b []byte) ([]byte, error) {
+	src, dst, udp, err := h.addrs()
+	if err != nil {
+		return nil, err
+	}
+	// v1은 TCP만 나타낼 수 있다.
+	if !src.IsValid() || udp {
+		return append(b, "PROXY UNKNOWN\r\n"...), nil
+	}
+
+	family := "TCP6"
+	if src.Addr().Is4() {
+		family = "TCP4"
+	}
+
+	return fmt.Appendf(b, "PROXY %s %s %s %d %d\r\n",
+		family, src.Addr(), dst.Addr(), src.Port(), dst.Port()), nil
+}
+
+func (h *Header) appendV2(b []byte) ([]byte, error) {
+	src, dst, udp, err := h.addrs()
+	if err != nil {
+		return nil, err
+	}
+
+	b = append(b, V2_SIGNATURE...)
+	if !src.IsValid() {
+		return append(b, v2CmdLocal, v2FamilyUnspec, 0, 0), nil
+	}
+
+	family := v2FamilyTCP6
+	if src.Addr().Is4() {
+		family = v2FamilyTCP4
+	}
+	if udp {
+		family++
+	}
+
+	srcIP, dstIP := src.Addr().AsSlice(), dst.Addr().AsSlice()
+	b = append(b, v2CmdProxy, family)
+	b = binary.BigEndian.AppendUint16(b, uint16(2*len(srcIP)+4))
+	b = append(b, srcIP...)
+	b = append(b, dstIP...)
+	b = binary.BigEndian.AppendUint16(b, src.Port())
+	b = binary.BigEndian.AppendUint16(b, dst.Port())
+
+	return b, nil
+}
+
+// 두 주소를 같은 주소 체계로 맞춘다. 하나라도 IPv6이면 둘 다 IPv6로 나타낸다.
+func (h *Header) addrs() (src, dst netip.AddrPort, udp bool, err error) {
+	if h.Source == nil && h.Destination == nil {
+		return src, dst, false, nil
+	}
+
+	src, sUDP, err := addrPort(h.Source)
+	if err != nil {
+		return src, dst, false, err
+	}
+	dst, dUDP, err := addrPort(h.Destination)
+	if err != nil {
+		return src, dst, false, err
+	}
+	if sUDP != dUDP {
+		return src, dst, false, errors.New("source and destination networks differ")
+	}
+
+	if src.Addr().Is4() != dst.Addr().Is4() {
+		src = netip.AddrPortFrom(netip.AddrFrom16(src.Addr().As16()), src.Port())
+		dst = netip.AddrPortFrom(netip.AddrFrom16(dst.Addr().As16()), dst.Port())
+	}
+
+	return src, dst, sUDP, nil
+}
+
+func addrPort(a net.Addr) (netip.AddrPort, bool, error) {
+	var ap netip.AddrPort
+	udp := false
+
+	switch a := a.(type) {
+	case *net.TCPAddr:
+		ap = a.AddrPort()
+	case *net.UDPAddr:
+		ap, udp = a.AddrPort(), true
+	default:
+		return ap, false, fmt.Errorf("unsupported address %v", a)
+	}
+
+	// 헤더에는 영역을 나타낼 수 없다.
+	return netip.AddrPortFrom(ap.Addr().Unmap().WithZone(""), ap.Port()), udp, nil
+}
@@ -0,0 +1,270 @@
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func tcpAddr(s string) *net.TCPAddr {
+	a, err := net.ResolveTCPAddr("tcp", s)
+	if err != nil {
+		panic(err)
+	}
+
+	return a
+}
+
+func TestWriteHeader(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   Header
+		expected string
+	}{
+		{
+			"v1 tcp4",
+			Header{VERSION_1, tcpAddr("192.0.2.1:56324"), tcpAddr("192.0.2.2:443")},
+			"PROXY TCP4 192.0.2.1 192.0.2.2 56324 443\r\n",
+		},
+		{
+			"v1 tcp6",
+			Header{VERSION_1, tcpAddr("[2001:db8::1]:56324"), tcpAddr("[2001:db8::2]:443")},
+			"PROXY TCP6 2001:db8::1 2001:db8::2 56324 443\r\n",
+		},
+		{
+			"v1 mixed",
+			Header{VERSION_1, tcpAddr("192.0.2.1:56324"), tcpAddr("[2001:db8::2]:443")},
+			"PROXY TCP6 ::ffff:192.0.2.1 2001:db8::2 56324 443\r\n",
+		},
+		{
+			"v1 unknown",
+			Header{Version: VERSION_1},
+			"PROXY UNKNOWN\r\n",
+		},
+		{
+			"v2 tcp4",
+			Header{VERSION_2, tcpAddr("192.0.2.1:56324"), tcpAddr("192.0.2.2:443")},
+			V2_SIGNATURE + "\x21\x11\x00\x0c" +
+				"\xc0\x00\x02\x01" + "\xc0\x00\x02\x02" + "\xdc\x04" + "\x01\xbb",
+		},
+		{
+			"v2 local",
+			Header{Version: VERSION_2},
+			V2_SIGNATURE + "\x20\x00\x00\x00",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			_, err := tc.header.WriteTo(buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != tc.expected {
+				t.Errorf("expected\n%s\nactual\n%s", hex.Dump([]byte(tc.expected)), hex.Dump(buf.Bytes()))
+			}
+		})
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	addrs := []struct{ src, dst net.Addr }{
+		{tcpAddr("192.0.2.1:56324"), tcpAddr("192.0.2.2:443")},
+		{tcpAddr("[2001:db8::1]:1"), tcpAddr("[2001:db8::2]:65535")},
+		{
+			&net.UDPAddr{IP: net.ParseIP("192.0.2.1"), Port: 53},
+			&net.UDPAddr{IP: net.ParseIP("192.0.2.2"), Port: 5353},
+		},
+	}
+
+	for _, version := range []uint8{VERSION_1, VERSION_2} {
+		for _, a := range addrs {
+			_, udp := a.src.(*net.UDPAddr)
+			// v1은 UDP를 나타낼 수 없다.
+			if udp && version == VERSION_1 {
+				continue
+			}
+
+			buf := new(bytes.Buffer)
+			_, err := (&Header{Version: version, Source: a.src, Destination: a.dst}).WriteTo(buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			buf.WriteString("payload")
+
+			r := bufio.NewReader(buf)
+			h, err := ReadHeader(r)
+			if err != nil {
+				t.Fatalf("v%d %s: %v", version, a.src, err)
+			}
+			if h.Version != version ||
+				h.Source.String() != a.src.String() || h.Destination.String() != a.dst.String() ||
+				h.Source.Network() != a.src.Network() {
+				t.Errorf("v%d: expected %s -> %s; actual %+v", version, a.src, a.dst, h)
+			}
+
+			rest, _ := io.ReadAll(r)
+			if string(rest) != "payload" {
+				t.Errorf("v%d: expected payload after header; actual %q", version, rest)
+			}
+		}
+	}
+}
+
+func TestReadHeaderInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		err   error
+	}{
+		{"no header", "GET / HTTP/1.1\r\n", ErrNoHeader},
+		{"partial signature", "PROXX TCP4", ErrNoHeader},
+		{"v1 family mismatch", "PROXY TCP4 2001:db8::1 192.0.2.2 1 2\r\n", ErrInvalidHeader},
+		{"v1 bad port", "PROXY TCP4 192.0.2.1 192.0.2.2 1 65536\r\n", ErrInvalidHeader},
+		{"v1 missing fields", "PROXY TCP4 192.0.2.1\r\n", ErrInvalidHeader},
+		{"v1 too long", "PROXY " + strings.Repeat("A", MAX_V1_LENGTH) + "\r\n", ErrInvalidHeader},
+		{"v2 bad command", V2_SIGNATURE + "\x22\x11\x00\x00", ErrInvalidHeader},
+		{"v2 short address", V2_SIGNATURE + "\x21\x11\x00\x04\xc0\x00\x02\x01", ErrInvalidHeader},
+		{"v2 truncated", V2_SIGNATURE + "\x21\x11\x00\x0c\xc0", io.ErrUnexpectedEOF},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := bufio.NewReader(strings.NewReader(tc.input))
+			_, err := ReadHeader(r)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("expected %v; actual %v", tc.err, err)
+			}
+
+			// 헤더가 아니면 데이터를 그대로 남겨야 한다.
+			if tc.err == ErrNoHeader {
+				rest, _ := io.ReadAll(r)
+				if string(rest) != tc.input {
+					t.Errorf("expected input to be unread; actual %q", rest)
+				}
+			}
+		})
+	}
+}
+
+func TestListener(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pl := &Listener{Listener: l, Optional: true, HeaderTimeout: time.Second}
+	defer pl.Close()
+
+	src := tcpAddr("198.51.100.7:40000")
+	dst := tcpAddr("203.0.113.1:443")
+
+	tests := []struct {
+		name   string
+		header *Header
+		remote string
+	}{
+		{"v1", &Header{VERSION_1, src, dst}, src.String()},
+		{"v2", &Header{VERSION_2, src, dst}, src.String()},
+		{"local", &Header{Version: VERSION_2}, ""},
+		{"no header", nil, ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := net.Dial("tcp", l.Addr().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer client.Close()
+
+			if tc.header != nil {
+				_, err = tc.header.WriteTo(client)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			_, err = io.WriteString(client, "hello")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			conn, err := pl.Accept()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			remote := tc.remote
+			if remote == "" {
+				remote = client.LocalAddr().String()
+			}
+			if conn.RemoteAddr().String() != remote {
+				t.Errorf("expected remote address %s; actual %s", remote, conn.RemoteAddr())
+			}
+
+			buf := make([]byte, 5)
+			_, err = io.ReadFull(conn, buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(buf) != "hello" {
+				t.Errorf("expected %q; actual %q", "hello", buf)
+			}
+		})
+	}
+}
+
+func TestListenerRequired(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pl := &Listener{Listener: l, HeaderTimeout: 100 * time.Millisecond}
+	defer pl.Close()
+
+	tests := []struct {
+		name  string
+		input string
+		err   error
+	}{
+		{"no header", "hello", ErrNoHeader},
+		{"timeout", "", nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := net.Dial("tcp", l.Addr().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer client.Close()
+			_, _ = io.WriteString(client, tc.input)
+
+			conn, err := pl.Accept()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			_, err = conn.Read(make([]byte, 5))
+			if tc.err != nil {
+				if !errors.Is(err, tc.err) {
+					t.Errorf("expected %v; actual %v", tc.err, err)
+				}
+				return
+			}
+
+			var nErr net.Error
+			if !errors.As(err, &nErr) || !nErr.Timeout() {
+				t.Errorf("expected timeout; actual %v", err)
+			}
+		})
+	}
+}
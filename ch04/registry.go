@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+var (
+	ErrTypeRegistered = errors.New("type already registered")
+	ErrNilFactory     = errors.New("nil payload factory")
+	ErrReservedType   = errors.New("reserved type")
+)
+
+// 등록되지 않은 타입을 만나면 반환한다.
+type UnknownTypeError struct {
+	Type uint8
+}
+
+func (e *UnknownTypeError) Error() string {
+	return fmt.Sprintf("unknown type: %d", e.Type)
+}
+
+// 디코딩할 빈 페이로드를 만든다.
+// 페이로드는 | Type(1B) | Size(4B) | Payload | 형식으로 인코딩해야 한다.
+// Decoder가 헤더를 먼저 읽어 타입과 크기를 확인한 후 ReadFrom에 헤더부터 다시 넘겨준다.
+type PayloadFactory func() Payload
+
+// 타입별 PayloadFactory를 관리한다. Decoder는 Registry에서 찾은 타입만 디코딩한다.
+type Registry struct {
+	mu        sync.RWMutex
+	factories map[uint8]PayloadFactory
+}
+
+func NewRegistry() *Registry {
+	return &Registry{factories: make(map[uint8]PayloadFactory)}
+}
+
+func (reg *Registry) Register(typ uint8, factory PayloadFactory) error {
+	if factory == nil {
+		return ErrNilFactory
+	}
+	// Decoder가 먼저 처리하므로 등록해도 사용되지 않는다.
+	if typ == EXTENDED_TYPE {
+		return fmt.Errorf("%w: %d", ErrReservedType, typ)
+	}
+
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	if _, ok := reg.factories[typ]; ok {
+		return fmt.Errorf("%w: %d", ErrTypeRegistered, typ)
+	}
+	reg.factories[typ] = factory
+
+	return nil
+}
+
+func (reg *Registry) Lookup(typ uint8) (PayloadFactory, bool) {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+
+	factory, ok := reg.factories[typ]
+
+	return factory, ok
+}
+
+func (reg *Registry) New(typ uint8) (Payload, error) {
+	factory, ok := reg.Lookup(typ)
+	if !ok {
+		return nil, &UnknownTypeError{Type: typ}
+	}
+
+	return factory(), nil
+}
+
+func (reg *Registry) Decode(r io.Reader) (Payload, error) {
+	d := NewDecoder(r)
+	d.Registry = reg
+
+	return d.Decode()
+}
+
+var DefaultRegistry = NewRegistry()
+
+func init() {
+	_ = DefaultRegistry.Register(BINARY_TYPE, func() Payload { return new(Binary) })
+	_ = DefaultRegistry.Register(STRING_TYPE, func() Payload { return new(String) })
+	_ = DefaultRegistry.Register(INT8_TYPE, func() Payload { return new(Int8) })
+	_ = DefaultRegistry.Register(INT16_TYPE, func() Payload { return new(Int16) })
+	_ = DefaultRegistry.Register(INT32_TYPE, func() Payload { return new(Int32) })
+	_ = DefaultRegistry.Register(INT64_TYPE, func() Payload { return new(Int64) })
+	_ = DefaultRegistry.Register(UINT8_TYPE, func() Payload { return new(Uint8) })
+	_ = DefaultRegistry.Register(UINT16_TYPE, func() Payload { return new(Uint16) })
+	_ = DefaultRegistry.Register(UINT32_TYPE, func() Payload { return new(Uint32) })
+	_ = DefaultRegistry.Register(UINT64_TYPE, func() Payload { return new(Uint64) })
+	_ = DefaultRegistry.Register(FLOAT64_TYPE, func() Payload { return new(Float64) })
+	_ = DefaultRegistry.Register(BOOL_TYPE, func() Payload { return new(Bool) })
+	_ = DefaultRegistry.Register(TIME_TYPE, func() Payload { return new(Time) })
+	_ = DefaultRegistry.Register(LIST_TYPE, func() Payload { return new(List) })
+	_ = DefaultRegistry.Register(MAP_TYPE, func() Payload { return new(Map) })
+	_ = DefaultRegistry.Register(STREAM_TYPE, func() Payload { return new(Stream) })
+}
+
+func Register(typ uint8, factory PayloadFactory) error {
+	return DefaultRegistry.Register(typ, factory)
+}
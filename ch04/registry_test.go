@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestRegistryConflict(t *testing.T) {
+	reg := NewRegistry()
+
+	err := reg.Register(BINARY_TYPE, func() Payload { return new(Binary) })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = reg.Register(BINARY_TYPE, func() Payload { return new(String) })
+	if !errors.Is(err, ErrTypeRegistered) {
+		t.Fatalf("expected ErrTypeRegistered; got %v", err)
+	}
+
+	err = reg.Register(STRING_TYPE, nil)
+	if !errors.Is(err, ErrNilFactory) {
+		t.Fatalf("expected ErrNilFactory; got %v", err)
+	}
+
+	err = Register(STRING_TYPE, func() Payload { return new(String) })
+	if !errors.Is(err, ErrTypeRegistered) {
+		t.Fatalf("expected ErrTypeRegistered from default registry; got %v", err)
+	}
+
+	err = reg.Register(EXTENDED_TYPE, func() Payload { return new(Binary) })
+	if !errors.Is(err, ErrReservedType) {
+		t.Fatalf("expected ErrReservedType; got %v", err)
+	}
+}
+
+func TestRegistryDecode(t *testing.T) {
+	const customType uint8 = 0x7f
+
+	reg := NewRegistry()
+	err := reg.Register(customType, func() Payload { return new(upperString) })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := new(bytes.Buffer)
+	expected := upperString("HELLO")
+	_, err = expected.WriteTo(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual, err := reg.Decode(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&expected, actual) {
+		t.Errorf("value mismatch: %v != %v", &expected, actual)
+	}
+}
+
+func TestRegistryUnknownType(t *testing.T) {
+	_, err := NewRegistry().Decode(bytes.NewReader([]byte{0x42, 0, 0, 0, 0}))
+
+	var typeErr *UnknownTypeError
+	if !errors.As(err, &typeErr) {
+		t.Fatalf("expected UnknownTypeError; got %v", err)
+	}
+	if typeErr.Type != 0x42 {
+		t.Errorf("expected type 0x42; got %#x", typeErr.Type)
+	}
+}
+
+// 테스트용 사용자 정의 타입
+type upperString string
+
+func (m upperString) Bytes() []byte {
+	return []byte(m)
+}
+
+func (m upperString) String() string {
+	return string(m)
+}
+
+func (m upperString) WriteTo(w io.Writer) (int64, error) {
+	n, err := writeHeader(w, 0x7f, uint32(len(m)))
+	if err != nil {
+		return n, err
+	}
+
+	o, err := w.Write([]byte(m))
+
+	return n + int64(o), err
+}
+
+func (m *upperString) ReadFrom(r io.Reader) (int64, error) {
+	size, n, err := readHeader(r, 0x7f, "upperString")
+	if err != nil {
+		return n, err
+	}
+
+	buf := make([]byte, size)
+	o, err := readFull(r, buf)
+	*m = upperString(buf)
+
+	return n + int64(o), err
+}
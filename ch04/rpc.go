@@ -0,0 +1,397 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// RPC 메시지 종류
+const (
+	RPC_REQUEST uint8 = iota + 1
+	RPC_RESPONSE
+	RPC_CANCEL
+)
+
+var (
+	ErrHandlerRegistered = errors.New("handler already registered")
+	ErrClientClosed      = errors.New("rpc client closed")
+	ErrInvalidMessage    = errors.New("invalid rpc message")
+)
+
+// 원격 핸들러가 반환한 오류
+type RPCError struct {
+	Method  string
+	Message string
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("rpc %s: %s", e.Method, e.Message)
+}
+
+// RPC 메시지는 Map으로 인코딩한다.
+//
+//	kind    Uint8   RPC_REQUEST, RPC_RESPONSE, RPC_CANCEL
+//	id      Uint64  요청과 응답을 연결하는 ID
+//	method  String  요청
+//	timeout Int64   요청, 남은 시간(ms)
+//	body    Payload 요청, 응답 (선택)
+//	error   String  응답 (선택)
+type rpcMessage struct {
+	kind    uint8
+	id      uint64
+	method  string
+	timeout time.Duration
+	body    Payload
+	err     string
+}
+
+func (m rpcMessage) payload() Payload {
+	kind, id := Uint8(m.kind), Uint64(m.id)
+	p := Map{"kind": &kind, "id": &id}
+
+	if m.method != "" {
+		method := String(m.method)
+		p["method"] = &method
+	}
+	if m.timeout > 0 {
+		// 서버의 제한 시간이 클라이언트보다 먼저 끝나지 않도록 올림한다.
+		timeout := Int64((m.timeout + time.Millisecond - 1).Milliseconds())
+		p["timeout"] = &timeout
+	}
+	if m.body != nil {
+		p["body"] = m.body
+	}
+	if m.err != "" {
+		e := String(m.err)
+		p["error"] = &e
+	}
+
+	return &p
+}
+
+func parseRPCMessage(p Payload) (rpcMessage, error) {
+	var m rpcMessage
+
+	fields, ok := p.(*Map)
+	if !ok {
+		return m, fmt.Errorf("%w: %T", ErrInvalidMessage, p)
+	}
+
+	kind, ok := (*fields)["kind"].(*Uint8)
+	if !ok {
+		return m, fmt.Errorf("%w: missing kind", ErrInvalidMessage)
+	}
+	id, ok := (*fields)["id"].(*Uint64)
+	if !ok {
+		return m, fmt.Errorf("%w: missing id", ErrInvalidMessage)
+	}
+	m.kind, m.id = uint8(*kind), uint64(*id)
+
+	if method, ok := (*fields)["method"].(*String); ok {
+		m.method = string(*method)
+	}
+	if timeout, ok := (*fields)["timeout"].(*Int64); ok {
+		m.timeout = time.Duration(*timeout) * time.Millisecond
+	}
+	if e, ok := (*fields)["error"].(*String); ok {
+		m.err = string(*e)
+	}
+	m.body = (*fields)["body"]
+
+	return m, nil
+}
+
+type Handler func(ctx context.Context, body Payload) (Payload, error)
+
+type RPCServer struct {
+	mu       sync.RWMutex
+	handlers map[string]Handler
+}
+
+func NewRPCServer() *RPCServer {
+	return &RPCServer{handlers: make(map[string]Handler)}
+}
+
+func (s *RPCServer) Handle(method string, h Handler) error {
+	if h == nil {
+		return fmt.Errorf("nil handler for %q", method)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.handlers[method]; ok {
+		return fmt.Errorf("%w: %q", ErrHandlerRegistered, method)
+	}
+	s.handlers[method] = h
+
+	return nil
+}
+
+func (s *RPCServer) Serve(ctx context.Context, l net.Listener) error {
+	go func() {
+		<-ctx.Done()
+		_ = l.Close()
+	}()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("accept: %w", err)
+		}
+
+		go func() {
+			fc := NewFramedConn(conn)
+			defer fc.Close()
+
+			_ = s.ServeConn(ctx, fc)
+		}()
+	}
+}
+
+// 연결이 끊기거나 ctx가 취소될 때까지 요청을 처리한다.
+// 각 요청은 별도의 고루틴에서 처리하므로 응답 순서는 요청 순서와 다를 수 있다.
+func (s *RPCServer) ServeConn(ctx context.Context, conn *FramedConn) error {
+	ctx, cancel := context.WithCancel(ctx)
+
+	go func() {
+		<-ctx.Done()
+		_ = conn.Close()
+	}()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		inFlight = make(map[uint64]context.CancelFunc)
+	)
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	for {
+		p, err := conn.Receive()
+		if err != nil {
+			if err == io.EOF {
+				// 상대가 요청을 마쳤으므로 처리 중인 요청의 응답을 보낸다.
+				wg.Wait()
+				return nil
+			}
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		m, err := parseRPCMessage(p)
+		if err != nil {
+			return err
+		}
+
+		switch m.kind {
+		case RPC_CANCEL:
+			mu.Lock()
+			if cancelCall, ok := inFlight[m.id]; ok {
+				cancelCall()
+			}
+			mu.Unlock()
+		case RPC_REQUEST:
+			var (
+				callCtx    context.Context
+				cancelCall context.CancelFunc
+			)
+			if m.timeout > 0 {
+				callCtx, cancelCall = context.WithTimeout(ctx, m.timeout)
+			} else {
+				callCtx, cancelCall = context.WithCancel(ctx)
+			}
+
+			mu.Lock()
+			inFlight[m.id] = cancelCall
+			mu.Unlock()
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() {
+					mu.Lock()
+					delete(inFlight, m.id)
+					mu.Unlock()
+					cancelCall()
+				}()
+
+				resp := rpcMessage{kind: RPC_RESPONSE, id: m.id}
+				body, err := s.call(callCtx, m.method, m.body)
+				if err != nil {
+					resp.err = err.Error()
+				} else {
+					resp.body = body
+				}
+
+				_ = conn.Send(resp.payload())
+			}()
+		default:
+			return fmt.Errorf("%w: kind %d", ErrInvalidMessage, m.kind)
+		}
+	}
+}
+
+func (s *RPCServer) call(ctx context.Context, method string, body Payload) (Payload, error) {
+	s.mu.RLock()
+	h, ok := s.handlers[method]
+	s.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown method %q", method)
+	}
+
+	return h(ctx, body)
+}
+
+type rpcResult struct {
+	body Payload
+	err  error
+}
+
+type rpcCall struct {
+	method string
+	result chan rpcResult
+}
+
+// 하나의 연결에서 여러 요청을 동시에 처리한다.
+type RPCClient struct {
+	conn *FramedConn
+	done chan struct{}
+
+	mu      sync.Mutex
+	nextID  uint64
+	pending map[uint64]rpcCall
+	err     error
+}
+
+func NewRPCClient(conn *FramedConn) *RPCClient {
+	c := &RPCClient{
+		conn:    conn,
+		done:    make(chan struct{}),
+		pending: make(map[uint64]rpcCall),
+	}
+	go c.receive()
+
+	return c
+}
+
+func (c *RPCClient) Call(ctx context.Context, method string, body Payload) (Payload, error) {
+	result := make(chan rpcResult, 1)
+
+	c.mu.Lock()
+	if c.err != nil {
+		err := c.err
+		c.mu.Unlock()
+		return nil, err
+	}
+	c.nextID++
+	id := c.nextID
+	c.pending[id] = rpcCall{method: method, result: result}
+	c.mu.Unlock()
+
+	req := rpcMessage{kind: RPC_REQUEST, id: id, method: method, body: body}
+	if deadline, ok := ctx.Deadline(); ok {
+		req.timeout = time.Until(deadline)
+		if req.timeout <= 0 {
+			c.forget(id)
+			return nil, context.DeadlineExceeded
+		}
+	}
+
+	err := c.conn.Send(req.payload())
+	if err != nil {
+		c.forget(id)
+		return nil, err
+	}
+
+	select {
+	case r := <-result:
+		if r.err != nil && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return r.body, r.err
+	case <-ctx.Done():
+		c.forget(id)
+		// 서버에 처리 중인 요청을 취소하도록 알린다.
+		_ = c.conn.Send(rpcMessage{kind: RPC_CANCEL, id: id}.payload())
+
+		return nil, ctx.Err()
+	}
+}
+
+func (c *RPCClient) Close() error {
+	err := c.conn.Close()
+	<-c.done
+
+	return err
+}
+
+func (c *RPCClient) forget(id uint64) {
+	c.mu.Lock()
+	delete(c.pending, id)
+	c.mu.Unlock()
+}
+
+func (c *RPCClient) receive() {
+	defer close(c.done)
+
+	var err error
+	for {
+		var p Payload
+		p, err = c.conn.Receive()
+		if err != nil {
+			break
+		}
+
+		var m rpcMessage
+		m, err = parseRPCMessage(p)
+		if err != nil {
+			break
+		}
+		if m.kind != RPC_RESPONSE {
+			continue
+		}
+
+		c.mu.Lock()
+		call, ok := c.pending[m.id]
+		delete(c.pending, m.id)
+		c.mu.Unlock()
+
+		// 취소된 요청의 응답은 버린다.
+		if !ok {
+			continue
+		}
+
+		r := rpcResult{body: m.body}
+		if m.err != "" {
+			r.body, r.err = nil, &RPCError{Method: call.method, Message: m.err}
+		}
+		call.result <- r
+	}
+
+	if err == io.EOF || errors.Is(err, net.ErrClosed) {
+		err = ErrClientClosed
+	}
+
+	// 대기 중인 요청에 오류를 전달한다.
+	c.mu.Lock()
+	c.err = err
+	for id, call := range c.pending {
+		call.result <- rpcResult{err: err}
+		delete(c.pending, id)
+	}
+	c.mu.Unlock()
+}
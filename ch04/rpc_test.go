@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func rpcPair(t *testing.T, s *RPCServer) *RPCClient {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		err := s.Serve(ctx, listener)
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewRPCClient(NewFramedConn(conn))
+
+	t.Cleanup(func() {
+		_ = c.Close()
+		cancel()
+		<-done
+	})
+
+	return c
+}
+
+func TestRPCConcurrentCalls(t *testing.T) {
+	s := NewRPCServer()
+	err := s.Handle("sleep", func(ctx context.Context, body Payload) (Payload, error) {
+		d, ok := body.(*Int64)
+		if !ok {
+			return nil, fmt.Errorf("unexpected body %T", body)
+		}
+		time.Sleep(time.Duration(*d) * time.Millisecond)
+
+		return d, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.Handle("sleep", func(context.Context, Payload) (Payload, error) { return nil, nil })
+	if !errors.Is(err, ErrHandlerRegistered) {
+		t.Fatalf("expected ErrHandlerRegistered; got %v", err)
+	}
+
+	c := rpcPair(t, s)
+
+	// 먼저 보낸 요청이 늦게 끝나도 각 호출은 자신의 응답을 받는다.
+	var wg sync.WaitGroup
+	for i := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			d := Int64(100 - i*10)
+			resp, err := c.Call(context.Background(), "sleep", &d)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if resp.String() != d.String() {
+				t.Errorf("expected %s; got %s", d, resp)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestRPCUnknownMethod(t *testing.T) {
+	c := rpcPair(t, NewRPCServer())
+
+	_, err := c.Call(context.Background(), "missing", nil)
+	var rpcErr *RPCError
+	if !errors.As(err, &rpcErr) {
+		t.Fatalf("expected RPCError; got %v", err)
+	}
+	if rpcErr.Method != "missing" {
+		t.Errorf("expected method %q; got %q", "missing", rpcErr.Method)
+	}
+}
+
+func TestRPCCancellation(t *testing.T) {
+	canceled := make(chan error, 2)
+
+	s := NewRPCServer()
+	err := s.Handle("block", func(ctx context.Context, _ Payload) (Payload, error) {
+		<-ctx.Done()
+		canceled <- ctx.Err()
+
+		return nil, ctx.Err()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := rpcPair(t, s)
+
+	// 취소는 서버의 핸들러까지 전달된다.
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+	}()
+
+	_, err = c.Call(ctx, "block", nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled; got %v", err)
+	}
+	if err := <-canceled; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected handler context to be canceled; got %v", err)
+	}
+
+	// 제한 시간은 서버의 핸들러에도 적용된다.
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	_, err = c.Call(ctx, "block", nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded; got %v", err)
+	}
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Error("handler context was not canceled by timeout")
+	}
+}
+
+func TestRPCClientClosed(t *testing.T) {
+	s := NewRPCServer()
+	err := s.Handle("block", func(ctx context.Context, _ Payload) (Payload, error) {
+		<-ctx.Done()
+
+		return nil, ctx.Err()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := rpcPair(t, s)
+
+	result := make(chan error)
+	go func() {
+		_, err := c.Call(context.Background(), "block", nil)
+		result <- err
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	_ = c.Close()
+
+	if err := <-result; !errors.Is(err, ErrClientClosed) {
+		t.Fatalf("expected ErrClientClosed; got %v", err)
+	}
+
+	_, err = c.Call(context.Background(), "block", nil)
+	if !errors.Is(err, ErrClientClosed) {
+		t.Fatalf("expected ErrClientClosed after Close; got %v", err)
+	}
+}
@@ -0,0 +1,186 @@
+package socks5
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// SOCKS5 서버를 거쳐 연결한다. net.Dialer 대신 사용할 수 있다.
+type Dialer struct {
+	ProxyAddress string
+	// 비어 있으면 인증하지 않는다.
+	Username string
+	Password string
+	// 서버에 연결하는 시간. 0이면 제한하지 않는다.
+	Timeout time.Duration
+}
+
+func (d *Dialer) Dial(network, address string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, address)
+}
+
+// CONNECT 명령으로 address에 연결한다. address의 이름은 서버에서 찾는다.
+func (d *Dialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return nil, fmt.Errorf("socks5: unsupported network %q", network)
+	}
+
+	dst, err := ParseAddr(address)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, _, err := d.request(ctx, CMD_CONNECT, dst)
+
+	return conn, err
+}
+
+// UDP ASSOCIATE 명령으로 서버의 UDP 릴레이를 사용한다.
+// 반환한 PacketConn을 닫으면 연관도 끝난다.
+func (d *Dialer) ListenPacket(ctx context.Context) (net.PacketConn, error) {
+	conn, bound, err := d.request(ctx, CMD_UDP_ASSOCIATE, Addr{Host: net.IPv4zero.String()})
+	if err != nil {
+		return nil, err
+	}
+
+	relay := &net.UDPAddr{IP: bound.IP(), Port: int(bound.Port)}
+	if relay.IP == nil || relay.IP.IsUnspecified() {
+		// 서버가 주소를 알려 주지 않으면 제어 연결의 주소를 사용한다.
+		relay.IP = conn.RemoteAddr().(*net.TCPAddr).IP
+	}
+
+	pc, err := net.ListenUDP("udp", nil)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	// 릴레이의 응답과 비교할 수 있도록 주소 형식을 맞춘다.
+	relayAddr, err := net.ResolveUDPAddr("udp", relay.String())
+	if err != nil {
+		_ = conn.Close()
+		_ = pc.Close()
+		return nil, err
+	}
+
+	return &packetConn{PacketConn: pc, ctrl: conn, relay: relayAddr}, nil
+}
+
+func (d *Dialer) request(ctx context.Context, cmd uint8, dst Addr) (net.Conn, Addr, error) {
+	nd := net.Dialer{Timeout: d.Timeout}
+	conn, err := nd.DialContext(ctx, "tcp", d.ProxyAddress)
+	if err != nil {
+		return nil, Addr{}, err
+	}
+
+	// 협상 중에 ctx가 끝나면 읽기와 쓰기를 멈춘다.
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.SetDeadline(time.Unix(1, 0))
+	})
+
+	bound, err := d.handshake(conn, cmd, dst)
+	if !stop() || err != nil {
+		_ = conn.Close()
+		if ctx.Err() != nil {
+			return nil, Addr{}, ctx.Err()
+		}
+		return nil, Addr{}, err
+	}
+	_ = conn.SetDeadline(time.Time{})
+
+	return conn, bound, nil
+}
+
+func (d *Dialer) handshake(conn net.Conn, cmd uint8, dst Addr) (Addr, error) {
+	methods := []byte{VERSION, 1, METHOD_NO_AUTH}
+	if d.Username != "" {
+		methods = []byte{VERSION, 2, METHOD_NO_AUTH, METHOD_USER_PASS}
+	}
+
+	_, err := conn.Write(methods)
+	if err != nil {
+		return Addr{}, err
+	}
+
+	buf := make([]byte, 3)
+	_, err = io.ReadFull(conn, buf[:2])
+	if err != nil {
+		return Addr{}, err
+	}
+	if buf[0] != VERSION {
+		return Addr{}, ErrVersion
+	}
+
+	switch buf[1] {
+	case METHOD_NO_AUTH:
+	case METHOD_USER_PASS:
+		if d.Username == "" {
+			return Addr{}, ErrNoAcceptable
+		}
+		err = d.sendUserPass(conn)
+		if err != nil {
+			return Addr{}, err
+		}
+	default:
+		return Addr{}, ErrNoAcceptable
+	}
+
+	req, err := dst.appendTo([]byte{VERSION, cmd, 0})
+	if err != nil {
+		return Addr{}, err
+	}
+	_, err = conn.Write(req)
+	if err != nil {
+		return Addr{}, err
+	}
+
+	// | VER | REP | RSV | ATYP | BND.ADDR | BND.PORT |
+	_, err = io.ReadFull(conn, buf)
+	if err != nil {
+		return Addr{}, err
+	}
+	if buf[0] != VERSION {
+		return Addr{}, ErrVersion
+	}
+	if buf[1] != REP_SUCCEEDED {
+		return Addr{}, &ReplyError{Code: buf[1]}
+	}
+
+	return readAddr(conn)
+}
+
+func (d *Dialer) sendUserPass(conn net.Conn) error {
+	if len(d.Username) > 255 || len(d.Password) > 255 {
+		return errors.New("socks5: username or password too long")
+	}
+
+	b := []byte{USER_PASS_VERSION, byte(len(d.Username))}
+	b = append(b, d.Username...)
+	b = append(b, byte(len(d.Password)))
+	b = append(b, d.Password...)
+
+	_, err := conn.Write(b)
+	if err != nil {
+		return err
+	}
+
+	status := make([]byte, 2)
+	_, err = io.ReadFull(conn, status)
+	if err != nil {
+		return err
+	}
+	if status[1] != 0 {
+		return ErrAuthFailed
+	}
+
+	return nil
+}
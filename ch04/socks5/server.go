@@ -0,0 +1,300 @@
+package socks5
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/testaquatic/NetworkProgrammingWithGo/ch04/proxy"
+)
+
+const DEFAULT_HANDSHAKE_TIMEOUT = 10 * time.Second
+
+type Server struct {
+	// 비어 있으면 인증하지 않는다.
+	Users map[string]string
+	// 처음 일치한 규칙을 따른다.
+	Rules []proxy.Rule
+	// 일치하는 규칙이 없을 때 거부한다.
+	DefaultDeny bool
+	// 0이면 기본값을 사용한다.
+	HandshakeTimeout time.Duration
+	// nil이면 net.Dialer를 사용한다.
+	Dial func(ctx context.Context, network, address string) (net.Conn, error)
+	// 연결이 끝날 때마다 호출한다.
+	OnClose func(proxy.ConnStats)
+
+	conns proxy.Tracker
+}
+
+// ctx가 취소되면 더 이상 연결을 받지 않고 중계 중인 연결이 끝나기를 기다린다.
+func (s *Server) Serve(ctx context.Context, l net.Listener) error {
+	return proxy.Serve(ctx, l, func(conn net.Conn) {
+		s.conns.Add(conn)
+		defer s.conns.Remove(conn)
+
+		stats := s.ServeConn(ctx, conn)
+		if s.OnClose != nil {
+			s.OnClose(stats)
+		}
+	})
+}
+
+// 처리 중인 모든 연결을 끊는다.
+func (s *Server) Close() error {
+	s.conns.CloseAll()
+
+	return nil
+}
+
+// 요청 하나를 처리한다. conn은 호출한 쪽에서 닫는다.
+func (s *Server) ServeConn(ctx context.Context, conn net.Conn) (stats proxy.ConnStats) {
+	start := time.Now()
+	stats.Client = conn.RemoteAddr()
+	defer func() { stats.Duration = time.Since(start) }()
+
+	timeout := s.HandshakeTimeout
+	if timeout <= 0 {
+		timeout = DEFAULT_HANDSHAKE_TIMEOUT
+	}
+	_ = conn.SetDeadline(time.Now().Add(timeout))
+
+	err := s.authenticate(conn)
+	if err != nil {
+		stats.Err = err
+		return stats
+	}
+
+	// | VER | CMD | RSV |
+	header := make([]byte, 3)
+	_, err = io.ReadFull(conn, header)
+	if err != nil {
+		stats.Err = err
+		return stats
+	}
+	if header[0] != VERSION {
+		stats.Err = ErrVersion
+		return stats
+	}
+
+	dst, err := readAddr(conn)
+	if err != nil {
+		var rErr *ReplyError
+		if errors.As(err, &rErr) {
+			_ = reply(conn, rErr.Code, Addr{})
+		}
+		stats.Err = err
+		return stats
+	}
+	stats.Target = dst
+
+	switch header[1] {
+	case CMD_CONNECT:
+		target, err := s.connect(ctx, conn, dst)
+		if err != nil {
+			stats.Err = err
+			return stats
+		}
+		defer target.Close()
+
+		s.conns.Add(target)
+		defer s.conns.Remove(target)
+
+		stats.Target = target.RemoteAddr()
+		_ = conn.SetDeadline(time.Time{})
+		stats.Sent, stats.Received, stats.Err = proxy.Pipe(conn, target)
+	case CMD_UDP_ASSOCIATE:
+		stats.Sent, stats.Received, stats.Err = s.associate(ctx, conn, dst)
+	default:
+		_ = reply(conn, REP_COMMAND_NOT_SUPPORTED, Addr{})
+		stats.Err = &ReplyError{Code: REP_COMMAND_NOT_SUPPORTED}
+	}
+
+	return stats
+}
+
+func (s *Server) authenticate(conn net.Conn) error {
+	// | VER | NMETHODS | METHODS |
+	header := make([]byte, 2)
+	_, err := io.ReadFull(conn, header)
+	if err != nil {
+		return err
+	}
+	if header[0] != VERSION {
+		return ErrVersion
+	}
+
+	methods := make([]byte, header[1])
+	_, err = io.ReadFull(conn, methods)
+	if err != nil {
+		return err
+	}
+
+	want := byte(METHOD_NO_AUTH)
+	if len(s.Users) > 0 {
+		want = METHOD_USER_PASS
+	}
+
+	method := byte(METHOD_NO_ACCEPTABLE)
+	for _, m := range methods {
+		if m == want {
+			method = want
+		}
+	}
+
+	_, err = conn.Write([]byte{VERSION, method})
+	if err != nil {
+		return err
+	}
+
+	switch method {
+	case METHOD_NO_ACCEPTABLE:
+		return ErrNoAcceptable
+	case METHOD_USER_PASS:
+		return s.checkUserPass(conn)
+	}
+
+	return nil
+}
+
+// | VER | ULEN | UNAME | PLEN | PASSWD |
+func (s *Server) checkUserPass(conn net.Conn) error {
+	buf := make([]byte, 2)
+	_, err := io.ReadFull(conn, buf)
+	if err != nil {
+		return err
+	}
+	if buf[0] != USER_PASS_VERSION {
+		return fmt.Errorf("%w: subnegotiation version %d", ErrVersion, buf[0])
+	}
+
+	user := make([]byte, buf[1])
+	_, err = io.ReadFull(conn, user)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.ReadFull(conn, buf[:1])
+	if err != nil {
+		return err
+	}
+	pass := make([]byte, buf[0])
+	_, err = io.ReadFull(conn, pass)
+	if err != nil {
+		return err
+	}
+
+	expected, ok := s.Users[string(user)]
+	if !ok || subtle.ConstantTimeCompare([]byte(expected), pass) != 1 {
+		_, _ = conn.Write([]byte{USER_PASS_VERSION, 0x01})
+		return fmt.Errorf("%w: user %q", ErrAuthFailed, user)
+	}
+
+	_, err = conn.Write([]byte{USER_PASS_VERSION, 0x00})
+
+	return err
+}
+
+// 규칙을 확인하고 연결할 주소를 정한다.
+// 도메인 이름은 서버에서 찾아 찾은 주소에도 규칙을 적용한다.
+func (s *Server) resolve(ctx context.Context, dst Addr) ([]string, error) {
+	ips := []net.IP{dst.IP()}
+	if ips[0] == nil {
+		addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", dst.Host)
+		if err != nil || len(addrs) == 0 {
+			return nil, &ReplyError{Code: REP_HOST_UNREACHABLE}
+		}
+
+		ips = ips[:0]
+		for _, addr := range addrs {
+			ips = append(ips, net.IP(addr.Unmap().AsSlice()))
+		}
+	}
+
+	var addresses []string
+	for _, ip := range ips {
+		if proxy.Allowed(s.Rules, s.DefaultDeny, dst.Host, ip, dst.Port) {
+			addresses = append(addresses, Addr{Host: ip.String(), Port: dst.Port}.String())
+		}
+	}
+	if len(addresses) == 0 {
+		return nil, &ReplyError{Code: REP_NOT_ALLOWED}
+	}
+
+	return addresses, nil
+}
+
+// 찾은 주소에 차례로 연결한다.
+func (s *Server) connect(ctx context.Context, conn net.Conn, dst Addr) (net.Conn, error) {
+	addresses, err := s.resolve(ctx, dst)
+	if err != nil {
+		_ = reply(conn, replyCode(err), Addr{})
+		return nil, err
+	}
+
+	var target net.Conn
+	for _, address := range addresses {
+		if s.Dial != nil {
+			target, err = s.Dial(ctx, "tcp", address)
+		} else {
+			d := net.Dialer{Timeout: DEFAULT_HANDSHAKE_TIMEOUT}
+			target, err = d.DialContext(ctx, "tcp", address)
+		}
+		if err == nil {
+			break
+		}
+	}
+	if err != nil {
+		_ = reply(conn, replyCode(err), Addr{})
+		return nil, fmt.Errorf("dialing %s: %w", dst, err)
+	}
+
+	err = reply(conn, REP_SUCCEEDED, addrFromNet(target.LocalAddr()))
+	if err != nil {
+		_ = target.Close()
+		return nil, err
+	}
+
+	return target, nil
+}
+
+// | VER | REP | RSV | ATYP | BND.ADDR | BND.PORT |
+func reply(conn net.Conn, code uint8, bound Addr) error {
+	if bound.Host == "" {
+		bound.Host = net.IPv4zero.String()
+	}
+
+	b, err := bound.appendTo([]byte{VERSION, code, 0})
+	if err != nil {
+		return err
+	}
+
+	_, err = conn.Write(b)
+
+	return err
+}
+
+func replyCode(err error) uint8 {
+	var rErr *ReplyError
+	var nErr net.Error
+	switch {
+	case errors.As(err, &rErr):
+		return rErr.Code
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return REP_CONNECTION_REFUSED
+	case errors.Is(err, syscall.ENETUNREACH):
+		return REP_NETWORK_UNREACHABLE
+	case errors.Is(err, syscall.EHOSTUNREACH):
+		return REP_HOST_UNREACHABLE
+	// TTL expired는 IP 계층의 의미이므로 연결 시간 초과는 도달할 수 없는 것으로 본다.
+	case errors.As(err, &nErr) && nErr.Timeout():
+		return REP_HOST_UNREACHABLE
+	}
+
+	return REP_GENERAL_FAILURE
+}
@@ -0,0 +1,213 @@
+package socks5
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+)
+
+// RFC 1928
+const VERSION = 5
+
+// 인증 방법
+const (
+	METHOD_NO_AUTH       = 0x00
+	METHOD_USER_PASS     = 0x02
+	METHOD_NO_ACCEPTABLE = 0xff
+)
+
+// RFC 1929
+const USER_PASS_VERSION = 1
+
+// 요청 명령
+const (
+	CMD_CONNECT       = 0x01
+	CMD_BIND          = 0x02
+	CMD_UDP_ASSOCIATE = 0x03
+)
+
+// 주소 형식
+const (
+	ATYP_IPV4   = 0x01
+	ATYP_DOMAIN = 0x03
+	ATYP_IPV6   = 0x04
+)
+
+// 응답 코드
+const (
+	REP_SUCCEEDED = iota
+	REP_GENERAL_FAILURE
+	REP_NOT_ALLOWED
+	REP_NETWORK_UNREACHABLE
+	REP_HOST_UNREACHABLE
+	REP_CONNECTION_REFUSED
+	REP_TTL_EXPIRED
+	REP_COMMAND_NOT_SUPPORTED
+	REP_ADDRESS_NOT_SUPPORTED
+)
+
+var (
+	ErrVersion        = errors.New("unsupported socks version")
+	ErrAuthFailed     = errors.New("socks authentication failed")
+	ErrNoAcceptable   = errors.New("no acceptable authentication method")
+	ErrInvalidAddress = errors.New("invalid socks address")
+)
+
+var replyMessages = []string{
+	REP_SUCCEEDED:             "succeeded",
+	REP_GENERAL_FAILURE:       "general failure",
+	REP_NOT_ALLOWED:           "connection not allowed by ruleset",
+	REP_NETWORK_UNREACHABLE:   "network unreachable",
+	REP_HOST_UNREACHABLE:      "host unreachable",
+	REP_CONNECTION_REFUSED:    "connection refused",
+	REP_TTL_EXPIRED:           "TTL expired",
+	REP_COMMAND_NOT_SUPPORTED: "command not supported",
+	REP_ADDRESS_NOT_SUPPORTED: "address type not supported",
+}
+
+// 서버가 요청을 거부했다.
+type ReplyError struct {
+	Code uint8
+}
+
+func (e *ReplyError) Error() string {
+	if int(e.Code) < len(replyMessages) {
+		return "socks: " + replyMessages[e.Code]
+	}
+
+	return fmt.Sprintf("socks: reply %#x", e.Code)
+}
+
+// SOCKS 주소. Host는 IP 주소나 도메인 이름이다.
+type Addr struct {
+	Host string
+	Port uint16
+}
+
+func ParseAddr(address string) (Addr, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return Addr{}, err
+	}
+
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		// 서비스 이름
+		n, err := net.LookupPort("tcp", port)
+		if err != nil {
+			return Addr{}, fmt.Errorf("%w: port %q", ErrInvalidAddress, port)
+		}
+		p = uint64(n)
+	}
+
+	return Addr{Host: host, Port: uint16(p)}, nil
+}
+
+func (a Addr) Network() string {
+	return "socks5"
+}
+
+func (a Addr) String() string {
+	return net.JoinHostPort(a.Host, strconv.Itoa(int(a.Port)))
+}
+
+// IP 주소가 아니면 nil을 반환한다.
+func (a Addr) IP() net.IP {
+	return net.ParseIP(a.Host)
+}
+
+// | ATYP(1B) | DST.ADDR | DST.PORT(2B) |
+func (a Addr) appendTo(b []byte) ([]byte, error) {
+	ip := a.IP()
+	switch {
+	case ip == nil:
+		if len(a.Host) == 0 || len(a.Host) > 255 {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidAddress, a.Host)
+		}
+		b = append(b, ATYP_DOMAIN, byte(len(a.Host)))
+		b = append(b, a.Host...)
+	case ip.To4() != nil:
+		b = append(b, ATYP_IPV4)
+		b = append(b, ip.To4()...)
+	default:
+		b = append(b, ATYP_IPV6)
+		b = append(b, ip.To16()...)
+	}
+
+	return binary.BigEndian.AppendUint16(b, a.Port), nil
+}
+
+func readAddr(r io.Reader) (Addr, error) {
+	var a Addr
+
+	atyp := make([]byte, 1)
+	_, err := io.ReadFull(r, atyp)
+	if err != nil {
+		return a, err
+	}
+
+	var host []byte
+	switch atyp[0] {
+	case ATYP_IPV4:
+		host = make([]byte, net.IPv4len)
+	case ATYP_IPV6:
+		host = make([]byte, net.IPv6len)
+	case ATYP_DOMAIN:
+		size := make([]byte, 1)
+		_, err = io.ReadFull(r, size)
+		if err != nil {
+			return a, err
+		}
+		if size[0] == 0 {
+			return a, fmt.Errorf("%w: empty domain", ErrInvalidAddress)
+		}
+		host = make([]byte, size[0])
+	default:
+		return a, &ReplyError{Code: REP_ADDRESS_NOT_SUPPORTED}
+	}
+
+	_, err = io.ReadFull(r, host)
+	if err != nil {
+		return a, err
+	}
+
+	port := make([]byte, 2)
+	_, err = io.ReadFull(r, port)
+	if err != nil {
+		return a, err
+	}
+
+	if atyp[0] == ATYP_DOMAIN {
+		a.Host = string(host)
+	} else {
+		a.Host = net.IP(host).String()
+	}
+	a.Port = binary.BigEndian.Uint16(port)
+
+	return a, nil
+}
+
+func addrFromNet(addr net.Addr) Addr {
+	var (
+		ip   net.IP
+		port int
+	)
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		ip, port = a.IP, a.Port
+	case *net.UDPAddr:
+		ip, port = a.IP, a.Port
+	case Addr:
+		return a
+	default:
+		return Addr{Host: net.IPv4zero.String()}
+	}
+	if ip == nil {
+		ip = net.IPv4zero
+	}
+
+	return Addr{Host: ip.String(), Port: uint16(port)}
+}
@@ -0,0 +1,341 @@
+package socks5
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/netip"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/testaquatic/NetworkProgrammingWithGo/ch04/proxy"
+)
+
+func startServer(t *testing.T, s *Server) string {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = s.Serve(ctx, l)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		_ = s.Close()
+		<-done
+	})
+
+	return l.Addr().String()
+}
+
+// 받은 데이터를 그대로 돌려보낸다.
+func echoServer(t *testing.T, network string) net.Listener {
+	t.Helper()
+
+	l, err := net.Listen(network, "localhost:")
+	if err != nil {
+		t.Skip(err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, _ = io.Copy(conn, conn)
+			}()
+		}
+	}()
+
+	return l
+}
+
+func echo(t *testing.T, conn net.Conn, msg string) {
+	t.Helper()
+
+	_, err := conn.Write([]byte(msg))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, len(msg))
+	_, err = io.ReadFull(conn, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != msg {
+		t.Errorf("expected %q; actual %q", msg, buf)
+	}
+}
+
+func TestConnect(t *testing.T) {
+	proxyAddr := startServer(t, &Server{})
+	d := &Dialer{ProxyAddress: proxyAddr}
+
+	tcp4 := echoServer(t, "tcp4").Addr().(*net.TCPAddr)
+
+	tests := []struct {
+		name    string
+		network string
+		address string
+	}{
+		{"ipv4", "tcp4", tcp4.String()},
+		{"domain", "tcp", net.JoinHostPort("localhost", strconv.Itoa(tcp4.Port))},
+	}
+
+	if l, err := net.Listen("tcp6", "[::1]:"); err == nil {
+		_ = l.Close()
+		tcp6 := echoServer(t, "tcp6").Addr()
+		tests = append(tests, struct{ name, network, address string }{"ipv6", "tcp6", tcp6.String()})
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			conn, err := d.Dial(tc.network, tc.address)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			echo(t, conn, "hello via socks")
+		})
+	}
+}
+
+func TestConnectRefused(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	_ = l.Close()
+
+	d := &Dialer{ProxyAddress: startServer(t, &Server{})}
+
+	_, err = d.Dial("tcp", addr)
+	var rErr *ReplyError
+	if !errors.As(err, &rErr) || rErr.Code != REP_CONNECTION_REFUSED {
+		t.Errorf("expected connection refused reply; actual %v", err)
+	}
+}
+
+// 연결 시간 초과는 TTL expired가 아니라 host unreachable로 응답한다.
+func TestConnectTimeout(t *testing.T) {
+	s := &Server{
+		Dial: func(context.Context, string, string) (net.Conn, error) {
+			return nil, os.ErrDeadlineExceeded
+		},
+	}
+	d := &Dialer{ProxyAddress: startServer(t, s)}
+
+	_, err := d.Dial("tcp", "127.0.0.1:7")
+	var rErr *ReplyError
+	if !errors.As(err, &rErr) || rErr.Code != REP_HOST_UNREACHABLE {
+		t.Errorf("expected host unreachable reply; actual %v", err)
+	}
+}
+
+func TestUserPass(t *testing.T) {
+	target := echoServer(t, "tcp4").Addr().String()
+	proxyAddr := startServer(t, &Server{Users: map[string]string{"alice": "secret"}})
+
+	tests := []struct {
+		name     string
+		user     string
+		password string
+		err      error
+	}{
+		{"valid", "alice", "secret", nil},
+		{"wrong password", "alice", "guess", ErrAuthFailed},
+		{"unknown user", "bob", "secret", ErrAuthFailed},
+		{"no credentials", "", "", ErrNoAcceptable},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			d := &Dialer{ProxyAddress: proxyAddr, Username: tc.user, Password: tc.password}
+			conn, err := d.Dial("tcp", target)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("expected %v; actual %v", tc.err, err)
+			}
+			if err == nil {
+				echo(t, conn, "authenticated")
+				_ = conn.Close()
+			}
+		})
+	}
+}
+
+func TestRules(t *testing.T) {
+	target := echoServer(t, "tcp4").Addr().(*net.TCPAddr)
+	allowedPort := target.Port
+
+	rules := []proxy.Rule{
+		{Host: "127.0.0.0/8", Port: uint16(allowedPort)},
+		{Deny: true, Host: "*"},
+	}
+	d := &Dialer{ProxyAddress: startServer(t, &Server{Rules: rules})}
+
+	conn, err := d.Dial("tcp", target.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.Close()
+
+	// 도메인으로 요청해도 찾은 주소에 규칙을 적용한다.
+	conn, err = d.Dial("tcp", net.JoinHostPort("localhost", strconv.Itoa(allowedPort)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.Close()
+
+	_, err = d.Dial("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(allowedPort+1)))
+	var rErr *ReplyError
+	if !errors.As(err, &rErr) || rErr.Code != REP_NOT_ALLOWED {
+		t.Errorf("expected not allowed reply; actual %v", err)
+	}
+}
+
+func TestUDPAssociate(t *testing.T) {
+	echoUDP, err := net.ListenPacket("udp", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer echoUDP.Close()
+
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			n, addr, err := echoUDP.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			_, _ = echoUDP.WriteTo(buf[:n], addr)
+		}
+	}()
+
+	d := &Dialer{ProxyAddress: startServer(t, &Server{})}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	pc, err := d.ListenPacket(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	for _, msg := range []string{"first", "second"} {
+		_, err = pc.WriteTo([]byte(msg), echoUDP.LocalAddr())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_ = pc.SetReadDeadline(time.Now().Add(2 * time.Second))
+		buf := make([]byte, 1024)
+		n, from, err := pc.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(buf[:n]) != msg {
+			t.Errorf("expected %q; actual %q", msg, buf[:n])
+		}
+		if from.String() != echoUDP.LocalAddr().String() {
+			t.Errorf("expected reply from %s; actual %s", echoUDP.LocalAddr(), from)
+		}
+	}
+}
+
+// Serve의 ctx가 끝나면 Close를 호출하지 않아도 UDP 중계가 끝난다.
+func TestUDPAssociateShutdown(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error)
+	go func() { done <- (&Server{}).Serve(ctx, l) }()
+
+	d := &Dialer{ProxyAddress: l.Addr().String()}
+	pc, err := d.ListenPacket(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected Serve to return after the UDP relay ended")
+	}
+}
+
+func TestUDPPeerLimit(t *testing.T) {
+	r := &udpRelay{peers: make(map[netip.AddrPort]time.Time)}
+	now := time.Now()
+
+	expired := netip.MustParseAddrPort("192.0.2.1:53")
+	r.addPeerLocked(expired, now.Add(-udpPeerTimeout))
+	for i := range udpMaxPeers - 1 {
+		r.addPeerLocked(netip.AddrPortFrom(netip.MustParseAddr("198.51.100.1"), uint16(1000+i)), now.Add(time.Duration(i)))
+	}
+
+	// 만료된 목적지부터 지운다.
+	r.addPeerLocked(netip.MustParseAddrPort("203.0.113.1:53"), now.Add(time.Minute))
+	if _, ok := r.peers[expired]; ok || len(r.peers) != udpMaxPeers {
+		t.Fatalf("expected expired peer to be evicted; %d peers", len(r.peers))
+	}
+
+	// 만료된 목적지가 없으면 가장 오래된 목적지를 지운다.
+	oldest := netip.AddrPortFrom(netip.MustParseAddr("198.51.100.1"), 1000)
+	r.addPeerLocked(netip.MustParseAddrPort("203.0.113.2:53"), now.Add(time.Minute))
+	if _, ok := r.peers[oldest]; ok || len(r.peers) != udpMaxPeers {
+		t.Errorf("expected oldest peer to be evicted; %d peers", len(r.peers))
+	}
+}
+
+func TestUDPRules(t *testing.T) {
+	blocked, err := net.ListenPacket("udp", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer blocked.Close()
+
+	d := &Dialer{ProxyAddress: startServer(t, &Server{Rules: []proxy.Rule{{Deny: true, Host: "*"}}})}
+
+	pc, err := d.ListenPacket(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	_, err = pc.WriteTo([]byte("blocked"), blocked.LocalAddr())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_ = blocked.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	_, _, err = blocked.ReadFrom(make([]byte, 16))
+	var nErr net.Error
+	if !errors.As(err, &nErr) || !nErr.Timeout() {
+		t.Errorf("expected datagram to be dropped; actual %v", err)
+	}
+}
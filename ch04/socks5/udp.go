@@ -0,0 +1,302 @@
+package socks5
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+// UDP 데이터그램의 최대 크기
+const maxDatagramSize = 65535
+
+const (
+	// 릴레이가 목적지 주소를 찾는 시간
+	udpResolveTimeout = 5 * time.Second
+	// 동시에 찾을 수 있는 호스트 이름의 수. 넘으면 데이터그램을 버린다.
+	udpMaxResolving = 8
+	// 답을 받을 목적지의 최대 수와 마지막으로 보낸 후 답을 받는 시간
+	udpMaxPeers    = 256
+	udpPeerTimeout = 2 * time.Minute
+)
+
+// 제어 연결이 닫히거나 ctx가 끝날 때까지 UDP 데이터그램을 중계한다.
+func (s *Server) associate(ctx context.Context, conn net.Conn, dst Addr) (sent, received int64, err error) {
+	var localIP net.IP
+	if local, ok := conn.LocalAddr().(*net.TCPAddr); ok {
+		localIP = local.IP
+	}
+
+	pc, err := net.ListenUDP("udp", &net.UDPAddr{IP: localIP})
+	if err != nil {
+		_ = reply(conn, REP_GENERAL_FAILURE, Addr{})
+		return 0, 0, err
+	}
+	defer pc.Close()
+
+	err = reply(conn, REP_SUCCEEDED, addrFromNet(pc.LocalAddr()))
+	if err != nil {
+		return 0, 0, err
+	}
+	_ = conn.SetDeadline(time.Time{})
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	r := &udpRelay{
+		server:    s,
+		pc:        pc,
+		peers:     make(map[netip.AddrPort]time.Time),
+		resolving: make(chan struct{}, udpMaxResolving),
+	}
+	if remote, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
+		r.clientIP, _ = netip.AddrFromSlice(remote.IP)
+		r.clientIP = r.clientIP.Unmap()
+	}
+	// 클라이언트가 보낼 주소를 알려 주지 않으면 처음 받은 데이터그램으로 정한다.
+	if ip, ok := netip.AddrFromSlice(dst.IP()); ok && !ip.IsUnspecified() && dst.Port != 0 {
+		r.client = netip.AddrPortFrom(ip.Unmap(), dst.Port)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r.run(ctx)
+	}()
+
+	// 제어 연결의 읽기를 깨워 중계를 끝낸다.
+	stop := context.AfterFunc(ctx, func() { _ = conn.SetReadDeadline(time.Now()) })
+	defer stop()
+
+	_, _ = io.Copy(io.Discard, conn)
+	stop()
+	// 주소를 찾고 있는 데이터그램도 버린다.
+	cancel()
+	_ = pc.Close()
+	<-done
+
+	return r.sent, r.received, nil
+}
+
+type udpRelay struct {
+	server   *Server
+	pc       *net.UDPConn
+	clientIP netip.Addr
+	client   netip.AddrPort
+	// 호스트 이름을 찾는 고루틴의 수를 제한한다.
+	resolving chan struct{}
+	wg        sync.WaitGroup
+
+	mu sync.Mutex
+	// 클라이언트가 보낸 적이 있는 목적지와 마지막으로 보낸 시각. 다른 곳에서 온 데이터그램은 버린다.
+	peers          map[netip.AddrPort]time.Time
+	sent, received int64
+}
+
+// 주소를 찾고 있는 데이터그램까지 처리한 후 반환한다.
+func (r *udpRelay) run(ctx context.Context) {
+	defer r.wg.Wait()
+
+	buf := make([]byte, maxDatagramSize)
+	for {
+		n, from, err := r.pc.ReadFromUDPAddrPort(buf)
+		if err != nil {
+			return
+		}
+		from = netip.AddrPortFrom(from.Addr().Unmap(), from.Port())
+
+		switch {
+		case r.isClient(from):
+			r.fromClient(ctx, buf[:n])
+		case r.isPeer(from):
+			r.toClient(from, buf[:n])
+		}
+	}
+}
+
+func (r *udpRelay) isClient(from netip.AddrPort) bool {
+	if r.client.IsValid() {
+		return from == r.client
+	}
+	if from.Addr() == r.clientIP {
+		r.client = from
+		return true
+	}
+
+	return false
+}
+
+// | RSV(2B) | FRAG(1B) | ATYP | DST.ADDR | DST.PORT | DATA |
+func (r *udpRelay) fromClient(ctx context.Context, p []byte) {
+	// 조각난 데이터그램은 지원하지 않는다.
+	if len(p) < 4 || p[2] != 0 {
+		return
+	}
+
+	br := bytes.NewReader(p[3:])
+	dst, err := readAddr(br)
+	if err != nil {
+		return
+	}
+	data := p[len(p)-br.Len():]
+
+	if dst.IP() != nil {
+		r.forward(ctx, dst, data)
+		return
+	}
+
+	// 이름을 찾는 동안 다른 데이터그램의 중계를 멈추지 않는다.
+	select {
+	case r.resolving <- struct{}{}:
+	default:
+		return
+	}
+	data = bytes.Clone(data)
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		defer func() { <-r.resolving }()
+
+		r.forward(ctx, dst, data)
+	}()
+}
+
+func (r *udpRelay) forward(ctx context.Context, dst Addr, data []byte) {
+	ctx, cancel := context.WithTimeout(ctx, udpResolveTimeout)
+	addresses, err := r.server.resolve(ctx, dst)
+	cancel()
+	if err != nil {
+		return
+	}
+
+	to, err := netip.ParseAddrPort(addresses[0])
+	if err != nil {
+		return
+	}
+
+	n, err := r.pc.WriteToUDPAddrPort(data, to)
+	if err != nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.sent += int64(n)
+	r.addPeerLocked(to, time.Now())
+}
+
+func (r *udpRelay) isPeer(from netip.AddrPort) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	last, ok := r.peers[from]
+
+	return ok && time.Since(last) < udpPeerTimeout
+}
+
+// 목적지가 udpMaxPeers를 넘으면 만료된 목적지를 지우고, 그래도 넘으면 가장 오래된 목적지를 지운다.
+func (r *udpRelay) addPeerLocked(to netip.AddrPort, now time.Time) {
+	if _, ok := r.peers[to]; !ok && len(r.peers) >= udpMaxPeers {
+		var oldest netip.AddrPort
+		for peer, last := range r.peers {
+			if now.Sub(last) >= udpPeerTimeout {
+				delete(r.peers, peer)
+				continue
+			}
+			if !oldest.IsValid() || last.Before(r.peers[oldest]) {
+				oldest = peer
+			}
+		}
+		if len(r.peers) >= udpMaxPeers {
+			delete(r.peers, oldest)
+		}
+	}
+	r.peers[to] = now
+}
+
+func (r *udpRelay) toClient(from netip.AddrPort, p []byte) {
+	src := Addr{Host: from.Addr().String(), Port: from.Port()}
+	b, err := src.appendTo([]byte{0, 0, 0})
+	if err != nil {
+		return
+	}
+
+	_, err = r.pc.WriteToUDPAddrPort(append(b, p...), r.client)
+	if err == nil {
+		r.mu.Lock()
+		r.received += int64(len(p))
+		r.mu.Unlock()
+	}
+}
+
+// SOCKS5 UDP 릴레이를 통해 데이터그램을 주고받는다.
+type packetConn struct {
+	net.PacketConn
+	ctrl  net.Conn
+	relay net.Addr
+}
+
+func (c *packetConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	dst, ok := addr.(Addr)
+	if !ok {
+		var err error
+		dst, err = ParseAddr(addr.String())
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	b, err := dst.appendTo([]byte{0, 0, 0})
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = c.PacketConn.WriteTo(append(b, p...), c.relay)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// 데이터그램을 보낸 원래 주소를 반환한다. IP 주소면 *net.UDPAddr이다.
+func (c *packetConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	buf := make([]byte, maxDatagramSize)
+	for {
+		n, from, err := c.PacketConn.ReadFrom(buf)
+		if err != nil {
+			return 0, nil, err
+		}
+		// 릴레이가 보내지 않은 데이터그램은 버린다.
+		if from.String() != c.relay.String() || n < 4 || buf[2] != 0 {
+			continue
+		}
+
+		br := bytes.NewReader(buf[3:n])
+		src, err := readAddr(br)
+		if err != nil {
+			continue
+		}
+
+		var addr net.Addr = src
+		if ip := src.IP(); ip != nil {
+			addr = &net.UDPAddr{IP: ip, Port: int(src.Port)}
+		}
+
+		return copy(p, buf[n-br.Len():n]), addr, nil
+	}
+}
+
+func (c *packetConn) Close() error {
+	err := c.ctrl.Close()
+	if pErr := c.PacketConn.Close(); !errors.Is(pErr, net.ErrClosed) && pErr != nil {
+		err = pErr
+	}
+
+	return err
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+const DEFAULT_CHUNK_SIZE = 32 << 10 // 32KB
+
+// 크기를 미리 알 수 없는 데이터를 청크 단위로 전송한다.
+// 헤더의 Size는 0이고 길이가 0인 청크로 끝난다.
+// | Type(1B) | Size(4B)=0 | ChunkSize(4B) | Chunk | ... | ChunkSize(4B)=0 |
+//
+// 수신한 Stream의 Body는 연결에서 직접 읽으므로
+// 다음 프레임을 디코딩하기 전에 Body를 끝까지 읽어야 한다.
+type Stream struct {
+	ChunkSize int
+
+	body io.Reader
+}
+
+func NewStream(body io.Reader) *Stream {
+	return &Stream{ChunkSize: DEFAULT_CHUNK_SIZE, body: body}
+}
+
+func (m *Stream) Body() io.Reader {
+	if m.body == nil {
+		return eofReader{}
+	}
+
+	return m.body
+}
+
+// 메모리에 전체를 올리지 않으므로 nil을 반환한다.
+func (m *Stream) Bytes() []byte {
+	return nil
+}
+
+func (m *Stream) String() string {
+	return "stream"
+}
+
+func (m *Stream) WriteTo(w io.Writer) (int64, error) {
+	n, err := writeHeader(w, STREAM_TYPE, 0)
+	if err != nil {
+		return n, err
+	}
+
+	size := m.ChunkSize
+	if size <= 0 {
+		size = DEFAULT_CHUNK_SIZE
+	}
+
+	// 청크 크기 4바이트 + 청크
+	buf := make([]byte, 4+size)
+	body := m.Body()
+	for {
+		o, rErr := body.Read(buf[4:])
+		if o > 0 {
+			binary.BigEndian.PutUint32(buf[:4], uint32(o))
+			p, err := w.Write(buf[:4+o])
+			n += int64(p)
+			if err != nil {
+				return n, err
+			}
+		}
+
+		if rErr == io.EOF {
+			break
+		}
+		if rErr != nil {
+			return n, rErr
+		}
+	}
+
+	// 종료 표시
+	err = binary.Write(w, binary.BigEndian, uint32(0))
+	if err != nil {
+		return n, err
+	}
+
+	return n + 4, nil
+}
+
+// 헤더만 읽고 Body는 r에서 청크를 차례로 읽는다.
+func (m *Stream) ReadFrom(r io.Reader) (int64, error) {
+	size, n, err := readHeader(r, STREAM_TYPE, "Stream")
+	if err != nil {
+		return n, err
+	}
+	if size != 0 {
+		return n, errors.New("invalid Stream")
+	}
+
+	m.body = &chunkedReader{r: r, limit: payloadLimit(r)}
+
+	return n, nil
+}
+
+type chunkedReader struct {
+	r         io.Reader
+	limit     uint32
+	remaining uint32
+	err       error
+}
+
+func (c *chunkedReader) Read(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+
+	if c.remaining == 0 {
+		var size uint32
+		err := binary.Read(c.r, binary.BigEndian, &size)
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			c.err = err
+
+			return 0, err
+		}
+
+		switch {
+		case size == 0:
+			c.err = io.EOF
+
+			return 0, io.EOF
+		case size > c.limit:
+			c.err = &MaxPayloadSizeError{Size: size, Limit: c.limit}
+
+			return 0, c.err
+		}
+		c.remaining = size
+	}
+
+	if uint32(len(p)) > c.remaining {
+		p = p[:c.remaining]
+	}
+
+	n, err := c.r.Read(p)
+	c.remaining -= uint32(n)
+	if err == io.EOF {
+		// 종료 표시 전에 스트림이 끝났다.
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		c.err = err
+	}
+
+	return n, err
+}
+
+type eofReader struct{}
+
+func (eofReader) Read([]byte) (int, error) {
+	return 0, io.EOF
+}
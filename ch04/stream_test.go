@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestStreamOverTCP(t *testing.T) {
+	const size = 64 << 20 // 64MB
+
+	listener, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	sent := sha256.New()
+	trailer := String("done")
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			t.Log(err)
+			return
+		}
+		defer conn.Close()
+
+		body := io.TeeReader(io.LimitReader(rand.New(rand.NewSource(1)), size), sent)
+		_, err = NewStream(body).WriteTo(conn)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		_, err = trailer.WriteTo(conn)
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// 전체 크기보다 작은 제한으로도 받을 수 있어야 한다.
+	d := NewDecoder(conn)
+	d.MaxPayloadSize = 1 << 20
+
+	p, err := d.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream, ok := p.(*Stream)
+	if !ok {
+		t.Fatalf("expected *Stream; got %T", p)
+	}
+
+	received := sha256.New()
+	n, err := io.Copy(received, stream.Body())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != size {
+		t.Errorf("expected %d bytes; got %d", size, n)
+	}
+	if !bytes.Equal(sent.Sum(nil), received.Sum(nil)) {
+		t.Error("stream body checksum mismatch")
+	}
+
+	// 스트림 뒤의 프레임도 정상적으로 읽을 수 있다.
+	actual, err := d.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&trailer, actual) {
+		t.Errorf("value mismatch: %v != %v", &trailer, actual)
+	}
+}
+
+func TestStreamTruncated(t *testing.T) {
+	buf := new(bytes.Buffer)
+	_, err := NewStream(bytes.NewReader([]byte("Don't panic."))).WriteTo(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 종료 표시를 제거한다.
+	frame := buf.Bytes()[:buf.Len()-4]
+
+	p, err := decode(bytes.NewReader(frame))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = io.ReadAll(p.(*Stream).Body())
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected io.ErrUnexpectedEOF; got %v", err)
+	}
+}
+
+func TestStreamChunkLimit(t *testing.T) {
+	s := NewStream(bytes.NewReader(make([]byte, 1024)))
+	s.ChunkSize = 1024
+
+	buf := new(bytes.Buffer)
+	_, err := s.WriteTo(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewDecoder(buf)
+	d.MaxPayloadSize = 512
+	p, err := d.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = io.ReadAll(p.(*Stream).Body())
+	if !errors.Is(err, ErrMaxPayloadSize) {
+		t.Fatalf("expected ErrMaxPayloadSize; got %v", err)
+	}
+}
+
+func TestStreamNested(t *testing.T) {
+	list := List{NewStream(bytes.NewReader([]byte("Don't panic.")))}
+
+	buf := new(bytes.Buffer)
+	_, err := list.WriteTo(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = decode(buf)
+	if !errors.Is(err, ErrNestedStream) {
+		t.Fatalf("expected ErrNestedStream; got %v", err)
+	}
+}
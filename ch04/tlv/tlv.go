@@ -0,0 +1,36 @@
+// TLV 형식의 타입과 확장 프레임 플래그.
+// ch04와 tlvdump가 같은 값을 사용하도록 한곳에서 정의한다.
+package tlv
+
+// 페이로드 타입
+const (
+	BINARY_TYPE uint8 = iota + 1
+	STRING_TYPE
+	INT8_TYPE
+	INT16_TYPE
+	INT32_TYPE
+	INT64_TYPE
+	UINT8_TYPE
+	UINT16_TYPE
+	UINT32_TYPE
+	UINT64_TYPE
+	FLOAT64_TYPE
+	BOOL_TYPE
+	TIME_TYPE
+	LIST_TYPE
+	MAP_TYPE
+	STREAM_TYPE
+
+	// 기존 타입과 겹치지 않도록 마지막 값을 사용한다.
+	EXTENDED_TYPE uint8 = 0xff
+)
+
+// 확장 프레임 플래그
+const (
+	FLAG_CHECKSUM uint8 = 1 << iota
+	FLAG_GZIP
+	FLAG_DEFLATE
+
+	COMPRESSION_FLAGS = FLAG_GZIP | FLAG_DEFLATE
+	KNOWN_FLAGS       = FLAG_CHECKSUM | COMPRESSION_FLAGS
+)
@@ -0,0 +1,558 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/testaquatic/NetworkProgrammingWithGo/ch04/tlv"
+)
+
+var (
+	jsonOut = flag.Bool("json", false, "print frames as JSON lines")
+	preview = flag.Int("preview", 16, "number of payload bytes to preview")
+	maxSize = flag.Uint("max", 10<<20, "maximum payload size")
+	listen  = flag.String("listen", "", "listen address for tap mode")
+	forward = flag.String("forward", "", "upstream address for tapped connections")
+)
+
+func init() {
+	flag.Usage = func() {
+		fmt.Printf("Usage: %s [options] [file ...]\n", os.Args[0])
+		fmt.Printf("       %s [options] -listen addr [-forward addr]\n", os.Args[0])
+		fmt.Println("Reads stdin if no file is given or the file is \"-\".")
+		fmt.Println("Options:")
+		flag.PrintDefaults()
+	}
+}
+
+var typeNames = map[uint8]string{
+	tlv.BINARY_TYPE:   "Binary",
+	tlv.STRING_TYPE:   "String",
+	tlv.INT8_TYPE:     "Int8",
+	tlv.INT16_TYPE:    "Int16",
+	tlv.INT32_TYPE:    "Int32",
+	tlv.INT64_TYPE:    "Int64",
+	tlv.UINT8_TYPE:    "Uint8",
+	tlv.UINT16_TYPE:   "Uint16",
+	tlv.UINT32_TYPE:   "Uint32",
+	tlv.UINT64_TYPE:   "Uint64",
+	tlv.FLOAT64_TYPE:  "Float64",
+	tlv.BOOL_TYPE:     "Bool",
+	tlv.TIME_TYPE:     "Time",
+	tlv.LIST_TYPE:     "List",
+	tlv.MAP_TYPE:      "Map",
+	tlv.STREAM_TYPE:   "Stream",
+	tlv.EXTENDED_TYPE: "Extended",
+}
+
+// 고정 길이 타입의 페이로드 크기
+var fixedSizes = map[uint8]uint32{
+	tlv.INT8_TYPE:    1,
+	tlv.INT16_TYPE:   2,
+	tlv.INT32_TYPE:   4,
+	tlv.INT64_TYPE:   8,
+	tlv.UINT8_TYPE:   1,
+	tlv.UINT16_TYPE:  2,
+	tlv.UINT32_TYPE:  4,
+	tlv.UINT64_TYPE:  8,
+	tlv.FLOAT64_TYPE: 8,
+	tlv.BOOL_TYPE:    1,
+	tlv.TIME_TYPE:    12,
+}
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+type Frame struct {
+	Source string `json:"source,omitempty"`
+	// 압축된 확장 프레임 안쪽은 압축을 푼 데이터 기준 오프셋
+	Offset int64  `json:"offset"`
+	Depth  int    `json:"depth,omitempty"`
+	Type   uint8  `json:"type"`
+	Name   string `json:"name"`
+	Length uint32 `json:"length"`
+	Hex    string `json:"hex,omitempty"`
+	ASCII  string `json:"ascii,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+func (f Frame) String() string {
+	var b strings.Builder
+
+	if f.Source != "" {
+		fmt.Fprintf(&b, "[%s] ", f.Source)
+	}
+	fmt.Fprintf(&b, "%08x  %s%-10s %8d", f.Offset, strings.Repeat("  ", f.Depth), f.Name, f.Length)
+
+	if f.Hex != "" {
+		fmt.Fprintf(&b, "  %s |%s|", f.Hex, f.ASCII)
+	}
+	if f.Error != "" {
+		fmt.Fprintf(&b, "  !! %s", f.Error)
+	}
+
+	return b.String()
+}
+
+func typeName(typ uint8) string {
+	if name, ok := typeNames[typ]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("unknown(%d)", typ)
+}
+
+type Inspector struct {
+	Source  string
+	Preview int
+	MaxSize uint32
+	Emit    func(Frame) error
+
+	// 잘못된 프레임 수
+	Malformed int
+}
+
+// 잘못된 프레임을 만나도 다음 프레임을 찾아 계속 진행한다.
+// 스트림이 끝나면 nil을 반환한다.
+func (in *Inspector) Inspect(r io.Reader) error {
+	return in.inspect(bufio.NewReader(r), 0, 0)
+}
+
+func (in *Inspector) inspect(r *bufio.Reader, base int64, depth int) error {
+	offset := base
+
+	for {
+		header, err := r.Peek(5)
+		if len(header) == 0 && err == io.EOF {
+			return nil
+		}
+		if len(header) < 5 {
+			if err != io.EOF {
+				return err
+			}
+			_, _ = r.Discard(len(header))
+
+			return in.malformed(Frame{Offset: offset, Depth: depth, Type: header[0], Name: typeName(header[0])},
+				fmt.Sprintf("truncated header: %d bytes", len(header)))
+		}
+
+		typ, size := header[0], binary.BigEndian.Uint32(header[1:])
+		f := Frame{Offset: offset, Depth: depth, Type: typ, Name: typeName(typ), Length: size}
+
+		// 크기를 신뢰할 수 없으면 한 바이트씩 이동하며 다음 프레임을 찾는다.
+		if size > in.MaxSize {
+			_, _ = r.Discard(1)
+			offset++
+
+			err = in.malformed(f, fmt.Sprintf("size %d exceeds maximum %d", size, in.MaxSize))
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		_, _ = r.Discard(5)
+		offset += 5
+
+		payload := make([]byte, size)
+		n, err := io.ReadFull(r, payload)
+		offset += int64(n)
+		if err != nil {
+			if err != io.EOF && err != io.ErrUnexpectedEOF {
+				return err
+			}
+			in.setPreview(&f, payload[:n])
+
+			return in.malformed(f, fmt.Sprintf("truncated payload: %d of %d bytes", n, size))
+		}
+		in.setPreview(&f, payload)
+
+		// 크기가 0인 확장 프레임은 확장 프레임을 지원한다는 광고다.
+		if typ == tlv.EXTENDED_TYPE && size == 0 && depth == 0 {
+			f.Name = "Advertise"
+			err = in.emit(f)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		if msg := in.validate(typ, payload); msg != "" {
+			err = in.malformed(f, msg)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		err = in.emit(f)
+		if err != nil {
+			return err
+		}
+
+		// 중첩된 프레임
+		switch typ {
+		case tlv.LIST_TYPE, tlv.MAP_TYPE:
+			err = in.inspect(bufio.NewReader(bytes.NewReader(payload)), f.Offset+5, depth+1)
+		case tlv.EXTENDED_TYPE:
+			err = in.inspectExtended(payload, f.Offset+5, depth+1)
+		case tlv.STREAM_TYPE:
+			var o int64
+			o, err = in.inspectChunks(r, offset, depth+1)
+			offset += o
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (in *Inspector) validate(typ uint8, payload []byte) string {
+	if _, ok := typeNames[typ]; !ok {
+		return "unknown type"
+	}
+
+	if want, ok := fixedSizes[typ]; ok && uint32(len(payload)) != want {
+		return fmt.Sprintf("invalid size: want %d", want)
+	}
+
+	switch typ {
+	case tlv.BOOL_TYPE:
+		if payload[0] > 1 {
+			return fmt.Sprintf("invalid Bool value %d", payload[0])
+		}
+	case tlv.TIME_TYPE:
+		// | Seconds(8B) | Nanoseconds(4B) |
+		if nsec := binary.BigEndian.Uint32(payload[8:]); nsec >= uint32(time.Second) {
+			return fmt.Sprintf("invalid Time nanoseconds %d", nsec)
+		}
+	case tlv.STREAM_TYPE:
+		if len(payload) != 0 {
+			return "Stream header size must be 0"
+		}
+	case tlv.EXTENDED_TYPE:
+		if len(payload) < 5 {
+			return "missing extended header"
+		}
+	}
+
+	return ""
+}
+
+func (in *Inspector) inspectExtended(payload []byte, base int64, depth int) error {
+	if len(payload) < 5 {
+		return nil
+	}
+
+	flags, sum, body := payload[0], binary.BigEndian.Uint32(payload[1:5]), payload[5:]
+	f := Frame{Offset: base, Depth: depth, Type: flags, Name: "flags", Length: 5}
+
+	var names []string
+	if flags&tlv.FLAG_CHECKSUM != 0 {
+		names = append(names, "crc32c")
+	}
+	if flags&tlv.FLAG_GZIP != 0 {
+		names = append(names, "gzip")
+	}
+	if flags&tlv.FLAG_DEFLATE != 0 {
+		names = append(names, "deflate")
+	}
+	f.ASCII = strings.Join(names, ",")
+	f.Hex = fmt.Sprintf("%02x %08x", flags, sum)
+
+	switch {
+	case flags&^tlv.KNOWN_FLAGS != 0:
+		return in.malformed(f, fmt.Sprintf("unknown flags %#x", flags))
+	case flags&tlv.COMPRESSION_FLAGS == tlv.COMPRESSION_FLAGS:
+		return in.malformed(f, "both gzip and deflate flags set")
+	case flags&tlv.FLAG_CHECKSUM != 0 && crc32.Checksum(body, castagnoli) != sum:
+		return in.malformed(f, "checksum mismatch")
+	}
+
+	err := in.emit(f)
+	if err != nil {
+		return err
+	}
+
+	var r io.Reader = bytes.NewReader(body)
+	switch {
+	case flags&tlv.FLAG_GZIP != 0:
+		r, err = gzip.NewReader(r)
+		if err != nil {
+			return in.malformed(f, err.Error())
+		}
+	case flags&tlv.FLAG_DEFLATE != 0:
+		r = flate.NewReader(r)
+	default:
+		return in.inspect(bufio.NewReader(r), base+5, depth)
+	}
+
+	// 안쪽 프레임의 헤더까지 허용하고 한 바이트를 더 읽어 제한을 넘었는지 확인한다.
+	limit := int64(in.MaxSize) + 5
+	raw, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return in.malformed(f, "decompress: "+err.Error())
+	}
+	if int64(len(raw)) > limit {
+		return in.malformed(f, fmt.Sprintf("decompressed size exceeds maximum %d", in.MaxSize))
+	}
+
+	return in.inspect(bufio.NewReader(bytes.NewReader(raw)), 0, depth)
+}
+
+// Stream의 청크를 종료 표시까지 읽는다. 읽은 바이트 수를 반환한다.
+func (in *Inspector) inspectChunks(r *bufio.Reader, base int64, depth int) (int64, error) {
+	var n int64
+
+	for {
+		f := Frame{Offset: base + n, Depth: depth, Name: "chunk"}
+
+		buf := make([]byte, 4)
+		o, err := io.ReadFull(r, buf)
+		n += int64(o)
+		if err != nil {
+			if err != io.EOF && err != io.ErrUnexpectedEOF {
+				return n, err
+			}
+			return n, in.malformed(f, "truncated Stream: missing end marker")
+		}
+
+		size := binary.BigEndian.Uint32(buf)
+		f.Length = size
+		if size == 0 {
+			f.Name = "end"
+			return n, in.emit(f)
+		}
+		if size > in.MaxSize {
+			// 청크 경계를 알 수 없으므로 다음 바이트부터 프레임을 찾는다.
+			return n, in.malformed(f, fmt.Sprintf("chunk size %d exceeds maximum %d", size, in.MaxSize))
+		}
+
+		chunk := make([]byte, size)
+		o, err = io.ReadFull(r, chunk)
+		n += int64(o)
+		in.setPreview(&f, chunk[:o])
+		if err != nil {
+			if err != io.EOF && err != io.ErrUnexpectedEOF {
+				return n, err
+			}
+			return n, in.malformed(f, fmt.Sprintf("truncated chunk: %d of %d bytes", o, size))
+		}
+
+		err = in.emit(f)
+		if err != nil {
+			return n, err
+		}
+	}
+}
+
+func (in *Inspector) setPreview(f *Frame, payload []byte) {
+	if len(payload) > in.Preview {
+		payload = payload[:in.Preview]
+	}
+	if len(payload) == 0 {
+		return
+	}
+
+	f.Hex = fmt.Sprintf("% x", payload)
+
+	ascii := make([]byte, len(payload))
+	for i, b := range payload {
+		if b < 0x20 || b > 0x7e {
+			b = '.'
+		}
+		ascii[i] = b
+	}
+	f.ASCII = string(ascii)
+}
+
+func (in *Inspector) malformed(f Frame, msg string) error {
+	in.Malformed++
+	f.Error = msg
+
+	return in.emit(f)
+}
+
+func (in *Inspector) emit(f Frame) error {
+	f.Source = in.Source
+
+	return in.Emit(f)
+}
+
+type printer struct {
+	mu   sync.Mutex
+	w    io.Writer
+	json bool
+}
+
+func (p *printer) Print(f Frame) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.json {
+		return json.NewEncoder(p.w).Encode(f)
+	}
+
+	_, err := fmt.Fprintln(p.w, f)
+
+	return err
+}
+
+func main() {
+	flag.Parse()
+
+	p := &printer{w: os.Stdout, json: *jsonOut}
+
+	if *listen != "" {
+		err := tap(*listen, *forward, p)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	files := flag.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+
+	malformed := 0
+	for _, file := range files {
+		n, err := inspectFile(file, p, len(files) > 1)
+		malformed += n
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if malformed > 0 {
+		fmt.Fprintf(os.Stderr, "%d malformed frame(s)\n", malformed)
+		os.Exit(1)
+	}
+}
+
+func newInspector(source string, p *printer) *Inspector {
+	return &Inspector{
+		Source:  source,
+		Preview: *preview,
+		MaxSize: uint32(*maxSize),
+		Emit:    p.Print,
+	}
+}
+
+func inspectFile(file string, p *printer, named bool) (int, error) {
+	var r io.Reader = os.Stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return 0, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	source := ""
+	if named {
+		source = file
+	}
+
+	in := newInspector(source, p)
+	err := in.Inspect(r)
+
+	return in.Malformed, err
+}
+
+// 연결마다 받은 데이터를 검사한다. forward가 있으면 양방향으로 중계하며 검사한다.
+func tap(addr, forward string, p *printer) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("binding to tcp %s: %w", addr, err)
+	}
+
+	go func() {
+		c := make(chan os.Signal, 1)
+		signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+		<-c
+		_ = l.Close()
+	}()
+
+	log.Printf("tapping on %s", l.Addr())
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return fmt.Errorf("accept: %w", err)
+		}
+
+		go func() {
+			defer conn.Close()
+
+			err := tapConn(conn, forward, p)
+			if err != nil {
+				log.Printf("%s: %v", conn.RemoteAddr(), err)
+			}
+		}()
+	}
+}
+
+func tapConn(conn net.Conn, forward string, p *printer) error {
+	client := conn.RemoteAddr().String()
+
+	if forward == "" {
+		return newInspector(client, p).Inspect(conn)
+	}
+
+	upstream, err := net.Dial("tcp", forward)
+	if err != nil {
+		return err
+	}
+	defer upstream.Close()
+
+	var wg sync.WaitGroup
+	relay := func(dst, src net.Conn, source string) {
+		defer wg.Done()
+
+		// 검사 결과를 출력하는 속도에 맞춰 중계한다.
+		pr, pw := io.Pipe()
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			_ = newInspector(source, p).Inspect(pr)
+			_, _ = io.Copy(io.Discard, pr)
+		}()
+
+		_, _ = io.Copy(dst, io.TeeReader(src, pw))
+		_ = pw.Close()
+		<-done
+
+		if c, ok := dst.(interface{ CloseWrite() error }); ok {
+			_ = c.CloseWrite()
+		}
+	}
+
+	wg.Add(2)
+	go relay(upstream, conn, client+" -> "+forward)
+	go relay(conn, upstream, forward+" -> "+client)
+	wg.Wait()
+
+	return nil
+}
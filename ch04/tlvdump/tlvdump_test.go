@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"hash/crc32"
+	"strings"
+	"testing"
+
+	"github.com/testaquatic/NetworkProgrammingWithGo/ch04/tlv"
+)
+
+func frame(typ uint8, payload []byte) []byte {
+	b := make([]byte, 5, 5+len(payload))
+	b[0] = typ
+	binary.BigEndian.PutUint32(b[1:], uint32(len(payload)))
+
+	return append(b, payload...)
+}
+
+func inspectAll(t *testing.T, stream []byte) ([]Frame, int) {
+	t.Helper()
+
+	var frames []Frame
+	in := &Inspector{
+		Preview: 8,
+		MaxSize: 1 << 10,
+		Emit: func(f Frame) error {
+			frames = append(frames, f)
+			return nil
+		},
+	}
+
+	err := in.Inspect(bytes.NewReader(stream))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return frames, in.Malformed
+}
+
+func TestInspectKeepsGoing(t *testing.T) {
+	var stream []byte
+	stream = append(stream, frame(tlv.STRING_TYPE, []byte("Errors are values."))...)
+	// 알 수 없는 타입은 건너뛴다.
+	stream = append(stream, frame(0x42, []byte{1, 2, 3})...)
+	// 고정 길이 타입의 크기가 맞지 않는다.
+	stream = append(stream, frame(tlv.INT32_TYPE, []byte{1, 2})...)
+	// 크기를 믿을 수 없으므로 한 바이트씩 다음 프레임을 찾는다.
+	stream = append(stream, tlv.BINARY_TYPE, 0xff, 0xff, 0xff, 0xff)
+	stream = append(stream, frame(tlv.BOOL_TYPE, []byte{1})...)
+	// 잘린 프레임
+	stream = append(stream, frame(tlv.BINARY_TYPE, []byte("Don't panic."))[:10]...)
+
+	frames, malformed := inspectAll(t, stream)
+
+	var names []string
+	for _, f := range frames {
+		names = append(names, f.Name)
+	}
+	t.Logf("frames: %v", names)
+
+	if frames[0].Name != "String" || frames[0].Error != "" || frames[0].ASCII != "Errors a" {
+		t.Errorf("unexpected first frame: %+v", frames[0])
+	}
+
+	var found bool
+	for _, f := range frames {
+		if f.Name == "Bool" && f.Error == "" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("did not resynchronize to Bool frame after oversized frame")
+	}
+
+	last := frames[len(frames)-1]
+	if !strings.HasPrefix(last.Error, "truncated payload") {
+		t.Errorf("expected truncated payload; got %+v", last)
+	}
+
+	// 알 수 없는 타입, 크기 오류, 크기 초과 5번(재동기화), 잘린 프레임
+	if malformed != 8 {
+		t.Errorf("expected 8 malformed frames; got %d", malformed)
+	}
+}
+
+func TestInspectNested(t *testing.T) {
+	list := append(frame(tlv.INT8_TYPE, []byte{7}), frame(tlv.STRING_TYPE, []byte("nested"))...)
+	ext := []byte{tlv.FLAG_CHECKSUM, 0, 0, 0, 0}
+	inner := frame(tlv.LIST_TYPE, list)
+	binary.BigEndian.PutUint32(ext[1:], crc32.Checksum(inner, castagnoli))
+
+	stream := frame(tlv.EXTENDED_TYPE, append(ext, inner...))
+
+	chunks := frame(tlv.STREAM_TYPE, nil)
+	chunks = append(chunks, 0, 0, 0, 3, 'a', 'b', 'c', 0, 0, 0, 0)
+	stream = append(stream, chunks...)
+
+	frames, malformed := inspectAll(t, stream)
+	if malformed != 0 {
+		t.Fatalf("unexpected malformed frames: %+v", frames)
+	}
+
+	expected := []struct {
+		name  string
+		depth int
+	}{
+		{"Extended", 0}, {"flags", 1}, {"List", 1}, {"Int8", 2}, {"String", 2},
+		{"Stream", 0}, {"chunk", 1}, {"end", 1},
+	}
+	if len(frames) != len(expected) {
+		t.Fatalf("expected %d frames; got %d: %+v", len(expected), len(frames), frames)
+	}
+	for i, e := range expected {
+		if frames[i].Name != e.name || frames[i].Depth != e.depth {
+			t.Errorf("%d: expected %s at depth %d; got %s at depth %d",
+				i, e.name, e.depth, frames[i].Name, frames[i].Depth)
+		}
+	}
+
+	// 오프셋은 원래 스트림 기준이다.
+	if frames[3].Offset != 5+5+5 {
+		t.Errorf("expected Int8 at offset 15; got %d", frames[3].Offset)
+	}
+
+	// 체크섬이 맞지 않는 경우
+	stream[len(stream)-len(chunks)-1] ^= 0xff
+	_, malformed = inspectAll(t, stream)
+	if malformed != 1 {
+		t.Errorf("expected checksum mismatch; got %d malformed frames", malformed)
+	}
+}
+
+// 디코더가 거부하는 나노초는 잘못된 프레임으로 보고한다.
+func TestInspectTime(t *testing.T) {
+	valid := binary.BigEndian.AppendUint32(make([]byte, 8), 999_999_999)
+	invalid := binary.BigEndian.AppendUint32(make([]byte, 8), 1_000_000_000)
+
+	frames, malformed := inspectAll(t, append(frame(tlv.TIME_TYPE, valid), frame(tlv.TIME_TYPE, invalid)...))
+	if malformed != 1 || frames[0].Error != "" || !strings.Contains(frames[1].Error, "nanoseconds") {
+		t.Errorf("expected only the second Time to be malformed; got %+v", frames)
+	}
+}
+
+func TestInspectAdvertisement(t *testing.T) {
+	stream := append(frame(tlv.EXTENDED_TYPE, nil), frame(tlv.BOOL_TYPE, []byte{1})...)
+
+	frames, malformed := inspectAll(t, stream)
+	if malformed != 0 || len(frames) != 2 || frames[0].Name != "Advertise" {
+		t.Errorf("expected advertisement followed by Bool; got %+v", frames)
+	}
+}
+
+func TestInspectExtendedFlags(t *testing.T) {
+	inner := frame(tlv.INT8_TYPE, []byte{7})
+	stream := frame(tlv.EXTENDED_TYPE, append([]byte{tlv.FLAG_GZIP | tlv.FLAG_DEFLATE, 0, 0, 0, 0}, inner...))
+
+	frames, malformed := inspectAll(t, stream)
+	if malformed != 1 || !strings.Contains(frames[len(frames)-1].Error, "gzip and deflate") {
+		t.Errorf("expected both compression flags to be malformed; got %+v", frames)
+	}
+}
+
+// 압축을 푼 크기가 제한을 넘으면 잘라서 보여주지 않고 오류로 보고한다.
+func TestInspectDecompressedLimit(t *testing.T) {
+	compressed := new(bytes.Buffer)
+	zw := gzip.NewWriter(compressed)
+	_, _ = zw.Write(frame(tlv.BINARY_TYPE, make([]byte, 4<<10)))
+	_ = zw.Close()
+
+	stream := frame(tlv.EXTENDED_TYPE, append([]byte{tlv.FLAG_GZIP, 0, 0, 0, 0}, compressed.Bytes()...))
+
+	frames, malformed := inspectAll(t, stream)
+	if malformed != 1 || !strings.Contains(frames[len(frames)-1].Error, "exceeds maximum") {
+		t.Errorf("expected decompressed size error; got %+v", frames)
+	}
+	for _, f := range frames {
+		if f.Name == "Binary" {
+			t.Errorf("expected truncated payload not to be dumped; got %+v", f)
+		}
+	}
+}
+
+func TestPrinter(t *testing.T) {
+	f := Frame{Offset: 16, Type: tlv.STRING_TYPE, Name: "String", Length: 4, Hex: "70 69 6e 67", ASCII: "ping"}
+
+	buf := new(bytes.Buffer)
+	p := &printer{w: buf}
+	err := p.Print(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "00000010  String") || !strings.Contains(buf.String(), "|ping|") {
+		t.Errorf("unexpected text output %q", buf.String())
+	}
+
+	buf.Reset()
+	p.json = true
+	err = p.Print(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var actual Frame
+	err = json.Unmarshal(buf.Bytes(), &actual)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual != f {
+		t.Errorf("expected %+v; got %+v", f, actual)
+	}
+}
+
+func FuzzInspect(f *testing.F) {
+	f.Add(frame(tlv.STRING_TYPE, []byte("Errors are values.")))
+	f.Add(frame(tlv.LIST_TYPE, append(frame(tlv.INT8_TYPE, []byte{7}), frame(tlv.BOOL_TYPE, []byte{1})...)))
+	f.Add(append(frame(tlv.STREAM_TYPE, nil), 0, 0, 0, 1, 'a', 0, 0, 0, 0))
+	f.Add(frame(tlv.EXTENDED_TYPE, append([]byte{tlv.FLAG_GZIP, 0, 0, 0, 0}, 0x1f, 0x8b)))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var last int64
+		in := &Inspector{
+			Preview: 16,
+			MaxSize: 1 << 16,
+			Emit: func(f Frame) error {
+				// 최상위 프레임의 오프셋은 줄어들지 않는다.
+				if f.Depth == 0 {
+					if f.Offset < last || f.Offset > int64(len(data)) {
+						t.Fatalf("unexpected offset %d after %d", f.Offset, last)
+					}
+					last = f.Offset
+				}
+				return nil
+			},
+		}
+
+		err := in.Inspect(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -6,13 +6,30 @@ import (
 	"errors"
 	"fmt"
 	"io"
+
+	"github.com/testaquatic/NetworkProgrammingWithGo/ch04/tlv"
 )
 
+// 값은 tlv 패키지에서 정의한다.
 const (
-	BINARY_TYPE uint8 = iota + 1
-	STRING_TYPE
-
-	MAX_PAYLOAD_SIZE uint32 = 10 << 20 // 10MB
+	BINARY_TYPE  = tlv.BINARY_TYPE
+	STRING_TYPE  = tlv.STRING_TYPE
+	INT8_TYPE    = tlv.INT8_TYPE
+	INT16_TYPE   = tlv.INT16_TYPE
+	INT32_TYPE   = tlv.INT32_TYPE
+	INT64_TYPE   = tlv.INT64_TYPE
+	UINT8_TYPE   = tlv.UINT8_TYPE
+	UINT16_TYPE  = tlv.UINT16_TYPE
+	UINT32_TYPE  = tlv.UINT32_TYPE
+	UINT64_TYPE  = tlv.UINT64_TYPE
+	FLOAT64_TYPE = tlv.FLOAT64_TYPE
+	BOOL_TYPE    = tlv.BOOL_TYPE
+	TIME_TYPE    = tlv.TIME_TYPE
+	LIST_TYPE    = tlv.LIST_TYPE
+	MAP_TYPE     = tlv.MAP_TYPE
+	STREAM_TYPE  = tlv.STREAM_TYPE
+
+	MAX_PAYLOAD_SIZE uint32 = 10 << 20 // 10MB, Decoder의 기본 제한
 )
 
 var ErrMaxPayloadSize = errors.New("maximum payload size exceeded")
@@ -56,32 +73,15 @@ func (m Binary) WriteTo(w io.Writer) (int64, error) {
 }
 
 func (m *Binary) ReadFrom(r io.Reader) (int64, error) {
-	var typ uint8
-	err := binary.Read(r, binary.BigEndian, &typ)
-	if err != nil {
-		return 0, err
-	}
-
-	// 읽은 바이트수
-	var n int64 = 1
-	if typ != BINARY_TYPE {
-		return n, errors.New("invalid Binary")
-	}
-
-	var size uint32
-	// 4바이트
-	err = binary.Read(r, binary.BigEndian, &size)
+	size, n, err := readHeader(r, BINARY_TYPE, "Binary")
 	if err != nil {
 		return n, err
 	}
-	n += 4
 
-	if size > MAX_PAYLOAD_SIZE {
-		return n, ErrMaxPayloadSize
-	}
+	// 한 번의 Read로 페이로드 전체를 읽는다는 보장이 없다.
+	buf, o, err := readPayload(r, size)
+	*m = buf
 
-	*m = make([]byte, size)
-	o, err := r.Read(*m)
 	return n + int64(o), err
 }
 
@@ -118,58 +118,93 @@ func (m String) WriteTo(w io.Writer) (int64, error) {
 }
 
 func (m *String) ReadFrom(r io.Reader) (int64, error) {
-	var typ uint8
-	err := binary.Read(r, binary.BigEndian, &typ)
+	size, n, err := readHeader(r, STRING_TYPE, "String")
 	if err != nil {
-		return 0, err
-	}
-	// 읽은 바이트수
-	var n int64 = 1
-	if typ != STRING_TYPE {
-		return n, errors.New("invalid String")
+		return n, err
 	}
 
-	var size uint32
-	// 4바이트
-	err = binary.Read(r, binary.BigEndian, &size)
+	buf, o, err := readPayload(r, size)
 	if err != nil {
-		return n, err
+		return n + int64(o), err
 	}
-	n += 4
+	*m = String(buf)
+
+	return n + int64(o), nil
+}
 
-	if size > MAX_PAYLOAD_SIZE {
-		return n, ErrMaxPayloadSize
+func decode(r io.Reader) (Payload, error) {
+	return NewDecoder(r).Decode()
+}
+
+// | Type(1B) | Size(4B) | 헤더를 기록한다.
+func writeHeader(w io.Writer, typ uint8, size uint32) (int64, error) {
+	err := binary.Write(w, binary.BigEndian, typ)
+	if err != nil {
+		return 0, err
 	}
 
-	buf := make([]byte, size)
-	o, err := r.Read(buf)
+	err = binary.Write(w, binary.BigEndian, size)
 	if err != nil {
-		return n, err
+		return 1, err
 	}
-	*m = String(buf)
 
-	return n + int64(o), nil
+	return 5, nil
 }
 
-func decode(r io.Reader) (Payload, error) {
-	var typ uint8
-	err := binary.Read(r, binary.BigEndian, &typ)
+// 헤더를 읽고 타입을 확인한 뒤 페이로드 크기를 반환한다.
+func readHeader(r io.Reader, typ uint8, name string) (uint32, int64, error) {
+	var actual uint8
+	err := binary.Read(r, binary.BigEndian, &actual)
 	if err != nil {
-		return nil, err
+		return 0, 0, err
 	}
-	var payload Payload
-	switch typ {
-	case BINARY_TYPE:
-		payload = new(Binary)
-	case STRING_TYPE:
-		payload = new(String)
-	default:
-		return nil, errors.New("unknown type")
+	if actual != typ {
+		return 0, 1, errors.New("invalid " + name)
 	}
 
-	_, err = payload.ReadFrom(io.MultiReader(bytes.NewReader([]byte{typ}), r))
+	var size uint32
+	err = binary.Read(r, binary.BigEndian, &size)
 	if err != nil {
-		return nil, err
+		// 타입 바이트 이후에 스트림이 끝나면 프레임이 잘린 것이다.
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, 1, err
+	}
+	if limit := payloadLimit(r); size > limit {
+		return 0, 5, &MaxPayloadSizeError{Size: size, Limit: limit}
 	}
-	return payload, nil
+
+	return size, 5, nil
+}
+
+// io.ReadFull과 같지만 한 바이트도 읽지 못한 경우에도 io.ErrUnexpectedEOF를 반환한다.
+func readFull(r io.Reader, buf []byte) (int, error) {
+	n, err := io.ReadFull(r, buf)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+
+	return n, err
+}
+
+const payloadChunkSize = 64 << 10 // 64KB
+
+// 선언된 크기를 그대로 믿고 할당하지 않도록
+// 큰 페이로드는 실제로 읽은 만큼만 메모리를 늘린다.
+func readPayload(r io.Reader, size uint32) ([]byte, int, error) {
+	if size <= payloadChunkSize {
+		buf := make([]byte, size)
+		n, err := readFull(r, buf)
+
+		return buf, n, err
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0, payloadChunkSize))
+	n, err := io.CopyN(buf, r, int64(size))
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+
+	return buf.Bytes(), int(n), err
 }
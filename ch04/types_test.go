@@ -3,6 +3,7 @@ package main
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"net"
 	"reflect"
 	"testing"
@@ -71,7 +72,7 @@ func TestMaxPayloadSize(t *testing.T) {
 
 	var b Binary
 	_, err = b.ReadFrom(buf)
-	if err != ErrMaxPayloadSize {
+	if !errors.Is(err, ErrMaxPayloadSize) {
 		t.Fatalf("expected ErrMaxPayloadSize; got %v", err)
 	}
 }
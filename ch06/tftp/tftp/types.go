@@ -54,6 +54,11 @@ func (q ReadReq) MarshalBinary() ([]byte, error) {
 		mode = q.Mode
 	}
 
+	// 0바이트는 필드 구분자이므로 포함할 수 없다.
+	if len(q.Filename) == 0 || strings.ContainsRune(q.Filename, 0) || strings.ContainsRune(mode, 0) {
+		return nil, errors.New("invalid RRQ")
+	}
+
 	cap := 2 + 2 + len(q.Filename) + 1 + len(mode) + 1
 
 	b := new(bytes.Buffer)
@@ -139,6 +144,10 @@ type Data struct {
 }
 
 func (d *Data) MarshalBinary() ([]byte, error) {
+	if d.Payload == nil {
+		return nil, errors.New("invalid DATA")
+	}
+
 	b := new(bytes.Buffer)
 	b.Grow(DATAGRAM_SIZE)
 
@@ -234,6 +243,10 @@ type Err struct {
 }
 
 func (e Err) MarshalBinary() ([]byte, error) {
+	if strings.ContainsRune(e.Message, 0) {
+		return nil, errors.New("invalid ERR")
+	}
+
 	cap := 2 + 2 + len(e.Message) + 1
 
 	b := new(bytes.Buffer)
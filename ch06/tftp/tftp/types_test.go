@@ -0,0 +1,179 @@
+package tftp
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"testing/quick"
+)
+
+func FuzzReadReq(f *testing.F) {
+	for _, q := range []ReadReq{
+		{Filename: "payload.svg"},
+		{Filename: "test", Mode: "OCTET"},
+	} {
+		b, err := q.MarshalBinary()
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(b)
+	}
+	f.Add([]byte{0, 1, 0, 0})
+
+	f.Fuzz(func(t *testing.T, p []byte) {
+		var q ReadReq
+		if q.UnmarshalBinary(p) != nil {
+			return
+		}
+
+		b, err := q.MarshalBinary()
+		if err != nil {
+			t.Fatalf("marshaling %#v: %v", q, err)
+		}
+
+		var actual ReadReq
+		err = actual.UnmarshalBinary(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual != q {
+			t.Fatalf("expected %#v; got %#v", q, actual)
+		}
+	})
+}
+
+func FuzzData(f *testing.F) {
+	d := Data{Payload: strings.NewReader("Don't panic.")}
+	b, err := d.MarshalBinary()
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(b)
+	f.Add([]byte{0, 3, 0xff, 0xff})
+
+	f.Fuzz(func(t *testing.T, p []byte) {
+		var d Data
+		if d.UnmarshalBinary(p) != nil {
+			return
+		}
+
+		// MarshalBinary는 블록 번호를 증가시킨다.
+		d.Block--
+		b, err := d.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(b, p) {
+			t.Fatalf("expected %x; got %x", p, b)
+		}
+	})
+}
+
+func FuzzAck(f *testing.F) {
+	b, err := Ack(1).MarshalBinary()
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(b)
+	f.Add([]byte{0, 4})
+
+	f.Fuzz(func(t *testing.T, p []byte) {
+		var a Ack
+		if a.UnmarshalBinary(p) != nil {
+			return
+		}
+
+		b, err := a.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(b, p[:4]) {
+			t.Fatalf("expected %x; got %x", p[:4], b)
+		}
+	})
+}
+
+func FuzzErr(f *testing.F) {
+	b, err := Err{Error: ERR_NOTFOUND, Message: "not found"}.MarshalBinary()
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(b)
+	f.Add([]byte{0, 5, 0, 1})
+
+	f.Fuzz(func(t *testing.T, p []byte) {
+		var e Err
+		if e.UnmarshalBinary(p) != nil {
+			return
+		}
+
+		b, err := e.MarshalBinary()
+		if err != nil {
+			t.Fatalf("marshaling %#v: %v", e, err)
+		}
+
+		var actual Err
+		err = actual.UnmarshalBinary(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual != e {
+			t.Fatalf("expected %#v; got %#v", e, actual)
+		}
+	})
+}
+
+func TestPropertyRoundTrip(t *testing.T) {
+	check := func(name string, f any) {
+		t.Helper()
+
+		err := quick.Check(f, nil)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+
+	check("RRQ", func(filename string) bool {
+		q := ReadReq{Filename: filename, Mode: "octet"}
+		b, err := q.MarshalBinary()
+		if filename == "" || strings.ContainsRune(filename, 0) {
+			return err != nil
+		}
+
+		var actual ReadReq
+		return err == nil && actual.UnmarshalBinary(b) == nil && actual == q
+	})
+	check("DATA", func(block uint16, payload []byte) bool {
+		d := Data{Block: block, Payload: bytes.NewReader(payload)}
+		b, err := d.MarshalBinary()
+		if err != nil {
+			return false
+		}
+
+		var actual Data
+		if actual.UnmarshalBinary(b) != nil || actual.Block != block+1 {
+			return false
+		}
+
+		// 한 블록을 넘는 데이터는 잘린다.
+		received, err := io.ReadAll(actual.Payload)
+		return err == nil && bytes.Equal(received, payload[:min(len(payload), BLOCK_SIZE)])
+	})
+	check("ACK", func(block uint16) bool {
+		b, err := Ack(block).MarshalBinary()
+
+		var actual Ack
+		return err == nil && actual.UnmarshalBinary(b) == nil && actual == Ack(block)
+	})
+	check("ERR", func(code uint16, message string) bool {
+		e := Err{Error: ErrCode(code), Message: message}
+		b, err := e.MarshalBinary()
+		if strings.ContainsRune(message, 0) {
+			return err != nil
+		}
+
+		var actual Err
+		return err == nil && actual.UnmarshalBinary(b) == nil && actual == e
+	})
+}